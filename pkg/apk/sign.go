@@ -0,0 +1,180 @@
+package apk
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"chainguard.dev/apko/pkg/apk/auth"
+	"chainguard.dev/apko/pkg/apk/signature"
+)
+
+// ErrUnsigned is returned when verifying an APKINDEX.tar.gz without signatures.
+var ErrUnsigned = errors.New("index is not signed")
+
+var signatureName = regexp.MustCompile(`^\.SIGN\.(RSA|RSA256)\.(.+)$`)
+
+// SignIndex returns the unsigned APKINDEX.tar.gz archive signed with each of the
+// RSA private keys in keyFiles. Each signature is named after the public key of
+// its key file, the base name of the key file with .pub appended, the way
+// melange names them.
+func SignIndex(archive []byte, keyFiles ...string) ([]byte, error) {
+	if len(keyFiles) == 0 {
+		return nil, fmt.Errorf("no keys to sign the index with")
+	}
+	digest := crypto.SHA256.New()
+	digest.Write(archive)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, key := range keyFiles {
+		sig, err := signature.RSASignDigest(digest.Sum(nil), crypto.SHA256, key, "")
+		if err != nil {
+			return nil, fmt.Errorf("signing index with %s: %w", key, err)
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     fmt.Sprintf(".SIGN.RSA256.%s.pub", path.Base(key)),
+			Typeflag: tar.TypeReg,
+			Size:     int64(len(sig)),
+			Mode:     0o644,
+			Uname:    "root",
+			Gname:    "root",
+			ModTime:  time.Unix(0, 0),
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(sig); err != nil {
+			return nil, err
+		}
+	}
+	// The signatures are prepended to the index, so there's no end-of-archive
+	// marker.
+	if err := tw.Flush(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	buf.Write(archive)
+	return buf.Bytes(), nil
+}
+
+// VerifyIndex verifies the signatures of the signed APKINDEX.tar.gz archive
+// against the public keys in keyring, keyed by the file name of the key. It
+// returns the name of the key of the first signature that verifies, and fails
+// if none does. Signatures made with keys that aren't in the keyring are
+// ignored.
+func VerifyIndex(archive []byte, keyring map[string][]byte) (string, error) {
+	r := bytes.NewReader(archive)
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return "", fmt.Errorf("reading index: %w", err)
+	}
+	// The signatures are in a gzip stream of their own, followed by the
+	// signed index.
+	zr.Multistream(false)
+
+	type sig struct {
+		key    string
+		hash   crypto.Hash
+		signed []byte
+	}
+	var sigs []sig
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading index signatures: %w", err)
+		}
+		m := signatureName.FindStringSubmatch(hdr.Name)
+		if m == nil {
+			if len(sigs) == 0 {
+				return "", ErrUnsigned
+			}
+			return "", fmt.Errorf("unexpected file %s in index signatures", hdr.Name)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return "", fmt.Errorf("reading signature %s: %w", hdr.Name, err)
+		}
+		hash := crypto.SHA256
+		if m[1] == "RSA" {
+			hash = crypto.SHA1
+		}
+		sigs = append(sigs, sig{key: m[2], hash: hash, signed: b})
+	}
+	if len(sigs) == 0 {
+		return "", ErrUnsigned
+	}
+
+	signed := archive[len(archive)-r.Len():]
+	var errs []error
+	for _, s := range sigs {
+		key, ok := keyring[s.key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("signed with %s, which isn't in the keyring", s.key))
+			continue
+		}
+		h := s.hash.New()
+		h.Write(signed)
+		if err := signature.RSAVerifyDigest(h.Sum(nil), s.hash, s.signed, key); err != nil {
+			errs = append(errs, fmt.Errorf("signature of %s: %w", s.key, err))
+			continue
+		}
+		return s.key, nil
+	}
+	return "", fmt.Errorf("no signature of the index verifies: %w", errors.Join(errs...))
+}
+
+// LoadKeyring reads the public keys at the given paths or https URLs, keyed by
+// their file names as in the names of index signatures.
+func LoadKeyring(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	keyring := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		var b []byte
+		if strings.HasPrefix(key, "https://") {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+			if err != nil {
+				return nil, err
+			}
+			if err := auth.DefaultAuthenticators.AddAuth(ctx, req); err != nil {
+				return nil, err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return nil, fmt.Errorf("fetching key %s: %w", key, err)
+			}
+			b, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("reading key %s: %w", key, err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("fetching key %s: %s", key, resp.Status)
+			}
+		} else {
+			var err error
+			if b, err = os.ReadFile(key); err != nil {
+				return nil, fmt.Errorf("reading key: %w", err)
+			}
+		}
+		keyring[path.Base(key)] = b
+	}
+	return keyring, nil
+}
@@ -0,0 +1,95 @@
+package apk
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wolfi-dev/wolfictl/pkg/apk/apktest"
+)
+
+func testIndexArchive(t *testing.T) []byte {
+	t.Helper()
+	r, err := apk.ArchiveFromIndex(&apk.APKIndex{
+		Description: "test",
+		Packages:    []*apk.Package{{Name: "foo", Version: "1.0-r0", Arch: "x86_64"}},
+	})
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return b
+}
+
+func TestSignIndex(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	first, second := apktest.WriteSigningKey(t, dir, "first.rsa"), apktest.WriteSigningKey(t, dir, "second.rsa")
+	other := apktest.WriteSigningKey(t, t.TempDir(), "first.rsa")
+	archive := testIndexArchive(t)
+
+	signed, err := SignIndex(archive, first, second)
+	require.NoError(t, err)
+
+	// The signed index is still a valid index.
+	index, err := apk.IndexFromArchive(io.NopCloser(bytes.NewReader(signed)))
+	require.NoError(t, err)
+	require.Len(t, index.Packages, 1)
+	assert.Equal(t, "foo", index.Packages[0].Name)
+
+	t.Run("verifies with any key", func(t *testing.T) {
+		keyring, err := LoadKeyring(ctx, second+".pub")
+		require.NoError(t, err)
+		key, err := VerifyIndex(signed, keyring)
+		require.NoError(t, err)
+		assert.Equal(t, "second.rsa.pub", key)
+
+		keyring, err = LoadKeyring(ctx, first+".pub", second+".pub")
+		require.NoError(t, err)
+		key, err = VerifyIndex(signed, keyring)
+		require.NoError(t, err)
+		assert.Equal(t, "first.rsa.pub", key)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		keyring, err := LoadKeyring(ctx, apktest.WriteSigningKey(t, t.TempDir(), "third.rsa")+".pub")
+		require.NoError(t, err)
+		_, err = VerifyIndex(signed, keyring)
+		assert.ErrorContains(t, err, "isn't in the keyring")
+	})
+
+	t.Run("wrong key of the same name", func(t *testing.T) {
+		keyring, err := LoadKeyring(ctx, other+".pub")
+		require.NoError(t, err)
+		_, err = VerifyIndex(signed, keyring)
+		assert.ErrorContains(t, err, "signature of first.rsa.pub")
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := append([]byte{}, signed...)
+		tampered[len(tampered)-1] ^= 0xff
+
+		keyring, err := LoadKeyring(ctx, first+".pub")
+		require.NoError(t, err)
+		_, err = VerifyIndex(tampered, keyring)
+		assert.ErrorContains(t, err, "no signature of the index verifies")
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		keyring, err := LoadKeyring(ctx, first+".pub")
+		require.NoError(t, err)
+		_, err = VerifyIndex(archive, keyring)
+		assert.ErrorIs(t, err, ErrUnsigned)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, err := LoadKeyring(ctx, filepath.Join(dir, "missing.rsa.pub"))
+		assert.Error(t, err)
+		_, err = SignIndex(archive, filepath.Join(dir, "missing.rsa"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,235 @@
+package apk
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+)
+
+// releaseSuffix matches the version and release at the end of an exact
+// name-version such as foo-1.2.3-r4.
+var releaseSuffix = regexp.MustCompile(`-[0-9][^-]*-r[0-9]+$`)
+
+// A Selector selects packages of an index by name, or by origin.
+type Selector struct {
+	// Spec is the selector as given.
+	Spec string
+
+	// Origin selects the packages built from the matching origins, i.e. an
+	// origin package and all its subpackages.
+	Origin bool
+
+	// exact is the name-version of the package to select, if the selector
+	// is one.
+	exact string
+
+	// pattern is the glob of the names to select, and constraint is the
+	// constraint on their version.
+	pattern    string
+	constraint apk.ParsedConstraint
+}
+
+// ParseSelector parses a package selector, one of:
+//
+//   - an exact name-version, such as foo-1.2.3-r4, with an optional .apk suffix
+//   - a glob of names, such as py3-*, selecting all their versions
+//   - a glob of names followed by a version constraint, such as
+//     openssl<3.1.4-r2 or libfoo*~1.2
+//
+// With origin, names are matched against the origin of packages instead.
+func ParseSelector(spec string, origin bool) (Selector, error) {
+	s := Selector{Spec: spec, Origin: origin}
+	trimmed := strings.TrimSuffix(spec, ".apk")
+	if !strings.ContainsAny(trimmed, "<>=~") && releaseSuffix.MatchString(trimmed) {
+		s.exact = trimmed
+		return s, nil
+	}
+
+	s.constraint = apk.ResolvePackageNameVersionPin(spec)
+	s.pattern = s.constraint.Name
+	if _, err := path.Match(s.pattern, ""); err != nil {
+		return Selector{}, fmt.Errorf("invalid package selector %q: %w", spec, err)
+	}
+	if s.constraint.Version != "" {
+		if _, err := apk.ParseVersion(s.constraint.Version); err != nil {
+			return Selector{}, fmt.Errorf("invalid version in package selector %q: %w", spec, err)
+		}
+	}
+	return s, nil
+}
+
+// Matches reports whether the selector selects pkg.
+func (s Selector) Matches(pkg *apk.Package) (bool, error) {
+	name := pkg.Name
+	if s.Origin && pkg.Origin != "" {
+		name = pkg.Origin
+	}
+	if s.exact != "" {
+		return name+"-"+pkg.Version == s.exact, nil
+	}
+
+	if ok, _ := path.Match(s.pattern, name); !ok {
+		return false, nil
+	}
+	v, err := apk.ParseVersion(pkg.Version)
+	if err != nil {
+		return false, fmt.Errorf("parsing version %q of %s: %w", pkg.Version, pkg.Name, err)
+	}
+	return s.constraint.SatisfiedBy(v)
+}
+
+// A Withdrawal selects the packages to withdraw from an index.
+type Withdrawal struct {
+	Selectors []Selector
+
+	// BuiltAfter and BuiltBefore, if set, restrict the selected packages to
+	// those built in that range.
+	BuiltAfter  time.Time
+	BuiltBefore time.Time
+}
+
+// Matches reports whether pkg is to be withdrawn, and the selector that
+// selected it.
+func (w Withdrawal) Matches(pkg *apk.Package) (*Selector, error) {
+	if !w.BuiltAfter.IsZero() && !pkg.BuildTime.After(w.BuiltAfter) {
+		return nil, nil
+	}
+	if !w.BuiltBefore.IsZero() && !pkg.BuildTime.Before(w.BuiltBefore) {
+		return nil, nil
+	}
+	for i, s := range w.Selectors {
+		ok, err := s.Matches(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &w.Selectors[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Apply removes the packages to withdraw from index, and returns them together
+// with the selectors that didn't select any package.
+func (w Withdrawal) Apply(index *apk.APKIndex) ([]*apk.Package, []Selector, error) {
+	used := make(map[string]bool, len(w.Selectors))
+	var withdrawn, kept []*apk.Package
+	for _, pkg := range index.Packages {
+		s, err := w.Matches(pkg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if s == nil {
+			kept = append(kept, pkg)
+			continue
+		}
+		used[s.Spec] = true
+		withdrawn = append(withdrawn, pkg)
+	}
+	index.Packages = kept
+
+	var unused []Selector
+	for _, s := range w.Selectors {
+		if !used[s.Spec] {
+			unused = append(unused, s)
+		}
+	}
+	return withdrawn, unused, nil
+}
+
+// Broken is a dependency of a package that only withdrawn packages satisfied.
+type Broken struct {
+	Package    *apk.Package
+	Dependency string
+
+	// Withdrawn are the withdrawn packages that satisfied the dependency.
+	Withdrawn []*apk.Package
+}
+
+// BrokenDependencies returns the dependencies of the remaining packages that
+// are satisfied by a withdrawn package, by name or by a provides entry, but
+// no longer by any of the remaining packages. Dependencies that weren't
+// satisfied in the first place aren't reported.
+func BrokenDependencies(remaining, withdrawn []*apk.Package) ([]Broken, error) {
+	before, after := newProviders(withdrawn), newProviders(remaining)
+
+	var broken []Broken
+	for _, pkg := range remaining {
+		for _, dep := range pkg.Dependencies {
+			if strings.HasPrefix(dep, "!") {
+				continue
+			}
+			c := apk.ResolvePackageNameVersionPin(dep)
+			ok, err := after.satisfy(c)
+			if err != nil {
+				return nil, fmt.Errorf("%s-%s: %w", pkg.Name, pkg.Version, err)
+			}
+			if ok {
+				continue
+			}
+			var by []*apk.Package
+			for _, p := range before[c.Name] {
+				if ok, err := p.satisfies(c); err != nil {
+					return nil, fmt.Errorf("%s-%s: %w", pkg.Name, pkg.Version, err)
+				} else if ok {
+					by = append(by, p.pkg)
+				}
+			}
+			if len(by) > 0 {
+				broken = append(broken, Broken{Package: pkg, Dependency: dep, Withdrawn: by})
+			}
+		}
+	}
+	return broken, nil
+}
+
+// provider is a package providing a name, with the version it provides.
+type provider struct {
+	pkg     *apk.Package
+	version string
+}
+
+func (p provider) satisfies(c apk.ParsedConstraint) (bool, error) {
+	if c.Version == "" {
+		return true, nil
+	}
+	// Provides entries without a version don't satisfy versioned
+	// dependencies.
+	if p.version == "" {
+		return false, nil
+	}
+	v, err := apk.ParseVersion(p.version)
+	if err != nil {
+		return false, fmt.Errorf("parsing version %q provided by %s: %w", p.version, p.pkg.Name, err)
+	}
+	return c.SatisfiedBy(v)
+}
+
+// providers are the packages providing each name.
+type providers map[string][]provider
+
+func newProviders(pkgs []*apk.Package) providers {
+	out := providers{}
+	for _, pkg := range pkgs {
+		out[pkg.Name] = append(out[pkg.Name], provider{pkg: pkg, version: pkg.Version})
+		for _, p := range pkg.Provides {
+			c := apk.ResolvePackageNameVersionPin(p)
+			out[c.Name] = append(out[c.Name], provider{pkg: pkg, version: c.Version})
+		}
+	}
+	return out
+}
+
+func (ps providers) satisfy(c apk.ParsedConstraint) (bool, error) {
+	for _, p := range ps[c.Name] {
+		ok, err := p.satisfies(c)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
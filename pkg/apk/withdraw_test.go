@@ -0,0 +1,127 @@
+package apk
+
+import (
+	"testing"
+	"time"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func names(pkgs []*apk.Package) []string {
+	var out []string
+	for _, pkg := range pkgs {
+		out = append(out, pkg.Name+"-"+pkg.Version)
+	}
+	return out
+}
+
+func TestSelector(t *testing.T) {
+	pkgs := []*apk.Package{
+		{Name: "openssl", Version: "3.1.3-r0", Origin: "openssl"},
+		{Name: "openssl", Version: "3.1.4-r2", Origin: "openssl"},
+		{Name: "libssl3", Version: "3.1.3-r0", Origin: "openssl"},
+		{Name: "py3-foo", Version: "1.2.0-r0", Origin: "py3-foo"},
+		{Name: "py3-bar", Version: "2.0.0-r0"},
+	}
+
+	for _, tt := range []struct {
+		spec   string
+		origin bool
+		want   []string
+	}{
+		{spec: "openssl-3.1.3-r0", want: []string{"openssl-3.1.3-r0"}},
+		{spec: "openssl-3.1.3-r0.apk", want: []string{"openssl-3.1.3-r0"}},
+		{spec: "openssl-3.1.3-r0", origin: true, want: []string{"openssl-3.1.3-r0", "libssl3-3.1.3-r0"}},
+		{spec: "openssl<3.1.4-r2", want: []string{"openssl-3.1.3-r0"}},
+		{spec: "openssl>=3.1.4", want: []string{"openssl-3.1.4-r2"}},
+		{spec: "openssl", want: []string{"openssl-3.1.3-r0", "openssl-3.1.4-r2"}},
+		{spec: "openssl<3.1.4-r2", origin: true, want: []string{"openssl-3.1.3-r0", "libssl3-3.1.3-r0"}},
+		{spec: "py3-*", want: []string{"py3-foo-1.2.0-r0", "py3-bar-2.0.0-r0"}},
+		{spec: "py3-*~1.2", want: []string{"py3-foo-1.2.0-r0"}},
+		// Packages without an origin are their own origin.
+		{spec: "py3-b*", origin: true, want: []string{"py3-bar-2.0.0-r0"}},
+		{spec: "libssl3", origin: true},
+	} {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := ParseSelector(tt.spec, tt.origin)
+			require.NoError(t, err)
+			var got []string
+			for _, pkg := range pkgs {
+				ok, err := s.Matches(pkg)
+				require.NoError(t, err)
+				if ok {
+					got = append(got, pkg.Name+"-"+pkg.Version)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseSelector("foo[", false)
+		assert.Error(t, err)
+		_, err = ParseSelector("foo<not a version", false)
+		assert.Error(t, err)
+	})
+}
+
+func TestWithdrawalApply(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, time.January, d, 0, 0, 0, 0, time.UTC) }
+	index := &apk.APKIndex{Packages: []*apk.Package{
+		{Name: "foo", Version: "1.0-r0", BuildTime: day(1)},
+		{Name: "foo", Version: "1.0-r1", BuildTime: day(2)},
+		{Name: "foo", Version: "1.0-r2", BuildTime: day(3)},
+		{Name: "bar", Version: "2.0-r0", BuildTime: day(2)},
+	}}
+
+	sel := func(spec string) Selector {
+		s, err := ParseSelector(spec, false)
+		require.NoError(t, err)
+		return s
+	}
+	wd := Withdrawal{
+		Selectors:   []Selector{sel("foo"), sel("baz")},
+		BuiltAfter:  day(1),
+		BuiltBefore: day(3),
+	}
+	withdrawn, unused, err := wd.Apply(index)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo-1.0-r1"}, names(withdrawn))
+	assert.Equal(t, []string{"foo-1.0-r0", "foo-1.0-r2", "bar-2.0-r0"}, names(index.Packages))
+	require.Len(t, unused, 1)
+	assert.Equal(t, "baz", unused[0].Spec)
+}
+
+func TestBrokenDependencies(t *testing.T) {
+	libssl := &apk.Package{Name: "libssl3", Version: "3.1.3-r0", Provides: []string{"so:libssl.so.3=3"}}
+	newLibssl := &apk.Package{Name: "libssl3", Version: "3.1.4-r0", Provides: []string{"so:libssl.so.3=3"}}
+	openssl := &apk.Package{Name: "openssl", Version: "3.1.3-r0", Provides: []string{"cmd:openssl=3.1.3-r0"}}
+	curl := &apk.Package{Name: "curl", Version: "8.0-r0", Dependencies: []string{"so:libssl.so.3", "cmd:openssl", "!wget"}}
+	pinned := &apk.Package{Name: "libssl-dev", Version: "3.1.3-r0", Dependencies: []string{"libssl3=3.1.3-r0"}}
+	missing := &apk.Package{Name: "baz", Version: "1.0-r0", Dependencies: []string{"not-in-the-index"}}
+
+	t.Run("broken", func(t *testing.T) {
+		broken, err := BrokenDependencies([]*apk.Package{curl, pinned, missing}, []*apk.Package{libssl, openssl})
+		require.NoError(t, err)
+		var got []string
+		for _, b := range broken {
+			got = append(got, b.Package.Name+" "+b.Dependency+" "+names(b.Withdrawn)[0])
+		}
+		assert.Equal(t, []string{
+			"curl so:libssl.so.3 libssl3-3.1.3-r0",
+			"curl cmd:openssl openssl-3.1.3-r0",
+			"libssl-dev libssl3=3.1.3-r0 libssl3-3.1.3-r0",
+		}, got)
+	})
+
+	t.Run("still provided", func(t *testing.T) {
+		// Another version of libssl3 still provides the soname, but not the
+		// pinned version.
+		broken, err := BrokenDependencies([]*apk.Package{newLibssl, openssl, curl, pinned}, []*apk.Package{libssl})
+		require.NoError(t, err)
+		require.Len(t, broken, 1)
+		assert.Equal(t, pinned, broken[0].Package)
+	})
+}
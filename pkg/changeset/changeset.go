@@ -0,0 +1,307 @@
+// Package changeset turns edits to the files of a git repository into a
+// commit on a new branch, pushed to a remote, with a pull request opened for
+// it on GitHub.
+package changeset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"text/template"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/v58/github"
+	"github.com/wolfi-dev/wolfictl/pkg/gh"
+	wgit "github.com/wolfi-dev/wolfictl/pkg/git"
+)
+
+// ErrEmpty is returned when committing a change set without changes.
+var ErrEmpty = errors.New("no changes to commit")
+
+// Options configure a ChangeSet.
+type Options struct {
+	// Branch is the branch to create for the change set, from the commit
+	// currently checked out.
+	Branch string
+
+	// Base is the branch to open the pull request against. It defaults to the
+	// branch currently checked out.
+	Base string
+
+	// Remote is the remote to push the branch to. It defaults to origin.
+	Remote string
+
+	// Auth authenticates the push. It defaults to the GITHUB_TOKEN of
+	// GetGitAuth for github.com remotes.
+	Auth transport.AuthMethod
+
+	// Owner and Repo are the GitHub repository to open the pull request on.
+	// They default to those of the remote's URL.
+	Owner string
+	Repo  string
+}
+
+// A ChangeSet is a set of edits to the configs of packages in a git repository,
+// made on a branch of its own.
+type ChangeSet struct {
+	opts     Options
+	repo     *git.Repository
+	dir      string
+	packages []string
+	paths    []string
+
+	// orig is the HEAD of the repository when the change set was created.
+	orig *plumbing.Reference
+}
+
+// New creates the branch of a change set in the git repository containing dir
+// and checks it out, keeping any changes in the worktree. The files of the
+// change set are added relative to dir.
+func New(dir string, opts Options) (*ChangeSet, error) {
+	if opts.Branch == "" {
+		return nil, fmt.Errorf("no branch for the change set")
+	}
+	if opts.Remote == "" {
+		opts.Remote = "origin"
+	}
+
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("opening git repository at %s: %w", dir, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("resolving HEAD: %w", err)
+	}
+	if opts.Base == "" {
+		if !head.Name().IsBranch() {
+			return nil, fmt.Errorf("HEAD is detached, a base branch is needed for the pull request")
+		}
+		opts.Base = head.Name().Short()
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(opts.Branch),
+		Create: true,
+		Keep:   true,
+	}); err != nil {
+		return nil, fmt.Errorf("creating branch %s: %w", opts.Branch, err)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeSet{opts: opts, repo: repo, dir: abs, orig: head}, nil
+}
+
+// Abort checks out the branch or commit that was checked out when the change
+// set was created again, keeping any changes in the worktree, e.g. after the
+// edits of the change set failed. The branch of the change set is deleted,
+// unless something was committed to it already.
+func (cs *ChangeSet) Abort() error {
+	wt, err := cs.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	co := &git.CheckoutOptions{Keep: true}
+	if cs.orig.Name().IsBranch() {
+		co.Branch = cs.orig.Name()
+	} else {
+		co.Hash = cs.orig.Hash()
+	}
+	if err := wt.Checkout(co); err != nil {
+		return fmt.Errorf("checking out %s again: %w", cs.orig.Name().Short(), err)
+	}
+
+	branch := plumbing.NewBranchReferenceName(cs.opts.Branch)
+	ref, err := cs.repo.Reference(branch, true)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", cs.opts.Branch, err)
+	}
+	if ref.Hash() != cs.orig.Hash() {
+		return nil
+	}
+	if err := cs.repo.Storer.RemoveReference(branch); err != nil {
+		return fmt.Errorf("deleting branch %s: %w", cs.opts.Branch, err)
+	}
+	return nil
+}
+
+// Branch returns the branch of the change set.
+func (cs *ChangeSet) Branch() string {
+	return cs.opts.Branch
+}
+
+// Add records the edit of the configs at paths for pkg.
+func (cs *ChangeSet) Add(pkg string, paths ...string) error {
+	wt, err := cs.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(cs.dir, p)
+		}
+		rel, err := filepath.Rel(wt.Filesystem.Root(), p)
+		if err != nil {
+			return fmt.Errorf("%s isn't in the worktree: %w", p, err)
+		}
+		rel = filepath.ToSlash(rel)
+		if !slices.Contains(cs.paths, rel) {
+			cs.paths = append(cs.paths, rel)
+		}
+	}
+	if !slices.Contains(cs.packages, pkg) {
+		cs.packages = append(cs.packages, pkg)
+	}
+	return nil
+}
+
+// Packages returns the packages changed, in the order they were added.
+func (cs *ChangeSet) Packages() []string {
+	return cs.packages
+}
+
+var bodyTemplate = template.Must(template.New("body").Parse(`{{.Description}}
+
+Packages changed:
+{{range .Packages}}- {{.}}
+{{end}}`))
+
+// Body returns the description of the change set, followed by the list of the
+// packages changed.
+func (cs *ChangeSet) Body(description string) (string, error) {
+	var buf bytes.Buffer
+	if err := bodyTemplate.Execute(&buf, struct {
+		Description string
+		Packages    []string
+	}{description, cs.packages}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Commit commits the changed files to the branch of the change set, with the
+// title as the subject of the commit message and the Body as its body. The
+// author is taken from GIT_AUTHOR_NAME and GIT_AUTHOR_EMAIL if they are set,
+// and from the git config otherwise.
+func (cs *ChangeSet) Commit(ctx context.Context, title, description string) (plumbing.Hash, error) {
+	if len(cs.paths) == 0 {
+		return plumbing.ZeroHash, ErrEmpty
+	}
+	wt, err := cs.repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	for _, p := range cs.paths {
+		if _, err := wt.Add(p); err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("adding %s: %w", p, err)
+		}
+	}
+
+	body, err := cs.Body(description)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	hash, err := wt.Commit(title+"\n\n"+body, &git.CommitOptions{Author: wgit.GetGitAuthorSignature()})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("committing to %s: %w", cs.opts.Branch, err)
+	}
+	clog.FromContext(ctx).Infof("committed %s to %s", hash, cs.opts.Branch)
+	return hash, nil
+}
+
+// Push pushes the branch of the change set to the remote.
+func (cs *ChangeSet) Push(ctx context.Context) error {
+	ref := plumbing.NewBranchReferenceName(cs.opts.Branch)
+	po := &git.PushOptions{
+		RemoteName: cs.opts.Remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", ref, ref))},
+		Auth:       cs.opts.Auth,
+	}
+	if po.Auth == nil {
+		if u, err := cs.remoteURL(); err == nil && u.Host == "github.com" {
+			// Push over https with the GITHUB_TOKEN, as git@ URLs would need
+			// ssh keys.
+			auth, err := wgit.GetGitAuth(u.RawURL)
+			if err != nil {
+				return fmt.Errorf("getting git auth: %w", err)
+			}
+			po.RemoteURL = u.RawURL
+			po.Auth = auth
+		}
+	}
+
+	if err := cs.repo.PushContext(ctx, po); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("pushing %s to %s: %w", cs.opts.Branch, cs.opts.Remote, err)
+	}
+	clog.FromContext(ctx).Infof("pushed %s to %s", cs.opts.Branch, cs.opts.Remote)
+	return nil
+}
+
+func (cs *ChangeSet) remoteURL() (*wgit.URL, error) {
+	remote, err := cs.repo.Remote(cs.opts.Remote)
+	if err != nil {
+		return nil, fmt.Errorf("finding remote %s: %w", cs.opts.Remote, err)
+	}
+	if len(remote.Config().URLs) == 0 {
+		return nil, fmt.Errorf("no URL configured for remote %s", cs.opts.Remote)
+	}
+	u, err := wgit.ParseGitURL(remote.Config().URLs[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing the URL of remote %s: %w", cs.opts.Remote, err)
+	}
+	return u, nil
+}
+
+// OpenPullRequest opens a pull request of the pushed branch of the change set
+// against the base branch, with the Body of the change set.
+func (cs *ChangeSet) OpenPullRequest(ctx context.Context, gitOpts gh.GitOptions, title, description string) (*github.PullRequest, error) {
+	owner, repo := cs.opts.Owner, cs.opts.Repo
+	if owner == "" || repo == "" {
+		u, err := cs.remoteURL()
+		if err != nil {
+			return nil, err
+		}
+		owner, repo = u.Organisation, u.Name
+	}
+
+	body, err := cs.Body(description)
+	if err != nil {
+		return nil, err
+	}
+	return gitOpts.OpenPullRequest(ctx, &gh.NewPullRequest{
+		BasePullRequest: gh.BasePullRequest{
+			Owner:                 owner,
+			RepoName:              repo,
+			Branch:                cs.opts.Branch,
+			PullRequestBaseBranch: cs.opts.Base,
+		},
+		Title: title,
+		Body:  body,
+	})
+}
+
+// Submit commits the change set, pushes its branch and opens a pull request
+// for it.
+func (cs *ChangeSet) Submit(ctx context.Context, gitOpts gh.GitOptions, title, description string) (*github.PullRequest, error) {
+	if _, err := cs.Commit(ctx, title, description); err != nil {
+		return nil, err
+	}
+	if err := cs.Push(ctx); err != nil {
+		return nil, err
+	}
+	return cs.OpenPullRequest(ctx, gitOpts, title, description)
+}
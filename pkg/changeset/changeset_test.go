@@ -0,0 +1,170 @@
+package changeset
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wolfi-dev/wolfictl/pkg/gh"
+)
+
+// testRepo returns a clone of a bare repository with a main branch, containing
+// foo.yaml and bar.yaml, and the path of the bare repository.
+func testRepo(t *testing.T) (string, string) {
+	t.Helper()
+	remote := t.TempDir()
+	_, err := git.PlainInit(remote, true)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	r, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.Main},
+	})
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	for _, name := range []string{"foo.yaml", "bar.yaml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("epoch: 0\n"), 0o644))
+		_, err := w.Add(name)
+		require.NoError(t, err)
+	}
+	_, err = w.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remote}})
+	require.NoError(t, err)
+	require.NoError(t, r.Push(&git.PushOptions{RemoteName: "origin"}))
+	return dir, remote
+}
+
+func TestChangeSet(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("GIT_AUTHOR_NAME", "wolfictl")
+	t.Setenv("GIT_AUTHOR_EMAIL", "wolfictl@example.com")
+	dir, remote := testRepo(t)
+
+	var got github.NewPullRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/wolfi-dev/os/pulls" {
+			http.NotFound(w, r)
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(b, &got))
+		w.WriteHeader(http.StatusCreated)
+		_, err = w.Write([]byte(`{"number": 42, "html_url": "https://github.com/wolfi-dev/os/pull/42"}`))
+		assert.NoError(t, err)
+	}))
+	defer srv.Close()
+	client := github.NewClient(srv.Client())
+	var err error
+	client.BaseURL, err = url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+
+	cs, err := New(dir, Options{Branch: "wolfictl-bump-test", Owner: "wolfi-dev", Repo: "os"})
+	require.NoError(t, err)
+
+	_, err = cs.Commit(ctx, "nothing", "")
+	assert.ErrorIs(t, err, ErrEmpty)
+
+	// Edits are made after the branch is created, and only the files added to
+	// the change set are committed.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.yaml"), []byte("epoch: 1\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar.yaml"), []byte("epoch: 1\n"), 0o644))
+	require.NoError(t, cs.Add("foo", "foo.yaml"))
+	require.NoError(t, cs.Add("foo", filepath.Join(dir, "foo.yaml")))
+	assert.Equal(t, []string{"foo"}, cs.Packages())
+
+	pr, err := cs.Submit(ctx, gh.GitOptions{GithubClient: client}, "Bump epoch of foo", "Rebuild for a soname change.")
+	require.NoError(t, err)
+	assert.Equal(t, 42, pr.GetNumber())
+
+	wantBody := "Rebuild for a soname change.\n\nPackages changed:\n- foo\n"
+	assert.Equal(t, "Bump epoch of foo", got.GetTitle())
+	assert.Equal(t, "wolfictl-bump-test", got.GetHead())
+	assert.Equal(t, "main", got.GetBase())
+	assert.Equal(t, wantBody, got.GetBody())
+
+	// The branch was pushed to the remote with the edit of foo.yaml only.
+	r, err := git.PlainOpen(remote)
+	require.NoError(t, err)
+	ref, err := r.Reference(plumbing.NewBranchReferenceName("wolfictl-bump-test"), true)
+	require.NoError(t, err)
+	commit, err := r.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "Bump epoch of foo\n\n"+wantBody, commit.Message)
+	assert.Equal(t, "wolfictl", commit.Author.Name)
+
+	for name, want := range map[string]string{"foo.yaml": "epoch: 1\n", "bar.yaml": "epoch: 0\n"} {
+		f, err := commit.File(name)
+		require.NoError(t, err)
+		content, err := f.Contents()
+		require.NoError(t, err)
+		assert.Equal(t, want, content, name)
+	}
+
+	main, err := r.Reference(plumbing.NewBranchReferenceName("main"), true)
+	require.NoError(t, err)
+	assert.Equal(t, []plumbing.Hash{main.Hash()}, commit.ParentHashes)
+}
+
+func TestChangeSetAbort(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("GIT_AUTHOR_NAME", "wolfictl")
+	t.Setenv("GIT_AUTHOR_EMAIL", "wolfictl@example.com")
+	dir, _ := testRepo(t)
+	r, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+
+	t.Run("nothing committed", func(t *testing.T) {
+		cs, err := New(dir, Options{Branch: "wolfictl-bump-abort"})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.yaml"), []byte("epoch: 1\n"), 0o644))
+		require.NoError(t, cs.Abort())
+
+		head, err := r.Head()
+		require.NoError(t, err)
+		assert.Equal(t, "main", head.Name().Short())
+		_, err = r.Reference(plumbing.NewBranchReferenceName("wolfictl-bump-abort"), true)
+		assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+
+		// The edit is left in the worktree.
+		b, err := os.ReadFile(filepath.Join(dir, "foo.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "epoch: 1\n", string(b))
+	})
+
+	t.Run("committed", func(t *testing.T) {
+		cs, err := New(dir, Options{Branch: "wolfictl-bump-committed"})
+		require.NoError(t, err)
+		require.NoError(t, cs.Add("foo", "foo.yaml"))
+		hash, err := cs.Commit(ctx, "Bump epoch of foo", "")
+		require.NoError(t, err)
+		require.NoError(t, cs.Abort())
+
+		head, err := r.Head()
+		require.NoError(t, err)
+		assert.Equal(t, "main", head.Name().Short())
+		// The branch is kept with its commit, e.g. to push it again.
+		ref, err := r.Reference(plumbing.NewBranchReferenceName("wolfictl-bump-committed"), true)
+		require.NoError(t, err)
+		assert.Equal(t, hash, ref.Hash())
+	})
+}
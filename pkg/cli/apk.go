@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -33,6 +34,7 @@ func cmdApk() *cobra.Command {
 func cmdCp() *cobra.Command {
 	var latest bool
 	var indexURL, outDir, gcsPath string
+	signing := signingParams{}
 	cmd := &cobra.Command{
 		Use:          "cp",
 		Aliases:      []string{"copy"},
@@ -42,10 +44,17 @@ func cmdCp() *cobra.Command {
 
 			repoURL := strings.TrimSuffix(indexURL, "/APKINDEX.tar.gz")
 
-			index, arch, err := fetchAPKIndex(ctx, indexURL)
+			archive, arch, err := fetchAPKIndexArchive(ctx, indexURL)
 			if err != nil {
 				return err
 			}
+			if err := signing.verify(ctx, indexURL, archive); err != nil {
+				return err
+			}
+			index, err := apk.IndexFromArchive(io.NopCloser(bytes.NewReader(archive)))
+			if err != nil {
+				return fmt.Errorf("parsing %q: %w", indexURL, err)
+			}
 
 			wantSet := map[string]struct{}{}
 			for _, p := range args {
@@ -174,28 +183,18 @@ func cmdCp() *cobra.Command {
 			}
 			fn := filepath.Join(outDir, arch, "APKINDEX.tar.gz")
 			log.Printf("writing index: %s (%d total packages)", fn, len(index.Packages))
-			f, err := os.Create(fn)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			r, err := apk.ArchiveFromIndex(index)
+			signed, err := signing.sign(index)
 			if err != nil {
-				return err
-			}
-			if _, err := io.Copy(f, r); err != nil {
-				return err
+				return fmt.Errorf("signing index: %w", err)
 			}
-
-			// TODO: Sign index?
-
-			return nil
+			return os.WriteFile(fn, signed, 0o644)
 		},
 	}
 	cmd.Flags().StringVarP(&outDir, "out-dir", "o", "./packages", "directory to copy packages to")
 	cmd.Flags().StringVarP(&indexURL, "index", "i", "https://packages.wolfi.dev/os/x86_64/APKINDEX.tar.gz", "APKINDEX.tar.gz URL")
 	cmd.Flags().BoolVar(&latest, "latest", true, "copy only the latest version of each package")
 	cmd.Flags().StringVar(&gcsPath, "gcs", "", "copy objects from a GCS bucket")
+	signing.addFlagsTo(cmd)
 	return cmd
 }
 
@@ -274,6 +273,20 @@ func cmdApkLs() *cobra.Command {
 }
 
 func fetchAPKIndex(ctx context.Context, indexURL string) (*apk.APKIndex, string, error) {
+	archive, arch, err := fetchAPKIndexArchive(ctx, indexURL)
+	if err != nil {
+		return nil, "", err
+	}
+	index, err := apk.IndexFromArchive(io.NopCloser(bytes.NewReader(archive)))
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing %q: %w", indexURL, err)
+	}
+	return index, arch, nil
+}
+
+// fetchAPKIndexArchive returns the APKINDEX.tar.gz at indexURL, as is, and the
+// architecture of its repository.
+func fetchAPKIndexArchive(ctx context.Context, indexURL string) ([]byte, string, error) {
 	var arch string
 	repoURL := strings.TrimSuffix(indexURL, "/APKINDEX.tar.gz")
 	var in io.ReadCloser
@@ -313,11 +326,11 @@ func fetchAPKIndex(ctx context.Context, indexURL string) (*apk.APKIndex, string,
 		arch = repoURL[strings.LastIndex(repoURL, "/")+1:]
 	}
 	defer in.Close()
-	index, err := apk.IndexFromArchive(in)
+	archive, err := io.ReadAll(in)
 	if err != nil {
-		return nil, "", fmt.Errorf("parsing %q: %w", indexURL, err)
+		return nil, "", fmt.Errorf("reading %q: %w", indexURL, err)
 	}
-	return index, arch, nil
+	return archive, arch, nil
 }
 
 func onlyLatest(packages []*apk.Package) []*apk.Package {
@@ -1,36 +1,78 @@
 package cli
 
 import (
-	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"chainguard.dev/melange/pkg/config"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/changeset"
+	"github.com/wolfi-dev/wolfictl/pkg/configs"
+	buildconfigs "github.com/wolfi-dev/wolfictl/pkg/configs/build"
+	rwos "github.com/wolfi-dev/wolfictl/pkg/configs/rwfs/os"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+	"github.com/wolfi-dev/wolfictl/pkg/melange"
+	"github.com/wolfi-dev/wolfictl/pkg/yam"
+	"gopkg.in/yaml.v3"
 )
 
-const epochPattern = `epoch: %d`
-
 type bumpOptions struct {
-	repoDir string
-	epoch   bool
-	dryRun  bool
+	repoDir        string
+	epoch          bool
+	dryRun         bool
+	version        string
+	expectedCommit string
+	sources        []string
+
+	// dependentsOf are the packages whose dependents to bump, found in a
+	// graph built with graph.
+	dependentsOf []string
+	depth        int
+	types        []string
+	graph        graphParams
+
+	// pr opens a pull request of the changes, recorded in changes.
+	pr      prParams
+	changes *changeset.ChangeSet
 }
 
 func cmdBump() *cobra.Command {
 	opts := bumpOptions{}
 	cmd := &cobra.Command{
-		Use:     "bump config[.yaml] [config[.yaml]...]",
-		Short:   "Bumps the epoch field in melange configuration files",
-		Example: "wolfictl bump openssh.yaml perl lib*.yaml",
-		Long: `Bumps the epoch field in melange configuration files
+		Use:   "bump config[.yaml] [config[.yaml]...]",
+		Short: "Bumps the epoch or version in melange configuration files",
+		Example: `wolfictl bump openssh.yaml perl lib*.yaml
+wolfictl bump --version 3.3.2 openssl
+wolfictl bump --version 1.2.0 --expected-commit 5f1c8e3a... crane
+wolfictl bump --dependents-of libfoo --depth 1
+wolfictl bump --pr --dependents-of libfoo`,
+		Long: `Bumps the epoch or version in melange configuration files
 
 The bump subcommand increments version numbers in package config files.
-For now it will only bump epoch numbers but a future version will
-allow users to control versions expressed in semver.
+By default it increments the epoch of each package.
+
+With --version, the package version is set to the given version and the
+epoch is reset to 0. The expected-sha256 and expected-sha512 checksums
+of fetch steps are recomputed by downloading the files for the new
+version, or by hashing the local copies given with --source, matched by
+file name. The expected-commit of git-checkout steps of the version's
+tag is set to --expected-commit, and left alone without it.
+--version bumps one configuration file at a time.
+
+With --dependents-of, the epoch of every package depending on the given
+packages is bumped instead, e.g. to rebuild them after a soname change.
+Dependents are found in the package dependency graph, following the
+dependency types in --type up to --depth edges away, and subpackages
+are flattened into their origins. Each package is bumped once, however
+many paths lead to it, and the packages are printed in the order to
+rebuild them.
 
 wolfictl bump can take a filename, a package or a file glob, increasing
 the version in each matching configuration file:
@@ -39,63 +81,209 @@ the version in each matching configuration file:
     wolfictl bump openssl
     wolfictl bump lib*.yaml
 
+Configs are edited in place, keeping their comments, and formatted with
+the repository's yam settings from .yam.yaml.
+
 The command assumes it is being run from the top of the wolfi/os
 repository. To look for files in another location use the --repo flag.
 You can use --dry-run to see which versions will be bumped without
 modifying anything in the filesystem.
 
+With --pr, the bumped configs are committed to a new branch, which is
+pushed and a pull request is opened for it against the current branch,
+listing the packages bumped. The GitHub token is taken from
+GITHUB_TOKEN or the gh CLI, and the commit author from GIT_AUTHOR_NAME
+and GIT_AUTHOR_EMAIL or the git config. If bumping or opening the pull
+request fails, the branch that was checked out before is checked out
+again, keeping the edits in the worktree.
+
 `,
-		RunE: func(cmd *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			ctx := cmd.Context()
-			if len(args) == 0 {
-				cmd.Help() //nolint:errcheck
-				return fmt.Errorf("not enough arguments")
+			fsys := rwos.DirFS(opts.repoDir)
+			defer func() { err = opts.pr.abort(opts.changes, err) }()
+
+			if opts.pr.pr && opts.dryRun {
+				return fmt.Errorf("--pr can't be combined with --dry-run")
 			}
-			files := []string{}
-			for _, fname := range args {
-				_, err := os.Stat(filepath.Join(opts.repoDir, fname+".yaml"))
-				if err == nil {
-					files = append(files, filepath.Join(opts.repoDir, fname+".yaml"))
-					continue
-				}
 
-				if !os.IsNotExist(err) {
-					return fmt.Errorf("while checking config path %s: %w", fname, err)
+			if len(opts.dependentsOf) > 0 {
+				if len(args) > 0 || opts.version != "" {
+					return fmt.Errorf("--dependents-of can't be combined with configs to bump or --version")
 				}
-
-				m, err := filepath.Glob(filepath.Join(opts.repoDir, fname))
-				if err == nil {
-					files = append(files, m...)
-					continue
+				if opts.dryRun {
+					fmt.Fprint(os.Stderr, "dry-run: not writing data\n")
+				}
+				if opts.changes, err = opts.pr.start(opts.repoDir, "bump"); err != nil {
+					return err
 				}
-				return fmt.Errorf("unable to find config files from: %s", fname)
+				if err := bumpDependents(ctx, opts, os.Stdout); err != nil {
+					return err
+				}
+				dependencies := strings.Join(opts.dependentsOf, ", ")
+				return opts.pr.submit(ctx, opts.changes,
+					fmt.Sprintf("Bump epoch of dependents of %s", dependencies),
+					fmt.Sprintf("Rebuilds the packages depending on %s.", dependencies))
+			}
+			if len(args) == 0 {
+				cmd.Help() //nolint:errcheck
+				return fmt.Errorf("not enough arguments")
+			}
+			files, err := bumpTargets(fsys, args)
+			if err != nil {
+				return err
+			}
+			index, err := buildconfigs.NewIndexFromPaths(ctx, fsys, files...)
+			if err != nil {
+				return fmt.Errorf("unable to index configs in %s: %w", opts.repoDir, err)
+			}
+			if opts.version != "" && len(files) != 1 {
+				return fmt.Errorf("--version bumps exactly one config, found %d", len(files))
 			}
 
 			if opts.dryRun {
 				fmt.Fprint(os.Stderr, "dry-run: not writing data\n")
 			}
+			if opts.changes, err = opts.pr.start(opts.repoDir, "bump"); err != nil {
+				return err
+			}
+
+			if opts.version != "" {
+				if err := bumpVersion(ctx, opts, filepath.Join(opts.repoDir, files[0]), os.Stdout); err != nil {
+					return err
+				}
+				if opts.changes == nil {
+					return nil
+				}
+				e, err := index.Select().WhereFilePath(files[0]).First()
+				if err != nil {
+					return err
+				}
+				name := e.Configuration().Package.Name
+				if err := opts.changes.Add(name, files[0]); err != nil {
+					return err
+				}
+				return opts.pr.submit(ctx, opts.changes,
+					fmt.Sprintf("%s/%s package update", name, opts.version),
+					fmt.Sprintf("Updates %s to version %s.", name, opts.version))
+			}
 
 			for _, f := range files {
-				if err := bumpEpoch(ctx, opts, f); err != nil {
+				if err := bumpEpoch(ctx, opts, index, f); err != nil {
 					return err
 				}
 			}
-			return nil
+			if opts.changes == nil {
+				return nil
+			}
+			return opts.pr.submit(ctx, opts.changes, epochTitle(opts.changes.Packages()), "Bumps the epoch to rebuild the packages.")
 		},
 	}
 
 	cmd.Flags().BoolVar(&opts.epoch, "epoch", true, "bump the package epoch")
 	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "don't change anything, just print what would be done")
 	cmd.Flags().StringVar(&opts.repoDir, "repo", ".", "path to the wolfi/os repository")
+	cmd.Flags().StringVar(&opts.version, "version", "", "bump the package to this version, resetting the epoch")
+	cmd.Flags().StringVar(&opts.expectedCommit, "expected-commit", "", "with --version, the expected commit of the git-checkout of the new version")
+	cmd.Flags().StringSliceVar(&opts.sources, "source", nil, "with --version, local copy of a file fetched by the package to hash instead of downloading it")
+	cmd.Flags().StringSliceVar(&opts.dependentsOf, "dependents-of", nil, "bump the epoch of the packages depending on these packages")
+	cmd.Flags().IntVar(&opts.depth, "depth", 0, "with --dependents-of, maximum number of edges between a package and its bumped dependents (0 for no limit, 1 for direct dependents)")
+	cmd.Flags().StringSliceVarP(&opts.types, "type", "t", []string{string(dag.EdgeBuildtime), string(dag.EdgeRuntime)}, "with --dependents-of, types of dependencies to follow: buildtime, runtime, test")
+	opts.graph.addResolveFlagsTo(cmd)
+	opts.pr.addFlagsTo(cmd)
 
 	return cmd
 }
 
-func bumpEpoch(ctx context.Context, opts bumpOptions, path string) error {
-	cfg, err := config.ParseConfiguration(ctx, path)
+// epochTitle returns the title of a pull request bumping the epochs of pkgs.
+func epochTitle(pkgs []string) string {
+	if len(pkgs) == 1 {
+		return fmt.Sprintf("Bump epoch of %s", pkgs[0])
+	}
+	return fmt.Sprintf("Bump epoch of %d packages", len(pkgs))
+}
+
+// epochUpdater increments the epoch of a package. A trailing CVE or GHSA
+// comment on the epoch records why the previous epoch was bumped, so it's
+// dropped.
+var epochUpdater = configs.NewYAMLUpdateFunc[config.Configuration](func(cfg config.Configuration, node *yaml.Node) error {
+	bump := configs.NewTargetedYAMLASTMutater[config.Package, config.Configuration](
+		"package",
+		func(cfg config.Configuration) (config.Package, error) {
+			p := cfg.Package
+			p.Epoch++
+			return p, nil
+		},
+		func(cfg config.Configuration, p config.Package) config.Configuration {
+			cfg.Package = p
+			return cfg
+		},
+	)
+	if err := bump(cfg, node); err != nil {
+		return err
+	}
+
+	epoch := mappingValue(mappingValue(node.Content[0], "package"), "epoch")
+	if epoch == nil {
+		return fmt.Errorf("unable to find epoch in package section")
+	}
+	comment := strings.TrimSpace(strings.TrimPrefix(epoch.LineComment, "#"))
+	if strings.HasPrefix(comment, "CVE-") || strings.HasPrefix(comment, "GHSA-") {
+		epoch.LineComment = ""
+	}
+	return nil
+})
+
+// mappingValue returns the value of key in the YAML mapping node, or nil if
+// there's none.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// bumpTargets returns the paths of the configs in fsys matching args, each a
+// package name, a config file name or a glob of config file names.
+func bumpTargets(fsys fs.FS, args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		matches := []string{arg + ".yaml"}
+		if _, err := fs.Stat(fsys, matches[0]); err != nil {
+			if matches, err = fs.Glob(fsys, arg); err != nil {
+				return nil, fmt.Errorf("invalid config pattern %s: %w", arg, err)
+			}
+		}
+		found := false
+		for _, m := range matches {
+			if filepath.Ext(m) != ".yaml" {
+				continue
+			}
+			found = true
+			if !slices.Contains(paths, m) {
+				paths = append(paths, m)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unable to find config files from: %s", arg)
+		}
+	}
+	return paths, nil
+}
+
+// bumpEpoch increments the epoch of the config at path in index.
+func bumpEpoch(ctx context.Context, opts bumpOptions, index *configs.Index[config.Configuration], path string) error {
+	s := index.Select().WhereFilePath(path)
+	e, err := s.First()
 	if err != nil {
-		return fmt.Errorf("unable to parse configuration at %q: %w", path, err)
+		return fmt.Errorf("unable to find config %s: %w", path, err)
 	}
+	cfg := e.Configuration()
 
 	fmt.Fprintf(
 		os.Stderr, "bumping %s-%s-%d in %s to epoch %d\n", cfg.Package.Name,
@@ -106,43 +294,103 @@ func bumpEpoch(ctx context.Context, opts bumpOptions, path string) error {
 		return nil
 	}
 
-	original, err := os.Open(path)
+	if err := s.Update(ctx, epochUpdater); err != nil {
+		return fmt.Errorf("bumping epoch in %s: %w", path, err)
+	}
+	if opts.changes != nil {
+		return opts.changes.Add(cfg.Package.Name, path)
+	}
+	return nil
+}
+
+// bumpVersion bumps the config at path to opts.version. With opts.dryRun, the
+// changes are only printed to w as a diff.
+func bumpVersion(ctx context.Context, opts bumpOptions, path string, w io.Writer) error {
+	before, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("opening config file: %w", err)
-	}
-
-	scanner := bufio.NewScanner(original)
-	scanner.Split(bufio.ScanLines)
-	newFile := []string{}
-	found := false
-	old := fmt.Sprintf(epochPattern, cfg.Package.Epoch)
-	for scanner.Scan() {
-		line := scanner.Text()
-		nocomment, comment, _ := strings.Cut(line, "#")
-		if strings.TrimSpace(nocomment) == old {
-			found = true
-			comment = strings.TrimSpace(comment)
-			if strings.HasPrefix(comment, "CVE-") || strings.HasPrefix(comment, "GHSA-") {
-				line = strings.TrimRight(nocomment, " ")
-			}
-			newFile = append(
-				newFile, strings.ReplaceAll(line, old, fmt.Sprintf(epochPattern, cfg.Package.Epoch+1)),
-			)
-		} else {
-			newFile = append(newFile, line)
+		return err
+	}
+	encodeOptions, err := yam.TryReadingEncodeOptions(opts.repoDir)
+	if err != nil {
+		return err
+	}
+	after, err := melange.BumpVersion(ctx, path, melange.BumpOptions{
+		Version:        opts.version,
+		ExpectedCommit: opts.expectedCommit,
+		Sources:        opts.sources,
+		EncodeOptions:  encodeOptions,
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(strings.TrimSuffix(string(before), "\n")),
+			B:        difflib.SplitLines(strings.TrimSuffix(string(after), "\n")),
+			FromFile: path,
+			ToFile:   path,
+			Context:  3,
+		})
+		if err != nil {
+			return err
 		}
+		_, err = io.WriteString(w, diff)
+		return err
 	}
-	original.Close()
 
-	if !found {
-		return fmt.Errorf("unable to find epoch tag in yaml config")
+	fmt.Fprintf(os.Stderr, "bumping %s to version %s\n", path, opts.version)
+	return os.WriteFile(path, after, 0o644)
+}
+
+// bumpDependents bumps the epoch of the packages depending on
+// opts.dependentsOf, printing them to w in the order to rebuild them.
+func bumpDependents(ctx context.Context, opts bumpOptions, w io.Writer) error {
+	types, err := dag.ParseEdgeTypes(opts.types)
+	if err != nil {
+		return err
 	}
+	opts.graph.dir = opts.repoDir
+	opts.graph.runtimeDeps = slices.Contains(types, dag.EdgeRuntime)
+	opts.graph.testDeps = slices.Contains(types, dag.EdgeTest)
 
-	if err := os.WriteFile(
-		path, []byte(strings.Join(newFile, "\n")+"\n"), os.FileMode(0o644),
-	); err != nil {
-		return fmt.Errorf("writing %s: %w", path, err)
+	pkgs, g, err := opts.graph.build(ctx)
+	if err != nil {
+		return err
+	}
+	g, err = g.Targets()
+	if err != nil {
+		return fmt.Errorf("targets: %w", err)
+	}
+	sorted, err := rebuildOrder(g, pkgs, opts.dependentsOf, opts.depth, types)
+	if err != nil {
+		return err
 	}
 
+	// Only the configs to bump are indexed, the graph already parsed them
+	// all.
+	var names, paths []string
+	for _, pkg := range sorted {
+		c, ok := pkg.(*dag.Configuration)
+		if !ok {
+			continue
+		}
+		p, err := filepath.Rel(opts.repoDir, c.Path)
+		if err != nil {
+			return err
+		}
+		names, paths = append(names, c.Name()), append(paths, p)
+	}
+	index, err := buildconfigs.NewIndexFromPaths(ctx, rwos.DirFS(opts.repoDir), paths...)
+	if err != nil {
+		return fmt.Errorf("unable to index configs in %s: %w", opts.repoDir, err)
+	}
+
+	for i, p := range paths {
+		if err := bumpEpoch(ctx, opts, index, p); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, names[i])
+	}
 	return nil
 }
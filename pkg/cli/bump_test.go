@@ -1,17 +1,20 @@
 package cli
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	buildconfigs "github.com/wolfi-dev/wolfictl/pkg/configs/build"
+	rwos "github.com/wolfi-dev/wolfictl/pkg/configs/rwfs/os"
+	"github.com/wolfi-dev/wolfictl/pkg/configs/rwfs/os/testerfs"
 )
 
 func testPkgDefinition(epoch string) []byte {
-	pkgTemplate := `
-package:
+	pkgTemplate := `package:
   name: awesome-tool
   version: 0.61.0
   epoch: EPOCH_HERE
@@ -37,13 +40,18 @@ func TestBumpWithComment(t *testing.T) {
 			testPkgDefinition("2"),
 		},
 	} {
-		name := filepath.Join(t.TempDir(), "awesome-tool.yaml")
+		dir := t.TempDir()
+		name := filepath.Join(dir, "awesome-tool.yaml")
 
 		if err := os.WriteFile(name, td.before, 0o644); err != nil {
 			t.Fatal(err)
 		}
 
-		if err := bumpEpoch(t.Context(), bumpOptions{}, name); err != nil {
+		index, err := buildconfigs.NewIndexFromPaths(t.Context(), rwos.DirFS(dir), "awesome-tool.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bumpEpoch(t.Context(), bumpOptions{}, index, "awesome-tool.yaml"); err != nil {
 			t.Fatal(err)
 		}
 
@@ -56,3 +64,236 @@ func TestBumpWithComment(t *testing.T) {
 		}
 	}
 }
+
+func TestBumpIgnoresOtherConfigs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "awesome-tool.yaml"), testPkgDefinition("1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A config that doesn't parse elsewhere in the repository doesn't keep
+	// others from being bumped.
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("package: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := rwos.DirFS(dir)
+	paths, err := bumpTargets(fsys, []string{"awesome-*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := buildconfigs.NewIndexFromPaths(t.Context(), fsys, paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bumpEpoch(t.Context(), bumpOptions{}, index, "awesome-tool.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "awesome-tool.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(testPkgDefinition("2"), got); diff != "" {
+		t.Errorf("bumpEpoch() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBumpEpochIndex(t *testing.T) {
+	fsys, err := testerfs.New(os.DirFS("testdata/bump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := bumpTargets(fsys, []string{"hello", "fl*.yaml", "hello.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"hello.yaml", "flow.yaml"}, paths); diff != "" {
+		t.Errorf("bumpTargets() mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := bumpTargets(fsys, []string{"missing"}); err == nil {
+		t.Error("bumpTargets() of a missing config didn't fail")
+	}
+
+	// Only the targets are indexed.
+	index, err := buildconfigs.NewIndexFromPaths(t.Context(), fsys, paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range paths {
+		if err := bumpEpoch(t.Context(), bumpOptions{}, index, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if diff := fsys.DiffAll(); diff != "" {
+		t.Errorf("unexpected file modification results (-want, +got):\n%s", diff)
+	}
+}
+
+func TestBumpVersionDryRun(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "awesome-tool.yaml")
+	before := []byte(`package:
+  name: awesome-tool
+  version: 0.61.0
+  epoch: 4
+pipeline:
+  - uses: fetch
+    with:
+      uri: https://example.invalid/awesome-tool-${{package.version}}.tar.gz
+      expected-sha256: old
+`)
+	if err := os.WriteFile(name, before, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "awesome-tool-0.62.0.tar.gz")
+	if err := os.WriteFile(src, []byte("awesome"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	opts := bumpOptions{repoDir: dir, dryRun: true, version: "0.62.0", sources: []string{src}}
+	if err := bumpVersion(t.Context(), opts, name, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `--- ` + name + `
++++ ` + name + `
+@@ -1,9 +1,9 @@
+ package:
+   name: awesome-tool
+-  version: 0.61.0
+-  epoch: 4
++  version: "0.62.0"
++  epoch: 0
+ pipeline:
+   - uses: fetch
+     with:
+       uri: https://example.invalid/awesome-tool-${{package.version}}.tar.gz
+-      expected-sha256: old
++      expected-sha256: 705db0603fd5431451dab1171b964b4bd575e2230f40f4c300d70df6e65f5f1c
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("bumpVersion() dry-run mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(before, got); diff != "" {
+		t.Errorf("bumpVersion() changed the config in a dry-run (-want +got):\n%s", diff)
+	}
+}
+
+func TestBumpDependents(t *testing.T) {
+	configs := map[string]string{
+		"libfoo": `package:
+  name: libfoo
+  version: 1.0.0
+  epoch: 0
+subpackages:
+  - name: libfoo-dev
+pipeline:
+  - runs: echo libfoo
+`,
+		"app": `package:
+  name: app
+  version: 2.0.0
+  epoch: 1
+environment:
+  contents:
+    packages:
+      - libfoo-dev
+pipeline:
+  - runs: echo app
+`,
+		// tool is reached both directly and through app.
+		"tool": `package:
+  name: tool
+  version: 3.0.0
+  epoch: 5
+  dependencies:
+    runtime:
+      - libfoo
+environment:
+  contents:
+    packages:
+      - app
+pipeline:
+  - runs: echo tool
+`,
+		"other": `package:
+  name: other
+  version: 1.0.0
+  epoch: 0
+pipeline:
+  - runs: echo other
+`,
+	}
+
+	for _, td := range []struct {
+		name   string
+		depth  int
+		types  []string
+		dryRun bool
+		want   string
+		epochs map[string]string
+	}{
+		{
+			name:   "all dependents",
+			types:  []string{"buildtime", "runtime"},
+			want:   "app\ntool\n",
+			epochs: map[string]string{"libfoo": "0", "app": "2", "tool": "6", "other": "0"},
+		},
+		{
+			name:   "buildtime only",
+			depth:  1,
+			types:  []string{"buildtime"},
+			want:   "app\n",
+			epochs: map[string]string{"libfoo": "0", "app": "2", "tool": "5", "other": "0"},
+		},
+		{
+			name:   "dry run",
+			types:  []string{"buildtime", "runtime"},
+			dryRun: true,
+			want:   "app\ntool\n",
+			epochs: map[string]string{"libfoo": "0", "app": "1", "tool": "5", "other": "0"},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, cfg := range configs {
+				if err := os.WriteFile(filepath.Join(dir, name+".yaml"), []byte(cfg), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			opts := bumpOptions{
+				repoDir:      dir,
+				dryRun:       td.dryRun,
+				dependentsOf: []string{"libfoo"},
+				depth:        td.depth,
+				types:        td.types,
+				graph:        graphParams{arch: "x86_64"},
+			}
+			if err := bumpDependents(t.Context(), opts, &buf); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(td.want, buf.String()); diff != "" {
+				t.Errorf("bumpDependents() rebuild order mismatch (-want +got):\n%s", diff)
+			}
+
+			for name, epoch := range td.epochs {
+				b, err := os.ReadFile(filepath.Join(dir, name+".yaml"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(b), "epoch: "+epoch+"\n") {
+					t.Errorf("%s: want epoch %s, got:\n%s", name, epoch, b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+
+	"github.com/wolfi-dev/wolfictl/pkg/changeset"
+	"github.com/wolfi-dev/wolfictl/pkg/gh"
+)
+
+// prParams are the flags of commands editing the configs of a repository to
+// commit their edits to a new branch, push it and open a pull request for it
+// instead of leaving them in the worktree.
+type prParams struct {
+	pr     bool
+	branch string
+	base   string
+	remote string
+}
+
+func (p *prParams) addFlagsTo(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&p.pr, "pr", false, "commit the changes to a new branch, push it and open a pull request")
+	cmd.Flags().StringVar(&p.branch, "pr-branch", "", "with --pr, branch to create (default wolfictl-<command>-<timestamp>)")
+	cmd.Flags().StringVar(&p.base, "pr-base", "", "with --pr, branch to open the pull request against (default the current branch)")
+	cmd.Flags().StringVar(&p.remote, "pr-remote", "origin", "with --pr, remote to push the branch to and open the pull request on")
+}
+
+// start creates and checks out the branch of the change set of command in the
+// repository at dir, before any edits. Without --pr, it returns nil. If the
+// edits or their submission fail, abort checks out the previous branch again.
+func (p prParams) start(dir, command string) (*changeset.ChangeSet, error) {
+	if !p.pr {
+		return nil, nil
+	}
+	branch := p.branch
+	if branch == "" {
+		branch = fmt.Sprintf("wolfictl-%s-%d", command, time.Now().Unix())
+	}
+	return changeset.New(dir, changeset.Options{
+		Branch: branch,
+		Base:   p.base,
+		Remote: p.remote,
+	})
+}
+
+// abort checks out the branch that was checked out before cs was started again
+// if err is set, so that a failed command doesn't leave the repository on the
+// branch of the change set. It returns err, and does nothing if cs is nil.
+func (p prParams) abort(cs *changeset.ChangeSet, err error) error {
+	if cs == nil || err == nil {
+		return err
+	}
+	if aerr := cs.Abort(); aerr != nil {
+		return errors.Join(err, aerr)
+	}
+	return err
+}
+
+// submit commits cs, pushes it and opens a pull request for it on GitHub. It
+// does nothing if cs is nil.
+func (p prParams) submit(ctx context.Context, cs *changeset.ChangeSet, title, description string) error {
+	if cs == nil {
+		return nil
+	}
+	gitOpts := gh.GitOptions{
+		GithubClient: github.NewClient(oauth2.NewClient(ctx, ghTokenSource{})),
+		Logger:       log.New(log.Writer(), "wolfictl: ", log.LstdFlags|log.Lmsgprefix),
+	}
+	pr, err := cs.Submit(ctx, gitOpts, title, description)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "opened pull request %s\n", pr.GetHTMLURL())
+	return nil
+}
@@ -29,13 +29,17 @@ func New() *cobra.Command {
 		cmdBump(),
 		cmdCheck(),
 		cmdGh(),
+		cmdGraph(),
 		cmdImage(),
 		cmdLint(),
 		cmdRuby(),
 		cmdLs(),
+		cmdRdeps(),
+		cmdServe(),
 		cmdSVG(),
 		cmdText(),
 		cmdVEX(),
+		cmdWhy(),
 		cmdWithdraw(),
 		version.Version(),
 	)
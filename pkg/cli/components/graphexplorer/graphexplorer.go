@@ -0,0 +1,668 @@
+package graphexplorer
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wolfi-dev/wolfictl/pkg/cli/components/ctrlcwrapper"
+	"github.com/wolfi-dev/wolfictl/pkg/cli/components/picker"
+	"github.com/wolfi-dev/wolfictl/pkg/cli/components/textinput"
+	"github.com/wolfi-dev/wolfictl/pkg/cli/components/tree"
+	"github.com/wolfi-dev/wolfictl/pkg/cli/styles"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+// maxSearchResults is the most packages listed for a search, to keep the list
+// on screen.
+const maxSearchResults = 20
+
+// Model is a terminal UI to explore the dependencies and dependents of a
+// package in a graph, expanding and collapsing them as a tree.
+type Model struct {
+	views      [2]*view
+	targets    bool
+	dependents bool
+
+	root     string
+	expanded map[string]bool
+	rows     [][]string
+	selected int
+	offset   int
+	height   int
+
+	mode     mode
+	search   textinput.Model
+	results  picker.Model[string]
+	editor   string
+	status   string
+	quitting bool
+
+	// Error is the error that occurred during the explorer's lifecycle, if any.
+	Error error
+}
+
+type mode int
+
+const (
+	modeBrowse mode = iota
+	modeSearch
+	modePick
+)
+
+// view is one way to look at the graph, either with subpackages or with them
+// flattened into their origins.
+type view struct {
+	graph *dag.Graph
+
+	// deps and rdeps are the sorted dependencies and dependents of each node.
+	deps, rdeps map[string][]string
+}
+
+func newView(g *dag.Graph) (*view, error) {
+	amap, err := g.Graph.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	pmap, err := g.Graph.PredecessorMap()
+	if err != nil {
+		return nil, err
+	}
+	v := &view{graph: g, deps: map[string][]string{}, rdeps: map[string][]string{}}
+	for node, edges := range amap {
+		for target := range edges {
+			v.deps[node] = append(v.deps[node], target)
+		}
+		sort.Strings(v.deps[node])
+	}
+	for node, edges := range pmap {
+		for source := range edges {
+			v.rdeps[node] = append(v.rdeps[node], source)
+		}
+		sort.Strings(v.rdeps[node])
+	}
+	return v, nil
+}
+
+// Options are the options to create an explorer.
+type Options struct {
+	// Graph is the graph to explore, including subpackages. The explorer can
+	// switch to its Targets view, with subpackages flattened into their
+	// origins.
+	Graph *dag.Graph
+
+	// Root is the name of the package to start exploring from.
+	Root string
+
+	// Targets starts the explorer in the Targets view.
+	Targets bool
+
+	// Dependents starts the explorer showing dependents rather than
+	// dependencies.
+	Dependents bool
+
+	// Editor is the command used to open config files. Jumping to configs is
+	// disabled if it's empty.
+	Editor string
+}
+
+// New returns a new explorer of the graph, rooted at the given package.
+func New(opts Options) (Model, error) {
+	if opts.Graph == nil {
+		return Model{}, errors.New("a graph is required")
+	}
+	full, err := newView(opts.Graph)
+	if err != nil {
+		return Model{}, err
+	}
+	targets, err := opts.Graph.Targets()
+	if err != nil {
+		return Model{}, fmt.Errorf("flattening subpackages: %w", err)
+	}
+	flat, err := newView(targets)
+	if err != nil {
+		return Model{}, err
+	}
+
+	m := Model{
+		views:      [2]*view{full, flat},
+		targets:    opts.Targets,
+		dependents: opts.Dependents,
+		editor:     opts.Editor,
+		search:     textinput.New(),
+	}
+	m.search.Inner.Prompt = "/"
+	root, err := m.lookup(opts.Root)
+	if err != nil {
+		return Model{}, err
+	}
+	m.setRoot(root)
+	return m, nil
+}
+
+func (m Model) view() *view {
+	if m.targets {
+		return m.views[1]
+	}
+	return m.views[0]
+}
+
+// lookup returns the node of the named package in the current view, falling
+// back to its origin if it's a subpackage flattened into it.
+func (m Model) lookup(name string) (string, error) {
+	g := m.view().graph
+	found, err := g.NodesByName(name)
+	if err != nil {
+		return "", err
+	}
+	if len(found) == 0 {
+		for _, v := range m.views {
+			candidates, err := v.graph.NodesByName(name)
+			if err != nil {
+				return "", err
+			}
+			for _, p := range candidates {
+				if c, ok := p.(*dag.Configuration); ok && c.Package.Name != name {
+					if found, err = g.NodesByName(c.Package.Name); err != nil {
+						return "", err
+					}
+				}
+			}
+			if len(found) > 0 {
+				break
+			}
+		}
+	}
+	if len(found) == 0 {
+		return "", fmt.Errorf("could not find package %q", name)
+	}
+	// The latest version, if there are several.
+	sort.SliceStable(found, func(i, j int) bool { return newer(found[i], found[j]) })
+	return dag.PackageHash(found[0]), nil
+}
+
+// newer reports whether a is to be preferred to b as the latest version of a
+// package: it has a higher version, or the same version but a is local and b
+// isn't.
+func newer(a, b dag.Package) bool {
+	va, errA := apk.ParseVersion(a.Version())
+	vb, errB := apk.ParseVersion(b.Version())
+	if errA == nil && errB == nil {
+		if c := apk.CompareVersions(va, vb); c != 0 {
+			return c > 0
+		}
+	} else if a.Version() != b.Version() {
+		return a.Version() > b.Version()
+	}
+	return a.Source() == dag.Local && b.Source() != dag.Local
+}
+
+// setRoot starts exploring from node, with only its own edges expanded.
+func (m *Model) setRoot(node string) {
+	m.root = node
+	m.expanded = map[string]bool{node: true}
+	m.selected = 0
+	m.offset = 0
+	m.layout()
+}
+
+// layout computes the visible rows, each the path of nodes from the root.
+func (m *Model) layout() {
+	edges := m.view().deps
+	if m.dependents {
+		edges = m.view().rdeps
+	}
+
+	m.rows = nil
+	var walk func(path []string)
+	walk = func(path []string) {
+		m.rows = append(m.rows, path)
+		if !m.expanded[pathKey(path)] {
+			return
+		}
+		for _, next := range edges[path[len(path)-1]] {
+			if contains(path, next) {
+				continue
+			}
+			child := make([]string, len(path), len(path)+1)
+			copy(child, path)
+			walk(append(child, next))
+		}
+	}
+	walk([]string{m.root})
+
+	if m.selected >= len(m.rows) {
+		m.selected = len(m.rows) - 1
+	}
+}
+
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+func contains(path []string, node string) bool {
+	for _, n := range path {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Model) current() []string {
+	return m.rows[m.selected]
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+type editorFinishedMsg struct {
+	err error
+}
+
+// statusMsg is a message to show the user below the tree.
+type statusMsg string
+
+func statusCmd(format string, a ...any) tea.Cmd {
+	return func() tea.Msg {
+		return statusMsg(fmt.Sprintf(format, a...))
+	}
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ctrlcwrapper.AboutToExitMsg:
+		m.quitting = true
+		return m, ctrlcwrapper.InnerIsReady
+
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.scroll()
+		return m, nil
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("editor failed: %v", msg.err)
+		}
+		return m, nil
+
+	case statusMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case picker.ErrMsg:
+		m.Error = msg
+		return m, tea.Quit
+
+	case tea.KeyMsg:
+		switch m.mode {
+		case modeSearch:
+			return m.updateSearch(msg)
+		case modePick:
+			return m.updatePick(msg)
+		}
+		return m.updateBrowse(msg)
+	}
+
+	return m, nil
+}
+
+func (m Model) updateBrowse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.status = ""
+	switch msg.String() {
+	case "q":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+		}
+
+	case "down", "j":
+		if m.selected < len(m.rows)-1 {
+			m.selected++
+		}
+
+	case "right", "l":
+		m.expanded[pathKey(m.current())] = true
+		m.layout()
+
+	case "left", "h":
+		path := m.current()
+		if m.expanded[pathKey(path)] && len(m.view().edges(m.dependents, path[len(path)-1])) > 0 {
+			delete(m.expanded, pathKey(path))
+			m.layout()
+			break
+		}
+		// Already collapsed, so go to the parent instead.
+		for i := m.selected - 1; i >= 0; i-- {
+			if len(m.rows[i]) == len(path)-1 {
+				m.selected = i
+				break
+			}
+		}
+
+	case "enter", " ":
+		key := pathKey(m.current())
+		if m.expanded[key] {
+			delete(m.expanded, key)
+		} else {
+			m.expanded[key] = true
+		}
+		m.layout()
+
+	case "f":
+		path := m.current()
+		m.setRoot(path[len(path)-1])
+
+	case "r":
+		m.dependents = !m.dependents
+		m.setRoot(m.root)
+
+	case "t":
+		path := m.current()
+		name := m.packageName(path[len(path)-1])
+		m.targets = !m.targets
+		root, err := m.lookup(name)
+		if err != nil {
+			// It isn't in the other view, e.g. an external package only a
+			// subpackage depended on.
+			if root, err = m.lookup(m.packageName(m.root)); err != nil {
+				m.targets = !m.targets
+				m.status = err.Error()
+				break
+			}
+		}
+		m.setRoot(root)
+
+	case "/":
+		m.mode = modeSearch
+		m.search.Inner.SetValue("")
+		return m, m.search.Init()
+
+	case "e":
+		return m, m.openConfig()
+	}
+
+	m.scroll()
+	return m, nil
+}
+
+func (v *view) edges(dependents bool, node string) []string {
+	if dependents {
+		return v.rdeps[node]
+	}
+	return v.deps[node]
+}
+
+func (m Model) packageName(node string) string {
+	p, err := m.view().graph.Graph.Vertex(node)
+	if err != nil {
+		return node
+	}
+	return p.Name()
+}
+
+func (m Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = modeBrowse
+		return m, nil
+
+	case "enter":
+		query := strings.TrimSpace(m.search.Inner.Value())
+		matches := m.find(query)
+		if len(matches) == 0 {
+			m.mode = modeBrowse
+			m.status = fmt.Sprintf("no package matches %q", query)
+			return m, nil
+		}
+		if len(matches) > maxSearchResults {
+			m.status = fmt.Sprintf("showing %d of %d matches, refine the search to see more", maxSearchResults, len(matches))
+			matches = matches[:maxSearchResults]
+		}
+		m.results = picker.New(picker.Options[string]{
+			Items:          matches,
+			ItemRenderFunc: m.label,
+		})
+		m.mode = modePick
+		return m, nil
+	}
+
+	updated, cmd := m.search.Update(msg)
+	m.search = updated.(textinput.Model)
+	return m, cmd
+}
+
+func (m Model) updatePick(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "esc" {
+		m.mode = modeBrowse
+		m.status = ""
+		return m, nil
+	}
+
+	updated, cmd := m.results.Update(msg)
+	m.results = updated.(picker.Model[string])
+	if picked := m.results.Picked(); picked != nil {
+		// The picker quits once something is picked, but the explorer goes on.
+		m.mode = modeBrowse
+		m.status = ""
+		m.setRoot(*picked)
+		return m, nil
+	}
+	return m, cmd
+}
+
+// find returns the nodes of the current view whose name contains query, those
+// whose name starts with it first.
+func (m Model) find(query string) []string {
+	var prefixed, others []string
+	for node := range m.view().deps {
+		name := m.packageName(node)
+		switch {
+		case strings.HasPrefix(name, query):
+			prefixed = append(prefixed, node)
+		case strings.Contains(name, query):
+			others = append(others, node)
+		}
+	}
+	sort.Strings(prefixed)
+	sort.Strings(others)
+	return append(prefixed, others...)
+}
+
+func (m Model) openConfig() tea.Cmd {
+	path := m.current()
+	p, err := m.view().graph.Graph.Vertex(path[len(path)-1])
+	if err != nil {
+		return picker.ErrCmd(err)
+	}
+	c, ok := p.(*dag.Configuration)
+	if !ok {
+		return statusCmd("%s is not defined by a local config", p.Name())
+	}
+	if m.editor == "" {
+		return statusCmd("no editor to open %s with, set $EDITOR", c.Path)
+	}
+	args := strings.Fields(m.editor)
+	cmd := exec.Command(args[0], append(args[1:], c.Path)...) //nolint:gosec // the editor is chosen by the user
+	return tea.ExecProcess(cmd, func(err error) tea.Msg { return editorFinishedMsg{err: err} })
+}
+
+// scroll keeps the selected row on screen.
+func (m *Model) scroll() {
+	visible := m.visibleRows()
+	if visible <= 0 {
+		return
+	}
+	if m.selected < m.offset {
+		m.offset = m.selected
+	}
+	if m.selected >= m.offset+visible {
+		m.offset = m.selected - visible + 1
+	}
+}
+
+// visibleRows is the number of rows of the tree that fit on screen, or zero if
+// the screen size isn't known.
+func (m Model) visibleRows() int {
+	if m.height == 0 {
+		return 0
+	}
+	// Leave room for the header, the details and help.
+	if rows := m.height - 6; rows > 1 {
+		return rows
+	}
+	return 1
+}
+
+// label renders a node with its version and source.
+func (m Model) label(node string) string {
+	p, err := m.view().graph.Graph.Vertex(node)
+	if err != nil {
+		return node
+	}
+	name := p.Name()
+	if p.Version() != "" {
+		name += "-" + p.Version()
+	}
+	return fmt.Sprintf("%s %s", name, styleSource.Render(source(p)))
+}
+
+func source(p dag.Package) string {
+	switch {
+	case !p.Resolved():
+		return "(unresolved)"
+	case p.Source() == dag.Local:
+		return "(local)"
+	default:
+		return "(" + p.Source() + ")"
+	}
+}
+
+// rowLabel renders a row, with the types of the edge from its parent.
+func (m Model) rowLabel(path []string) string {
+	node := path[len(path)-1]
+	label := m.label(node)
+	if len(path) > 1 {
+		from, to := path[len(path)-2], node
+		if m.dependents {
+			from, to = to, from
+		}
+		if types, err := m.view().graph.EdgeTypes(from, to); err == nil && len(types) > 0 {
+			names := make([]string, 0, len(types))
+			for _, t := range types {
+				names = append(names, string(t))
+			}
+			label += " " + styleEdge.Render("["+strings.Join(names, ",")+"]")
+		}
+	}
+	if len(m.view().edges(m.dependents, node)) > 0 && !m.expanded[pathKey(path)] {
+		label += styleEdge.Render(" …")
+	}
+	return label
+}
+
+func (m Model) View() string {
+	if m.quitting {
+		return ""
+	}
+
+	sb := new(strings.Builder)
+
+	viewName := "subpackages"
+	if m.targets {
+		viewName = "targets"
+	}
+	direction := "dependencies"
+	if m.dependents {
+		direction = "dependents"
+	}
+	fmt.Fprintf(sb, "%s\n\n", styleHeader.Render(fmt.Sprintf("%s of %s (%s view)", direction, m.packageName(m.root), viewName)))
+
+	switch m.mode {
+	case modePick:
+		sb.WriteString(m.results.View())
+		sb.WriteString(styleHelp.Render("esc to go back.") + "\n")
+		if m.status != "" {
+			sb.WriteString(styleStatus.Render(m.status) + "\n")
+		}
+		return sb.String()
+	case modeSearch:
+		sb.WriteString(m.search.View() + "\n\n")
+		sb.WriteString(styleHelp.Render("enter to search, esc to go back.") + "\n")
+		return sb.String()
+	}
+
+	// Each row is a leaf of the tree, so that rows and lines line up.
+	t, err := tree.New(m.rows, func(path []string) []string {
+		parts := make([]string, 0, len(path))
+		for i := range path {
+			parts = append(parts, m.rowLabel(path[:i+1]))
+		}
+		return parts
+	})
+	if err != nil {
+		return err.Error()
+	}
+	lines := strings.Split(strings.TrimSuffix(t.Render(), "\n"), "\n")
+
+	start, end := 0, len(lines)
+	if visible := m.visibleRows(); visible > 0 && end-start > visible {
+		start = m.offset
+		if end > start+visible {
+			end = start + visible
+		}
+	}
+	for i := start; i < end; i++ {
+		if i == m.selected {
+			fmt.Fprintf(sb, "%s %s\n", styleCursor.Render(">"), lines[i])
+			continue
+		}
+		fmt.Fprintf(sb, "  %s\n", lines[i])
+	}
+
+	sb.WriteString("\n" + m.details() + "\n")
+	if m.status != "" {
+		sb.WriteString(styleStatus.Render(m.status) + "\n")
+	}
+	sb.WriteString(styleHelp.Render(helpBrowse) + "\n")
+	return sb.String()
+}
+
+// details describes the selected package.
+func (m Model) details() string {
+	path := m.current()
+	p, err := m.view().graph.Graph.Vertex(path[len(path)-1])
+	if err != nil {
+		return err.Error()
+	}
+	switch c := p.(type) {
+	case *dag.Configuration:
+		if c.Name() != c.Package.Name {
+			return fmt.Sprintf("%s is built by %s, defined in %s", c.Name(), c.Package.Name, c.Path)
+		}
+		return fmt.Sprintf("%s is defined in %s", c.Name(), c.Path)
+	}
+	if !p.Resolved() {
+		return fmt.Sprintf("%s could not be resolved to any package", p.Name())
+	}
+	return fmt.Sprintf("%s is from %s", p.Name(), p.Source())
+}
+
+const helpBrowse = "↑/↓ to move, →/← to expand/collapse, f to focus, r to show dependents/dependencies, t to toggle subpackages, / to search, e to edit the config, q to quit."
+
+var (
+	styleHeader = styles.Bold()
+	styleCursor = styles.Accented().Bold(true)
+	styleSource = styles.Secondary()
+	styleEdge   = styles.Faint()
+	styleStatus = styles.Italic()
+	styleHelp   = styles.Faint()
+)
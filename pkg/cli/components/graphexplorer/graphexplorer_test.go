@@ -0,0 +1,142 @@
+package graphexplorer
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func testGraph(t *testing.T) *dag.Graph {
+	t.Helper()
+	ctx := context.Background()
+	dir := "../../../dag/testdata/subpackages"
+	pkgs, err := dag.NewPackages(ctx, os.DirFS(dir), dir, nil)
+	require.NoError(t, err)
+	g, err := dag.NewGraph(ctx, pkgs, dag.WithAllowUnresolved())
+	require.NoError(t, err)
+	return g
+}
+
+func press(t *testing.T, m Model, keys ...string) Model {
+	t.Helper()
+	for _, key := range keys {
+		var msg tea.KeyMsg
+		switch key {
+		case "up":
+			msg = tea.KeyMsg{Type: tea.KeyUp}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		case "right":
+			msg = tea.KeyMsg{Type: tea.KeyRight}
+		case "left":
+			msg = tea.KeyMsg{Type: tea.KeyLeft}
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		}
+		updated, cmd := m.Update(msg)
+		m = updated.(Model)
+		// Feed back status messages, like the program would.
+		if cmd != nil {
+			if status, ok := cmd().(statusMsg); ok {
+				updated, _ = m.Update(status)
+				m = updated.(Model)
+			}
+		}
+	}
+	return m
+}
+
+func TestExplorer(t *testing.T) {
+	m, err := New(Options{Graph: testGraph(t), Root: "three"})
+	require.NoError(t, err)
+
+	view := m.View()
+	assert.Contains(t, view, "dependencies of three (subpackages view)")
+	assert.Contains(t, view, "> three-4.5.6-r1 (local)")
+	assert.Contains(t, view, "two-4.5.6-r1 (local) [buildtime] …")
+	assert.Contains(t, view, "busybox (unresolved) [buildtime]")
+	assert.Contains(t, view, "three is defined in ../../../dag/testdata/subpackages/three.yaml")
+
+	// two depends on the one-dev subpackage, which is built by one.
+	m = press(t, m, "down", "down", "right")
+	assert.Equal(t, []string{"three:4.5.6-r1@local", "two:4.5.6-r1@local"}, m.current())
+	m = press(t, m, "down", "down", "right", "down")
+	assert.Equal(t, []string{"three:4.5.6-r1@local", "two:4.5.6-r1@local", "one-dev:1.2.3-r1@local", "one:1.2.3-r1@local"}, m.current())
+	assert.Contains(t, m.View(), "one-dev-1.2.3-r1 (local)")
+	assert.Contains(t, m.View(), "one is defined in")
+
+	// Collapsing goes back to the parent.
+	m = press(t, m, "left")
+	assert.Equal(t, []string{"three:4.5.6-r1@local", "two:4.5.6-r1@local", "one-dev:1.2.3-r1@local"}, m.current())
+	assert.Contains(t, m.View(), "one-dev is built by one")
+
+	// The targets view flattens one-dev into one, and keeps the selection.
+	m = press(t, m, "t")
+	assert.Contains(t, m.View(), "dependencies of one (targets view)")
+	assert.Equal(t, []string{"one:1.2.3-r1@local"}, m.current())
+
+	// one's dependents, in the targets view.
+	m = press(t, m, "r")
+	assert.Contains(t, m.View(), "dependents of one (targets view)")
+	m = press(t, m, "down", "right")
+	assert.Contains(t, m.View(), "three-4.5.6-r1 (local) [buildtime]")
+
+	// Search for a package and explore it instead.
+	m = press(t, m, "/", "t", "w", "o", "enter")
+	assert.Equal(t, modePick, m.mode)
+	assert.Contains(t, m.View(), "two-4.5.6-r1 (local)")
+	m = press(t, m, "enter")
+	assert.Equal(t, modeBrowse, m.mode)
+	assert.Contains(t, m.View(), "dependents of two (targets view)")
+
+	m = press(t, m, "/", "n", "o", "p", "e", "enter")
+	assert.Contains(t, m.View(), `no package matches "nope"`)
+
+	// Without an editor, there is no jumping to configs.
+	m = press(t, m, "e")
+	assert.Contains(t, m.View(), "no editor to open")
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	assert.NotNil(t, cmd)
+	assert.Empty(t, updated.View())
+}
+
+func TestExplorerUnknownRoot(t *testing.T) {
+	_, err := New(Options{Graph: testGraph(t), Root: "nope"})
+	assert.Error(t, err)
+}
+
+type testPackage struct{ version, source string }
+
+func (p testPackage) Name() string    { return "foo" }
+func (p testPackage) Version() string { return p.version }
+func (p testPackage) String() string  { return "foo-" + p.version }
+func (p testPackage) Source() string  { return p.source }
+func (p testPackage) Resolved() bool  { return true }
+
+func TestNewer(t *testing.T) {
+	for _, tt := range []struct {
+		a, b testPackage
+		want bool
+	}{
+		// Versions are compared as versions, not strings.
+		{testPackage{"1.10.0-r0", "repo"}, testPackage{"1.9.0-r0", dag.Local}, true},
+		{testPackage{"1.9.0-r0", dag.Local}, testPackage{"1.10.0-r0", "repo"}, false},
+		{testPackage{"1.0.0-r10", "repo"}, testPackage{"1.0.0-r9", "repo"}, true},
+		// The local package wins a tie.
+		{testPackage{"1.0.0-r0", dag.Local}, testPackage{"1.0.0-r0", "repo"}, true},
+		{testPackage{"1.0.0-r0", "repo"}, testPackage{"1.0.0-r0", dag.Local}, false},
+		{testPackage{"1.0.0-r0", "repo"}, testPackage{"1.0.0-r0", "repo"}, false},
+	} {
+		assert.Equal(t, tt.want, newer(tt.a, tt.b), "newer(%v, %v)", tt.a, tt.b)
+	}
+}
@@ -13,8 +13,8 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/tmc/dot"
 	"github.com/wolfi-dev/wolfictl/pkg/dag"
+	"golang.org/x/exp/slices"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/skratchdot/open-golang/open"
@@ -23,11 +23,14 @@ import (
 func cmdSVG() *cobra.Command { //nolint:gocyclo
 	var dir string
 	var pipelineDirs []string
-	var showDependents, recursive, span, web bool
+	var showDependents, recursive, span, web, runtimeDeps, testDeps bool
+	var format string
 	var extraKeys, extraRepos []string
+	var localBuilds string
+	var cache graphCacheFlags
 	d := &cobra.Command{
 		Use:   "dot",
-		Short: "Generate graphviz .dot output",
+		Short: "Generate graphviz .dot output, or the same graph as JSON, Mermaid or GraphML",
 		Args:  cobra.MinimumNArgs(1),
 		Long: `
 Generate .dot output and pipe it to dot to generate an SVG
@@ -45,22 +48,57 @@ Open browser to explore crane
 Open browser to explore crane's deps recursively, only showing a minimum subgraph
 
   wolfictl dot --web -R -S crane
+
+Generate a Mermaid flowchart of crane's deps, e.g. to embed in Markdown
+
+  wolfictl dot --format mermaid crane
+
+Generate JSON of crane's deps recursively, including runtime dependencies
+
+  wolfictl dot --format json -R --runtime-deps crane
+
+In the JSON and GraphML output, each edge has the dependency as declared, such as go>=1.22, and the version it resolved to.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
+			if web && format != dotFormatDot {
+				return fmt.Errorf("--web only supports --format=%s", dotFormatDot)
+			}
+			if !slices.Contains(dotFormats, format) {
+				return fmt.Errorf("unknown format %q, must be one of: %s", format, strings.Join(dotFormats, ", "))
+			}
+
 			if len(pipelineDirs) == 0 {
 				pipelineDirs = []string{filepath.Join(dir, "pipelines")}
 			}
 
-			pkgs, err := dag.NewPackages(ctx, os.DirFS(dir), dir, pipelineDirs)
+			pkgsOpts, opts, err := cache.options()
+			if err != nil {
+				return err
+			}
+			builds, err := localBuildsOptions(localBuilds)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, builds...)
+
+			pkgs, err := dag.NewPackages(ctx, os.DirFS(dir), dir, pipelineDirs, pkgsOpts...)
 			if err != nil {
 				return fmt.Errorf("NewPackages: %w", err)
 			}
 
-			g, err := dag.NewGraph(ctx, pkgs,
+			opts = append(opts,
 				dag.WithKeys(extraKeys...),
 				dag.WithRepos(extraRepos...),
 			)
+			if runtimeDeps {
+				opts = append(opts, dag.WithRuntimeDeps())
+			}
+			if testDeps {
+				opts = append(opts, dag.WithTestDeps())
+			}
+
+			g, err := dag.NewGraph(ctx, pkgs, opts...)
 			if err != nil {
 				return fmt.Errorf("building graph: %w", err)
 			}
@@ -80,15 +118,21 @@ Open browser to explore crane's deps recursively, only showing a minimum subgrap
 				return err
 			}
 
-			render := func(args []string) (*dot.Graph, error) {
+			collect := func(args []string) (*dotGraph, error) {
 				todo := []string{}
 				queued := map[string]struct{}{}
 
-				out := dot.NewGraph("images")
-				if err := out.Set("rankdir", "LR"); err != nil {
-					return nil, err
+				out := newDotGraph()
+
+				addNode := func(hash string) (string, error) {
+					pkgver, source := split(hash)
+					pkg, err := g.Graph.Vertex(hash)
+					if err != nil {
+						return "", err
+					}
+					out.addNode(pkgver, source, pkg, pkgs.ConfigByKey(pkgver) != nil)
+					return pkgver, nil
 				}
-				out.SetType(dot.DIGRAPH) //nolint:errcheck
 
 				renderNode := func(node string) error {
 					var byName []dag.Package
@@ -109,23 +153,10 @@ Open browser to explore crane's deps recursively, only showing a minimum subgrap
 					for _, name := range byName {
 						h := dag.PackageHash(name)
 
-						pkgver, source := split(h)
-						n := dot.NewNode(pkgver)
-						if err := n.Set("tooltip", source); err != nil {
+						n, err := addNode(h)
+						if err != nil {
 							return err
 						}
-						if pkgs.ConfigByKey(pkgver) != nil {
-							if web {
-								if err := n.Set("URL", link(args, pkgver)); err != nil {
-									return err
-								}
-							}
-						} else {
-							if err := n.Set("color", "red"); err != nil {
-								return err
-							}
-						}
-						out.AddNode(n) //nolint:errcheck
 
 						dependencies, ok := amap[h]
 						if !ok {
@@ -150,24 +181,15 @@ Open browser to explore crane's deps recursively, only showing a minimum subgrap
 								}
 							}
 
-							pkgver, source := split(dep)
-							d := dot.NewNode(pkgver)
-							if err := d.Set("tooltip", source); err != nil {
+							d, err := addNode(dep)
+							if err != nil {
 								return err
 							}
-							if pkgs.ConfigByKey(pkgver) != nil {
-								if web {
-									if err := d.Set("URL", link(args, pkgver)); err != nil {
-										return err
-									}
-								}
-							} else {
-								if err := d.Set("color", "red"); err != nil {
-									return err
-								}
+							c, err := g.Constraint(h, dep)
+							if err != nil {
+								return err
 							}
-							out.AddNode(d)                 //nolint:errcheck
-							out.AddEdge(dot.NewEdge(n, d)) //nolint:errcheck
+							out.addEdge(n, d, c.Types, c.Dependency)
 						}
 
 						if !showDependents {
@@ -186,20 +208,15 @@ Open browser to explore crane's deps recursively, only showing a minimum subgrap
 						sort.Strings(preds)
 
 						for _, pred := range preds {
-							pkgver, source := split(pred)
-							d := dot.NewNode(pkgver)
-							if err := d.Set("tooltip", source); err != nil {
+							d, err := addNode(pred)
+							if err != nil {
 								return err
 							}
-							if pkgs.ConfigByKey(pkgver) != nil {
-								if web {
-									if err := d.Set("URL", link(args, pkgver)); err != nil {
-										return err
-									}
-								}
+							c, err := g.Constraint(pred, h)
+							if err != nil {
+								return err
 							}
-							out.AddNode(d)                 //nolint:errcheck
-							out.AddEdge(dot.NewEdge(d, n)) //nolint:errcheck
+							out.addEdge(d, n, c.Types, c.Dependency)
 						}
 					}
 
@@ -242,7 +259,12 @@ Open browser to explore crane's deps recursively, only showing a minimum subgrap
 						nodes = args
 					}
 
-					out, err := render(nodes)
+					collected, err := collect(nodes)
+					if err != nil {
+						fmt.Fprintf(w, "error rendering %v: %v", nodes, err)
+						log.Fatal(err)
+					}
+					out, err := collected.dot(nodes, true)
 					if err != nil {
 						fmt.Fprintf(w, "error rendering %v: %v", nodes, err)
 						log.Fatal(err)
@@ -289,13 +311,12 @@ Open browser to explore crane's deps recursively, only showing a minimum subgrap
 				return g.Wait()
 			}
 
-			out, err := render(args)
+			out, err := collect(args)
 			if err != nil {
 				return err
 			}
 
-			fmt.Println(out.String())
-			return nil
+			return out.write(os.Stdout, format, args)
 		},
 	}
 	d.Flags().StringVarP(&dir, "dir", "d", ".", "directory to search for melange configs")
@@ -306,6 +327,11 @@ Open browser to explore crane's deps recursively, only showing a minimum subgrap
 	d.Flags().StringSliceVarP(&extraKeys, "keyring-append", "k", []string{"https://packages.wolfi.dev/os/wolfi-signing.rsa.pub"}, "path to extra keys to include in the build environment keyring")
 	d.Flags().StringSliceVarP(&extraRepos, "repository-append", "r", []string{"https://packages.wolfi.dev/os"}, "path to extra repositories to include in the build environment")
 	d.Flags().BoolVar(&web, "web", false, "do a website")
+	d.Flags().StringVar(&format, "format", dotFormatDot, fmt.Sprintf("output format (%s)", strings.Join(dotFormats, ", ")))
+	d.Flags().BoolVar(&runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	d.Flags().BoolVar(&testDeps, "test-deps", false, "include test dependencies in the graph")
+	addLocalBuildsFlagTo(d, &localBuilds)
+	cache.addFlagsTo(d)
 	return d
 }
 
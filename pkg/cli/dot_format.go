@@ -0,0 +1,342 @@
+package cli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tmc/dot"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+const (
+	dotFormatDot     = "dot"
+	dotFormatJSON    = "json"
+	dotFormatMermaid = "mermaid"
+	dotFormatGraphML = "graphml"
+)
+
+var dotFormats = []string{dotFormatDot, dotFormatJSON, dotFormatMermaid, dotFormatGraphML}
+
+// dotGraph is the part of the package graph selected for output by the dot
+// command, independent of the output format.
+type dotGraph struct {
+	nodes []dotNode
+	edges []dotEdge
+
+	// nodeIndex maps the id of each node to its index in nodes.
+	nodeIndex map[string]int
+	seenEdges map[[2]string]struct{}
+}
+
+type dotNode struct {
+	// id is the package name and version, e.g. "zlib-1.3-r0".
+	id     string
+	source string
+	pkg    dag.Package
+
+	// local is true if the package is defined by a config in this repository.
+	local bool
+}
+
+type dotEdge struct {
+	from, to string
+	types    []dag.EdgeType
+
+	// dependency is the dependency as declared in the config of from, such as
+	// foo>=1.2, if the edge comes from one.
+	dependency string
+}
+
+func newDotGraph() *dotGraph {
+	return &dotGraph{
+		nodeIndex: map[string]int{},
+		seenEdges: map[[2]string]struct{}{},
+	}
+}
+
+func (g *dotGraph) addNode(id, source string, pkg dag.Package, local bool) {
+	if _, ok := g.nodeIndex[id]; ok {
+		return
+	}
+	g.nodeIndex[id] = len(g.nodes)
+	g.nodes = append(g.nodes, dotNode{id: id, source: source, pkg: pkg, local: local})
+}
+
+func (g *dotGraph) addEdge(from, to string, types []dag.EdgeType, dependency string) {
+	key := [2]string{from, to}
+	if _, ok := g.seenEdges[key]; ok {
+		return
+	}
+	g.seenEdges[key] = struct{}{}
+	g.edges = append(g.edges, dotEdge{from: from, to: to, types: types, dependency: dependency})
+}
+
+func (g *dotGraph) write(w io.Writer, format string, args []string) error {
+	switch format {
+	case dotFormatDot:
+		out, err := g.dot(args, false)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, out.String())
+		return err
+	case dotFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g.json())
+	case dotFormatMermaid:
+		_, err := io.WriteString(w, g.mermaid())
+		return err
+	case dotFormatGraphML:
+		return g.graphML(w)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// dot renders the graph for graphviz. With web set, local packages link to a
+// page exploring them alongside args.
+func (g *dotGraph) dot(args []string, web bool) (*dot.Graph, error) {
+	out := dot.NewGraph("images")
+	if err := out.Set("rankdir", "LR"); err != nil {
+		return nil, err
+	}
+	out.SetType(dot.DIGRAPH) //nolint:errcheck
+
+	nodes := map[string]*dot.Node{}
+	for _, node := range g.nodes {
+		n := dot.NewNode(node.id)
+		if err := n.Set("tooltip", node.source); err != nil {
+			return nil, err
+		}
+		if node.local {
+			if web {
+				if err := n.Set("URL", link(args, node.id)); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			if err := n.Set("color", "red"); err != nil {
+				return nil, err
+			}
+		}
+		out.AddNode(n) //nolint:errcheck
+		nodes[node.id] = n
+	}
+
+	for _, edge := range g.edges {
+		e := dot.NewEdge(nodes[edge.from], nodes[edge.to])
+		if edge.dependency != "" {
+			if err := e.Set("tooltip", edge.dependency); err != nil {
+				return nil, err
+			}
+		}
+		out.AddEdge(e) //nolint:errcheck
+	}
+
+	return out, nil
+}
+
+type jsonGraph struct {
+	Nodes []jsonNode `json:"nodes"`
+	Edges []jsonEdge `json:"edges"`
+}
+
+type jsonNode struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Source   string `json:"source"`
+	Resolved bool   `json:"resolved"`
+}
+
+type jsonEdge struct {
+	From  string         `json:"from"`
+	To    string         `json:"to"`
+	Types []dag.EdgeType `json:"types"`
+
+	// Dependency is the dependency as declared, and Resolved the version it
+	// resolved to.
+	Dependency string `json:"dependency,omitempty"`
+	Resolved   string `json:"resolved,omitempty"`
+}
+
+func (g *dotGraph) json() jsonGraph {
+	out := jsonGraph{
+		Nodes: make([]jsonNode, 0, len(g.nodes)),
+		Edges: make([]jsonEdge, 0, len(g.edges)),
+	}
+	for _, node := range g.nodes {
+		out.Nodes = append(out.Nodes, jsonNode{
+			ID:       node.id,
+			Name:     node.pkg.Name(),
+			Version:  node.pkg.Version(),
+			Source:   node.pkg.Source(),
+			Resolved: node.pkg.Resolved(),
+		})
+	}
+	for _, edge := range g.edges {
+		types := edge.types
+		if types == nil {
+			types = []dag.EdgeType{}
+		}
+		out.Edges = append(out.Edges, jsonEdge{
+			From:       edge.from,
+			To:         edge.to,
+			Types:      types,
+			Dependency: edge.dependency,
+			Resolved:   g.resolvedVersion(edge),
+		})
+	}
+	return out
+}
+
+// mermaid renders the graph as a Mermaid flowchart. Runtime and test edges are
+// dotted, and packages not defined in this repository are highlighted.
+func (g *dotGraph) mermaid() string {
+	ids := make(map[string]string, len(g.nodes))
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	var external []string
+	for i, node := range g.nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.id] = id
+		fmt.Fprintf(&b, "  %s[%q]\n", id, node.id)
+		if !node.local {
+			external = append(external, id)
+		}
+	}
+	for _, edge := range g.edges {
+		arrow := "-->"
+		if dag.NotBuildtime(edge.types) {
+			arrow = "-.->"
+		}
+		if len(edge.types) > 0 {
+			fmt.Fprintf(&b, "  %s %s|%s| %s\n", ids[edge.from], arrow, joinEdgeTypes(edge.types), ids[edge.to])
+		} else {
+			fmt.Fprintf(&b, "  %s %s %s\n", ids[edge.from], arrow, ids[edge.to])
+		}
+	}
+	if len(external) > 0 {
+		b.WriteString("  classDef external stroke:red,color:red\n")
+		fmt.Fprintf(&b, "  class %s external\n", strings.Join(external, ","))
+	}
+	return b.String()
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (g *dotGraph) graphML(w io.Writer) error {
+	out := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+			{ID: "version", For: "node", AttrName: "version", AttrType: "string"},
+			{ID: "source", For: "node", AttrName: "source", AttrType: "string"},
+			{ID: "node-resolved", For: "node", AttrName: "resolved", AttrType: "boolean"},
+			{ID: "types", For: "edge", AttrName: "types", AttrType: "string"},
+			{ID: "dependency", For: "edge", AttrName: "dependency", AttrType: "string"},
+			{ID: "edge-resolved", For: "edge", AttrName: "resolved", AttrType: "string"},
+		},
+		Graph: graphMLGraph{
+			ID:          "packages",
+			EdgeDefault: "directed",
+		},
+	}
+	for _, node := range g.nodes {
+		out.Graph.Nodes = append(out.Graph.Nodes, graphMLNode{
+			ID: node.id,
+			Data: []graphMLData{
+				{Key: "name", Value: node.pkg.Name()},
+				{Key: "version", Value: node.pkg.Version()},
+				{Key: "source", Value: node.pkg.Source()},
+				{Key: "node-resolved", Value: fmt.Sprint(node.pkg.Resolved())},
+			},
+		})
+	}
+	for _, edge := range g.edges {
+		data := []graphMLData{{Key: "types", Value: joinEdgeTypes(edge.types)}}
+		if edge.dependency != "" {
+			data = append(data,
+				graphMLData{Key: "dependency", Value: edge.dependency},
+				graphMLData{Key: "edge-resolved", Value: g.resolvedVersion(edge)},
+			)
+		}
+		out.Graph.Edges = append(out.Graph.Edges, graphMLEdge{
+			Source: edge.from,
+			Target: edge.to,
+			Data:   data,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// resolvedVersion returns the version the dependency of edge resolved to, or an
+// empty string if the edge doesn't come from a dependency or it's unresolved.
+func (g *dotGraph) resolvedVersion(edge dotEdge) string {
+	if edge.dependency == "" {
+		return ""
+	}
+	i, ok := g.nodeIndex[edge.to]
+	if !ok || !g.nodes[i].pkg.Resolved() {
+		return ""
+	}
+	return g.nodes[i].pkg.Version()
+}
+
+func joinEdgeTypes(types []dag.EdgeType) string {
+	s := make([]string, 0, len(types))
+	for _, t := range types {
+		s = append(s, string(t))
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+type testPackage struct {
+	name, version, source string
+}
+
+func (p testPackage) Name() string    { return p.name }
+func (p testPackage) Version() string { return p.version }
+func (p testPackage) String() string  { return p.name + ":" + p.version }
+func (p testPackage) Source() string  { return p.source }
+func (p testPackage) Resolved() bool  { return p.source != "unknown" }
+
+func testDotGraph() *dotGraph {
+	g := newDotGraph()
+	g.addNode("crane-0.19.0-r0", dag.Local, testPackage{"crane", "0.19.0-r0", dag.Local}, true)
+	g.addNode("go-1.22.0-r0", dag.Local, testPackage{"go", "1.22.0-r0", dag.Local}, true)
+	g.addNode("ca-certificates-bundle-", "unknown", testPackage{"ca-certificates-bundle", "", "unknown"}, false)
+	g.addNode("go-1.22.0-r0", dag.Local, testPackage{"go", "1.22.0-r0", dag.Local}, true)
+	g.addEdge("crane-0.19.0-r0", "go-1.22.0-r0", []dag.EdgeType{dag.EdgeBuildtime}, "go>=1.22")
+	g.addEdge("crane-0.19.0-r0", "ca-certificates-bundle-", []dag.EdgeType{dag.EdgeRuntime}, "ca-certificates-bundle")
+	g.addEdge("crane-0.19.0-r0", "go-1.22.0-r0", []dag.EdgeType{dag.EdgeBuildtime}, "go>=1.22")
+	return g
+}
+
+func TestDotGraphJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testDotGraph().write(&buf, dotFormatJSON, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var got jsonGraph
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := jsonGraph{
+		Nodes: []jsonNode{
+			{ID: "crane-0.19.0-r0", Name: "crane", Version: "0.19.0-r0", Source: dag.Local, Resolved: true},
+			{ID: "go-1.22.0-r0", Name: "go", Version: "1.22.0-r0", Source: dag.Local, Resolved: true},
+			{ID: "ca-certificates-bundle-", Name: "ca-certificates-bundle", Source: "unknown"},
+		},
+		Edges: []jsonEdge{
+			{From: "crane-0.19.0-r0", To: "go-1.22.0-r0", Types: []dag.EdgeType{dag.EdgeBuildtime}, Dependency: "go>=1.22", Resolved: "1.22.0-r0"},
+			{From: "crane-0.19.0-r0", To: "ca-certificates-bundle-", Types: []dag.EdgeType{dag.EdgeRuntime}, Dependency: "ca-certificates-bundle"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("json mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDotGraphMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testDotGraph().write(&buf, dotFormatMermaid, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `flowchart LR
+  n0["crane-0.19.0-r0"]
+  n1["go-1.22.0-r0"]
+  n2["ca-certificates-bundle-"]
+  n0 -->|buildtime| n1
+  n0 -.->|runtime| n2
+  classDef external stroke:red,color:red
+  class n2 external
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("mermaid mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDotGraphGraphML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testDotGraph().write(&buf, dotFormatGraphML, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var got graphML
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// Key ids are unique across the document, whatever they're for.
+	keys := map[string]string{}
+	for _, k := range got.Keys {
+		if _, ok := keys[k.ID]; ok {
+			t.Errorf("duplicate key id %q", k.ID)
+		}
+		keys[k.ID] = k.For
+	}
+	for _, n := range got.Graph.Nodes {
+		for _, d := range n.Data {
+			if keys[d.Key] != "node" {
+				t.Errorf("node %s: data key %q isn't a node key", n.ID, d.Key)
+			}
+		}
+	}
+	for _, e := range got.Graph.Edges {
+		for _, d := range e.Data {
+			if keys[d.Key] != "edge" {
+				t.Errorf("edge %s -> %s: data key %q isn't an edge key", e.Source, e.Target, d.Key)
+			}
+		}
+	}
+
+	want := []graphMLData{
+		{Key: "types", Value: "buildtime"},
+		{Key: "dependency", Value: "go>=1.22"},
+		{Key: "edge-resolved", Value: "1.22.0-r0"},
+	}
+	if len(got.Graph.Edges) != 2 {
+		t.Fatalf("got %d edges, want 2", len(got.Graph.Edges))
+	}
+	if diff := cmp.Diff(want, got.Graph.Edges[0].Data); diff != "" {
+		t.Errorf("edge data mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"chainguard.dev/apko/pkg/build/types"
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/cli/internal/builds"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func cmdGraph() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "graph",
+		SilenceUsage: true,
+		Short:        "Subcommands used to analyze the package dependency graph",
+	}
+	cmd.AddCommand(
+		cmdGraphArches(),
+		cmdGraphConstraints(),
+		cmdGraphCriticalPath(),
+		cmdGraphCycles(),
+		cmdGraphDiff(),
+		cmdGraphExplore(),
+		cmdGraphUnresolved(),
+	)
+	return cmd
+}
+
+// graphParams are the flags shared by the graph subcommands to build the
+// package dependency graph.
+type graphParams struct {
+	dir          string
+	pipelineDirs []string
+	arch         string
+	extraKeys    []string
+	extraRepos   []string
+	runtimeDeps  bool
+	testDeps     bool
+	localBuilds  string
+	cache        graphCacheFlags
+}
+
+func (p *graphParams) addFlagsTo(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&p.dir, "dir", "d", ".", "directory to search for melange configs")
+	p.addResolveFlagsTo(cmd)
+}
+
+// addResolveFlagsTo adds the flags to build the graph other than the directory
+// of melange configs, for commands that take it from another flag.
+func (p *graphParams) addResolveFlagsTo(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&p.pipelineDirs, "pipeline-dir", nil, "directory used to extend defined built-in pipelines")
+	cmd.Flags().StringVarP(&p.arch, "arch", "a", "x86_64", "architecture to build for")
+	cmd.Flags().StringSliceVarP(&p.extraKeys, "keyring-append", "k", []string{"https://packages.wolfi.dev/os/wolfi-signing.rsa.pub"}, "path to extra keys to include in the build environment keyring")
+	cmd.Flags().StringSliceVarP(&p.extraRepos, "repository-append", "r", []string{"https://packages.wolfi.dev/os"}, "path to extra repositories to include in the build environment")
+	addLocalBuildsFlagTo(cmd, &p.localBuilds)
+	p.cache.addFlagsTo(cmd)
+}
+
+func addLocalBuildsFlagTo(cmd *cobra.Command, dir *string) {
+	cmd.Flags().StringVar(dir, "local-builds", "", "melange packages directory to also resolve dependencies against, e.g. ./packages, read from each architecture's APKINDEX.tar.gz or APK files")
+}
+
+// localBuildsOptions returns the options to resolve dependencies against the
+// packages built into dir, if it is set.
+func localBuildsOptions(dir string) ([]dag.GraphOptions, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	packages, err := builds.Packages(os.DirFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("reading local builds in %q: %w", dir, err)
+	}
+	return []dag.GraphOptions{dag.WithLocalBuilds(&dag.LocalBuilds{Dir: dir, Packages: packages})}, nil
+}
+
+// graphCacheFlags are the flags to cache compiled configs and resolved
+// dependencies on disk, so that building the same graph again is faster.
+type graphCacheFlags struct {
+	enabled bool
+	dir     string
+}
+
+func (f *graphCacheFlags) addFlagsTo(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.enabled, "cache", false, "cache compiled configs and resolved dependencies on disk, so that only what changed is compiled and resolved next time")
+	cmd.Flags().StringVar(&f.dir, "cache-dir", "", "directory to cache the graph in, implies --cache (default: wolfictl/graph in the user cache directory)")
+}
+
+// options returns the options to build the packages and graph with the cache,
+// if it is enabled.
+func (f *graphCacheFlags) options() ([]dag.PackagesOptions, []dag.GraphOptions, error) {
+	if !f.enabled && f.dir == "" {
+		return nil, nil, nil
+	}
+	dir := f.dir
+	if dir == "" {
+		var err error
+		if dir, err = dag.DefaultCacheDir(); err != nil {
+			return nil, nil, fmt.Errorf("finding cache directory: %w", err)
+		}
+	}
+	cache, err := dag.NewCache(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []dag.PackagesOptions{dag.WithConfigCache(cache)}, []dag.GraphOptions{dag.WithResolutionCache(cache)}, nil
+}
+
+// build returns the packages in the configured directory and their graph,
+// built with any extra options.
+func (p *graphParams) build(ctx context.Context, extra ...dag.GraphOptions) (*dag.Packages, *dag.Graph, error) {
+	return p.buildFrom(ctx, os.DirFS(p.dir), p.defaultPipelineDirs(), extra...)
+}
+
+// defaultPipelineDirs returns the configured pipeline directories, or the
+// pipelines directory within the configured directory if there are none.
+func (p *graphParams) defaultPipelineDirs() []string {
+	if len(p.pipelineDirs) == 0 {
+		return []string{filepath.Join(p.dir, "pipelines")}
+	}
+	return p.pipelineDirs
+}
+
+// packages returns the packages in fsys.
+func (p *graphParams) packages(ctx context.Context, fsys fs.FS, pipelineDirs []string) (*dag.Packages, error) {
+	opts, _, err := p.cache.options()
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := dag.NewPackages(ctx, fsys, p.dir, pipelineDirs, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("constructing new package set from directory %q: %w", p.dir, err)
+	}
+	return pkgs, nil
+}
+
+// options returns the options to build the graph with, other than the
+// architecture, followed by any extra options.
+func (p *graphParams) options(extra ...dag.GraphOptions) ([]dag.GraphOptions, error) {
+	_, opts, err := p.cache.options()
+	if err != nil {
+		return nil, err
+	}
+	builds, err := localBuildsOptions(p.localBuilds)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, builds...)
+	opts = append(opts,
+		dag.WithKeys(p.extraKeys...),
+		dag.WithRepos(p.extraRepos...),
+	)
+	if p.runtimeDeps {
+		opts = append(opts, dag.WithRuntimeDeps())
+	}
+	if p.testDeps {
+		opts = append(opts, dag.WithTestDeps())
+	}
+	return append(opts, extra...), nil
+}
+
+// buildFrom returns the packages in fsys and their graph, built with any extra
+// options.
+func (p *graphParams) buildFrom(ctx context.Context, fsys fs.FS, pipelineDirs []string, extra ...dag.GraphOptions) (*dag.Packages, *dag.Graph, error) {
+	pkgs, err := p.packages(ctx, fsys, pipelineDirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts, err := p.options(append([]dag.GraphOptions{dag.WithArch(types.ParseArchitecture(p.arch).ToAPK())}, extra...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	g, err := dag.NewGraph(ctx, pkgs, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating graph: %w", err)
+	}
+	return pkgs, g, nil
+}
+
+// targets returns the graph of local origin packages, with subpackages
+// flattened into their origins.
+func (p *graphParams) targets(ctx context.Context) (*dag.Packages, *dag.Graph, error) {
+	pkgs, g, err := p.build(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	filtered, err := g.Filter(dag.FilterLocal())
+	if err != nil {
+		return nil, nil, err
+	}
+	targets, err := filtered.Targets()
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkgs, targets, nil
+}
+
+// lookupNodes returns the nodes in g of the named packages. If g is the result of
+// Targets, subpackages are looked up by their origin instead.
+func lookupNodes(g *dag.Graph, pkgs *dag.Packages, names []string) ([]string, error) {
+	var out []string
+	for _, name := range names {
+		found, err := g.NodesByName(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			if cfgs := pkgs.Config(name, false); len(cfgs) > 0 {
+				found, err = g.NodesByName(cfgs[0].Package.Name)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("could not find package %q", name)
+		}
+		for _, pkg := range found {
+			out = append(out, dag.PackageHash(pkg))
+		}
+	}
+	return out, nil
+}
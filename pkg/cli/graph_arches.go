@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"chainguard.dev/apko/pkg/build/types"
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+	"github.com/wolfi-dev/wolfictl/pkg/distro"
+)
+
+func cmdGraphArches() *cobra.Command {
+	p := &archesParams{}
+	cmd := &cobra.Command{
+		Use:   "arches",
+		Short: "Compare the package dependency graph across architectures",
+		Long: `Compare the package dependency graph across architectures.
+
+The graph is built for each architecture the distro supports, or those given with --arches, only including the packages whose target-architecture includes it. The command then reports:
+
+  - packages whose dependencies differ by architecture,
+  - packages that only build on some architectures while some of their dependents build on more and are left without them,
+  - dependencies that can't be resolved on some of the architectures a package builds on, but can be on others.
+
+Dependencies that can't be resolved on any architecture aren't reported.
+
+The command exits with an error if a package is missing on an architecture its dependents need it on, or a dependency is only resolved on some architectures.`,
+		Example: `
+  wolfictl graph arches
+
+  wolfictl graph arches --arches x86_64,aarch64 --json`,
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+
+			arches, err := p.resolveArches()
+			if err != nil {
+				return err
+			}
+
+			pkgs, err := p.packages(ctx, os.DirFS(p.dir), p.defaultPipelineDirs())
+			if err != nil {
+				return err
+			}
+			opts, err := p.options()
+			if err != nil {
+				return err
+			}
+			ag, err := dag.NewArchGraphs(ctx, pkgs, arches, opts...)
+			if err != nil {
+				return fmt.Errorf("creating graphs: %w", err)
+			}
+			report, err := ag.Report()
+			if err != nil {
+				return err
+			}
+
+			if p.json {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(newArchReportJSON(report)); err != nil {
+					return err
+				}
+			} else {
+				printArchReport(os.Stdout, report)
+			}
+
+			if n := len(report.Partial) + len(report.Unresolved); n > 0 {
+				return fmt.Errorf("found %d architecture-specific problems", n)
+			}
+			return nil
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type archesParams struct {
+	graphParams
+
+	arches []string
+	json   bool
+}
+
+func (p *archesParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().StringSliceVar(&p.arches, "arches", nil, "architectures to compare (default: the architectures supported by the distro)")
+	cmd.Flags().BoolVar(&p.json, "json", false, "print the report as JSON")
+}
+
+// resolveArches returns the architectures to compare, in their APK form,
+// detecting the distro's supported architectures if none were given.
+func (p *archesParams) resolveArches() ([]string, error) {
+	arches := p.arches
+	if len(arches) == 0 {
+		d, err := distro.DetectFromDir(p.dir)
+		if err != nil {
+			return nil, fmt.Errorf("no architectures specified, and distro auto-detection failed: %w", err)
+		}
+		arches = d.Absolute.SupportedArchitectures
+	}
+	out := make([]string, 0, len(arches))
+	for _, arch := range arches {
+		out = append(out, types.ParseArchitecture(arch).ToAPK())
+	}
+	return out, nil
+}
+
+func printArchReport(w io.Writer, r *dag.ArchReport) {
+	if r.Empty() {
+		fmt.Fprintln(w, "No differences between architectures")
+		return
+	}
+
+	if len(r.Divergent) > 0 {
+		fmt.Fprintln(w, "Dependencies that differ by architecture:")
+		for _, d := range r.Divergent {
+			fmt.Fprintf(w, "  %s\n", d.Package)
+			for _, arch := range sortedArches(d.Only) {
+				fmt.Fprintf(w, "    %s only: %s\n", arch, strings.Join(d.Only[arch], ", "))
+			}
+		}
+	}
+	if len(r.Partial) > 0 {
+		fmt.Fprintln(w, "Packages missing on architectures their dependents build on:")
+		for _, pa := range r.Partial {
+			fmt.Fprintf(w, "  %s (missing on %s): needed by %s\n", pa.Package, strings.Join(pa.Missing, ", "), strings.Join(pa.Dependents, ", "))
+		}
+	}
+	if len(r.Unresolved) > 0 {
+		fmt.Fprintln(w, "Dependencies only unresolved on some architectures:")
+		for _, u := range r.Unresolved {
+			fmt.Fprintf(w, "  %s -> %s (unresolved on %s)\n", u.Package, u.Dependency, strings.Join(u.Arches, ", "))
+		}
+	}
+}
+
+func sortedArches(m map[string][]string) []string {
+	arches := make([]string, 0, len(m))
+	for arch := range m {
+		arches = append(arches, arch)
+	}
+	sort.Strings(arches)
+	return arches
+}
+
+type archReportJSON struct {
+	Divergent  []archDivergenceJSON `json:"divergent"`
+	Partial    []partialArchJSON    `json:"partial"`
+	Unresolved []archUnresolvedJSON `json:"unresolved"`
+}
+
+type archDivergenceJSON struct {
+	Package string              `json:"package"`
+	Only    map[string][]string `json:"only"`
+}
+
+type partialArchJSON struct {
+	Package    string   `json:"package"`
+	Arches     []string `json:"arches"`
+	Missing    []string `json:"missing"`
+	Dependents []string `json:"dependents"`
+}
+
+type archUnresolvedJSON struct {
+	Package    string   `json:"package"`
+	Dependency string   `json:"dependency"`
+	Arches     []string `json:"arches"`
+}
+
+func newArchReportJSON(r *dag.ArchReport) archReportJSON {
+	out := archReportJSON{
+		Divergent:  []archDivergenceJSON{},
+		Partial:    []partialArchJSON{},
+		Unresolved: []archUnresolvedJSON{},
+	}
+	for _, d := range r.Divergent {
+		out.Divergent = append(out.Divergent, archDivergenceJSON{Package: d.Package, Only: d.Only})
+	}
+	for _, pa := range r.Partial {
+		out.Partial = append(out.Partial, partialArchJSON{
+			Package:    pa.Package,
+			Arches:     pa.Arches,
+			Missing:    pa.Missing,
+			Dependents: pa.Dependents,
+		})
+	}
+	for _, u := range r.Unresolved {
+		out.Unresolved = append(out.Unresolved, archUnresolvedJSON{
+			Package:    u.Package,
+			Dependency: u.Dependency,
+			Arches:     u.Arches,
+		})
+	}
+	return out
+}
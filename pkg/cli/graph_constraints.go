@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func cmdGraphConstraints() *cobra.Command {
+	p := &constraintsParams{}
+	cmd := &cobra.Command{
+		Use:   "constraints",
+		Short: "Check that local packages satisfy the version constraints of their dependents",
+		Long: `Check that local packages still satisfy the version constraints their dependents declare on them, such as foo>=1.2, foo~3.1 or so:libfoo.so.3=3.
+
+A constraint the local package no longer satisfies resolves to another package instead, such as an older build of it in the repositories, or doesn't resolve at all. This is typically caused by a version bump breaking a dependent pinned to the previous version, which is reported here before the new version is published.
+
+With --all, every dependency is listed with the version it resolved to.
+
+The command exits with an error if any constraint isn't satisfied by the local packages.`,
+		Example: `
+  wolfictl graph constraints --runtime-deps
+
+  # List every dependency with the version it resolved to
+  wolfictl graph constraints --all`,
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, g, err := p.build(cmd.Context(), dag.WithAllowUnresolved())
+			if err != nil {
+				return err
+			}
+
+			if p.all {
+				constraints, err := g.Constraints()
+				if err != nil {
+					return err
+				}
+				if p.json {
+					enc := json.NewEncoder(os.Stdout)
+					enc.SetIndent("", "  ")
+					return enc.Encode(newConstraintsJSON(constraints))
+				}
+				printConstraints(os.Stdout, constraints)
+				return nil
+			}
+
+			unsatisfied, err := g.Unsatisfied()
+			if err != nil {
+				return err
+			}
+			if p.json {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(newUnsatisfiedJSON(unsatisfied)); err != nil {
+					return err
+				}
+			} else {
+				printUnsatisfied(os.Stdout, unsatisfied)
+			}
+
+			if len(unsatisfied) > 0 {
+				return fmt.Errorf("found %d unsatisfied constraints", len(unsatisfied))
+			}
+			return nil
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type constraintsParams struct {
+	graphParams
+
+	all  bool
+	json bool
+}
+
+func (p *constraintsParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().BoolVar(&p.all, "all", false, "list every dependency with the version it resolved to")
+	cmd.Flags().BoolVar(&p.json, "json", false, "print the constraints as JSON")
+}
+
+// resolvedTo describes the package a dependency resolved to.
+func resolvedTo(p dag.Package) string {
+	if !p.Resolved() {
+		return "unresolved"
+	}
+	return fmt.Sprintf("%s-%s (%s)", p.Name(), p.Version(), p.Source())
+}
+
+func printConstraints(w io.Writer, constraints []dag.Constraint) {
+	for _, c := range constraints {
+		fmt.Fprintf(w, "%s: %s -> %s\n", c.Package.Name(), c.Dependency, resolvedTo(c.Resolved))
+	}
+}
+
+func printUnsatisfied(w io.Writer, unsatisfied []dag.Unsatisfied) {
+	if len(unsatisfied) == 0 {
+		fmt.Fprintln(w, "All constraints on local packages are satisfied")
+		return
+	}
+	for _, u := range unsatisfied {
+		fmt.Fprintf(w, "%s needed by %s in %s (%s)\n", u.Dependency, u.Package.Name(), configPath(u.Package), strings.Join(u.Fields(), ", "))
+		for _, l := range u.Local {
+			fmt.Fprintf(w, "  not satisfied by local %s-%s in %s\n", l.Name(), l.Version(), l.Path)
+		}
+		fmt.Fprintf(w, "  resolved to %s\n", resolvedTo(u.Resolved))
+	}
+}
+
+type constraintJSON struct {
+	Package    string          `json:"package"`
+	Dependency string          `json:"dependency"`
+	Types      []string        `json:"types"`
+	Resolved   *packageRefJSON `json:"resolved"`
+}
+
+type packageRefJSON struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+}
+
+type unsatisfiedJSON struct {
+	constraintJSON
+	Path  string      `json:"path,omitempty"`
+	Local []localJSON `json:"local"`
+}
+
+type localJSON struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+func newConstraintJSON(c dag.Constraint) constraintJSON {
+	out := constraintJSON{
+		Package:    c.Package.Name(),
+		Dependency: c.Dependency,
+		Types:      make([]string, 0, len(c.Types)),
+	}
+	for _, t := range c.Types {
+		out.Types = append(out.Types, string(t))
+	}
+	if c.Resolved.Resolved() {
+		out.Resolved = &packageRefJSON{Name: c.Resolved.Name(), Version: c.Resolved.Version(), Source: c.Resolved.Source()}
+	}
+	return out
+}
+
+func newConstraintsJSON(constraints []dag.Constraint) []constraintJSON {
+	out := make([]constraintJSON, 0, len(constraints))
+	for _, c := range constraints {
+		out = append(out, newConstraintJSON(c))
+	}
+	return out
+}
+
+func newUnsatisfiedJSON(unsatisfied []dag.Unsatisfied) []unsatisfiedJSON {
+	out := make([]unsatisfiedJSON, 0, len(unsatisfied))
+	for _, u := range unsatisfied {
+		uj := unsatisfiedJSON{
+			constraintJSON: newConstraintJSON(u.Constraint),
+			Path:           configPath(u.Package),
+			Local:          make([]localJSON, 0, len(u.Local)),
+		}
+		for _, l := range u.Local {
+			uj.Local = append(uj.Local, localJSON{Name: l.Name(), Version: l.Version(), Path: l.Path})
+		}
+		out = append(out, uj)
+	}
+	return out
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func cmdGraphCriticalPath() *cobra.Command {
+	p := &criticalPathParams{}
+	cmd := &cobra.Command{
+		Use:   "critical-path [packages...]",
+		Short: "Estimate how long it takes to rebuild packages, and what bounds that time",
+		Long: `Estimate how long it takes to rebuild packages, using the dependency graph and historical build durations.
+
+Durations are read from a file with --durations, holding one "<package> <duration>" pair per line (e.g. "gcc 1h15m"), or approximated with --apkindex from the build times in a local APKINDEX written by a sequential build, where each origin package is assumed to have taken as long as the gap since the previous one was built. When both are given, the durations file takes precedence. Packages without a known duration are assumed to take --default-duration.
+
+With no arguments, every local package is considered. Otherwise, only the given packages and the packages that transitively depend on them are, which is what needs to be rebuilt after changing the given packages. Subpackages can be given too, and stand for their origin package.
+
+The output shows the critical path, the longest chain of dependent builds, which bounds how fast the packages can be built no matter how many workers are available; the estimated wall-clock time with --workers concurrent builds; and the packages that contribute most to the critical path.`,
+		Example: `
+  wolfictl graph critical-path --durations durations.txt --workers 8
+
+  wolfictl graph critical-path --apkindex packages/x86_64/APKINDEX.tar.gz openssl`,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			durations := dag.Durations{}
+			if p.apkindex != "" {
+				packages, err := readAPKIndex(p.apkindex)
+				if err != nil {
+					return err
+				}
+				for name, d := range dag.DurationsFromIndex(packages) {
+					durations[name] = d
+				}
+			}
+			if p.durations != "" {
+				f, err := os.Open(p.durations)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				fromFile, err := dag.ReadDurations(f)
+				if err != nil {
+					return fmt.Errorf("reading durations from %q: %w", p.durations, err)
+				}
+				for name, d := range fromFile {
+					durations[name] = d
+				}
+			}
+
+			pkgs, g, err := p.targets(ctx)
+			if err != nil {
+				return err
+			}
+			if len(args) > 0 {
+				roots, err := lookupNodes(g, pkgs, args)
+				if err != nil {
+					return err
+				}
+				g, err = g.Dependents(roots, 0)
+				if err != nil {
+					return err
+				}
+			}
+
+			est, err := g.Estimate(durations, p.defaultDuration, p.workers)
+			if err != nil {
+				return fmt.Errorf("estimating build time: %w", err)
+			}
+
+			if p.json {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(newCriticalPathJSON(est, p.top))
+			}
+			return printEstimate(os.Stdout, est, p.top)
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type criticalPathParams struct {
+	graphParams
+
+	durations       string
+	apkindex        string
+	workers         int
+	defaultDuration time.Duration
+	top             int
+	json            bool
+}
+
+func (p *criticalPathParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().StringVar(&p.durations, "durations", "", "file with one \"<package> <duration>\" pair per line")
+	cmd.Flags().StringVar(&p.apkindex, "apkindex", "", "local APKINDEX (or APKINDEX.tar.gz) to approximate durations from build times")
+	cmd.Flags().IntVarP(&p.workers, "workers", "j", 1, "number of concurrent builds (0 for no limit)")
+	cmd.Flags().DurationVar(&p.defaultDuration, "default-duration", 5*time.Minute, "duration assumed for packages without a known duration")
+	cmd.Flags().IntVar(&p.top, "top", 10, "number of top contributors to the critical path to show (0 for all)")
+	cmd.Flags().BoolVar(&p.json, "json", false, "print the estimate as JSON")
+}
+
+// readAPKIndex reads the packages in an APKINDEX, either the archive or the
+// unpacked APKINDEX file within it.
+func readAPKIndex(path string) ([]*apk.Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(path, ".tar.gz") {
+		index, err := apk.IndexFromArchive(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading apkindex from archive %q: %w", path, err)
+		}
+		return index.Packages, nil
+	}
+
+	packages, err := apk.ParsePackageIndex(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing apkindex %q: %w", path, err)
+	}
+	return packages, nil
+}
+
+func printEstimate(w io.Writer, est *dag.Estimate, top int) error {
+	names := make([]string, 0, len(est.CriticalPath))
+	for _, p := range est.CriticalPath {
+		names = append(names, p.Name())
+	}
+
+	workers := "unlimited workers"
+	switch {
+	case est.Workers == 1:
+		workers = "1 worker"
+	case est.Workers > 1:
+		workers = fmt.Sprintf("%d workers", est.Workers)
+	}
+
+	fmt.Fprintf(w, "Critical path (%s): %s\n", est.CriticalPathDuration, strings.Join(names, " -> "))
+	fmt.Fprintf(w, "Sequential build time: %s\n", est.Total)
+	fmt.Fprintf(w, "Estimated wall-clock time with %s: %s\n", workers, est.WallClock)
+
+	contributions := topContributions(est, top)
+	if len(contributions) > 0 {
+		fmt.Fprintf(w, "\nTop contributors to the critical path:\n")
+		width := 0
+		for _, c := range contributions {
+			if n := len(c.Package.Name()); n > width {
+				width = n
+			}
+		}
+		for _, c := range contributions {
+			fmt.Fprintf(w, "  %-*s  %10s  %5.1f%%\n", width, c.Package.Name(), c.Duration, c.Share*100)
+		}
+	}
+
+	if len(est.Unknown) > 0 {
+		fmt.Fprintf(w, "\nAssumed the default duration for packages without a known duration: %s\n", strings.Join(est.Unknown, " "))
+	}
+	return nil
+}
+
+func topContributions(est *dag.Estimate, top int) []dag.Contribution {
+	if top > 0 && len(est.Contributions) > top {
+		return est.Contributions[:top]
+	}
+	return est.Contributions
+}
+
+type criticalPathJSON struct {
+	CriticalPath         []string           `json:"criticalPath"`
+	CriticalPathDuration string             `json:"criticalPathDuration"`
+	Sequential           string             `json:"sequential"`
+	WallClock            string             `json:"wallClock"`
+	Workers              int                `json:"workers"`
+	Contributors         []contributionJSON `json:"contributors"`
+	Unknown              []string           `json:"unknown"`
+}
+
+type contributionJSON struct {
+	Package  string  `json:"package"`
+	Duration string  `json:"duration"`
+	Share    float64 `json:"share"`
+}
+
+func newCriticalPathJSON(est *dag.Estimate, top int) criticalPathJSON {
+	out := criticalPathJSON{
+		CriticalPath:         []string{},
+		CriticalPathDuration: est.CriticalPathDuration.String(),
+		Sequential:           est.Total.String(),
+		WallClock:            est.WallClock.String(),
+		Workers:              est.Workers,
+		Contributors:         []contributionJSON{},
+		Unknown:              append([]string{}, est.Unknown...),
+	}
+	for _, p := range est.CriticalPath {
+		out.CriticalPath = append(out.CriticalPath, p.Name())
+	}
+	for _, c := range topContributions(est, top) {
+		out.Contributors = append(out.Contributors, contributionJSON{
+			Package:  c.Package.Name(),
+			Duration: c.Duration.String(),
+			Share:    c.Share,
+		})
+	}
+	sort.Strings(out.Unknown)
+	return out
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func cmdGraphCycles() *cobra.Command {
+	p := &cyclesParams{}
+	cmd := &cobra.Command{
+		Use:   "cycles",
+		Short: "Find every dependency cycle between packages",
+		Long: `Find every dependency cycle between packages.
+
+Building the graph normally stops at the first cycle that can't be resolved by picking another version of a dependency. This command instead builds the graph as declared, with each dependency resolved to its best candidate, and reports every group of packages that depend on each other, every elementary cycle within them, and the config fields that declared each dependency in a cycle.
+
+It then suggests a set of dependencies to break so that no cycle is left, preferring dependencies for which a bootstrap package, such as foo-stage0 for foo, already exists.
+
+The command exits with an error if any cycle is found.`,
+		Example: `
+  wolfictl graph cycles
+
+  # Only the groups of packages that depend on each other
+  wolfictl graph cycles --components`,
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, g, err := p.build(cmd.Context(), dag.WithAllowCycles())
+			if err != nil {
+				return err
+			}
+
+			components, err := g.CyclicComponents()
+			if err != nil {
+				return err
+			}
+			if len(components) == 0 {
+				fmt.Fprintln(os.Stdout, "No cycles found")
+				return nil
+			}
+			if p.components {
+				printComponents(os.Stdout, g, components)
+				return fmt.Errorf("found %d groups of packages that depend on each other", len(components))
+			}
+
+			cycles, err := g.Cycles(p.limit)
+			if err != nil {
+				return err
+			}
+			printCycles(os.Stdout, g, components, cycles, p.limit)
+			return fmt.Errorf("found %d cycles", len(cycles))
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type cyclesParams struct {
+	graphParams
+
+	limit      int
+	components bool
+}
+
+func (p *cyclesParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().IntVar(&p.limit, "limit", 100, "maximum number of cycles to enumerate (0 for no limit)")
+	cmd.Flags().BoolVar(&p.components, "components", false, "only print the groups of packages that depend on each other, without enumerating cycles")
+}
+
+func printComponents(w io.Writer, g *dag.Graph, components [][]string) {
+	for i, component := range components {
+		names := make([]string, 0, len(component))
+		for _, node := range component {
+			pkg, err := g.Graph.Vertex(node)
+			if err != nil {
+				names = append(names, node)
+				continue
+			}
+			names = append(names, hopName(pkg))
+		}
+		fmt.Fprintf(w, "Group %d: %s\n", i+1, strings.Join(names, " "))
+	}
+}
+
+func printCycles(w io.Writer, g *dag.Graph, components [][]string, cycles []dag.Cycle, limit int) {
+	printComponents(w, g, components)
+
+	for i, c := range cycles {
+		fmt.Fprintf(w, "\nCycle %d: ", i+1)
+		printPath(w, c)
+	}
+	if limit > 0 && len(cycles) >= limit {
+		fmt.Fprintf(w, "\nStopped after %d cycles, there may be more (see --limit).\n", limit)
+	}
+
+	suggestions := g.SuggestBreaks(cycles)
+	if len(suggestions) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nSuggested dependencies to break:\n")
+	for _, s := range suggestions {
+		fmt.Fprintf(w, "  %s (in %d cycles)", describeHop(s.Hop), s.Cycles)
+		if s.Bootstrap != "" {
+			fmt.Fprintf(w, ", depend on %s instead", s.Bootstrap)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+	wgit "github.com/wolfi-dev/wolfictl/pkg/git"
+)
+
+func cmdGraphDiff() *cobra.Command {
+	p := &graphDiffParams{}
+	cmd := &cobra.Command{
+		Use:   "diff <rev-a> <rev-b>",
+		Short: "Compare the package graph at two git revisions",
+		Long: `Compare the package graph at two git revisions of the repository containing --dir, without checking them out.
+
+The output lists the packages added, removed or changed in version between the revisions, the dependencies added or removed between packages, and the packages whose transitive dependencies changed, including by a dependency changing version. Those packages, along with the packages that changed version, are what a rebuild of the second revision would need to build again.
+
+Pipelines are read from the pipelines directory at each revision, unless --pipeline-dir is given.`,
+		Example: `
+  # What would this branch trigger a rebuild of?
+  wolfictl graph diff main HEAD`,
+		Args:          cobra.ExactArgs(2),
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			repo, err := git.PlainOpenWithOptions(p.dir, &git.PlainOpenOptions{DetectDotGit: true})
+			if err != nil {
+				return fmt.Errorf("opening git repository at %q: %w", p.dir, err)
+			}
+			wt, err := repo.Worktree()
+			if err != nil {
+				return err
+			}
+			abs, err := filepath.Abs(p.dir)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(wt.Filesystem.Root(), abs)
+			if err != nil {
+				return err
+			}
+
+			graphs := make([]*dag.Graph, 0, len(args))
+			for _, rev := range args {
+				g, err := p.graphAt(ctx, repo, rev, filepath.ToSlash(rel))
+				if err != nil {
+					return fmt.Errorf("building graph at %q: %w", rev, err)
+				}
+				graphs = append(graphs, g)
+			}
+
+			diff, err := dag.Diff(graphs[0], graphs[1])
+			if err != nil {
+				return err
+			}
+
+			if p.json {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(newGraphDiffJSON(diff))
+			}
+			printGraphDiff(os.Stdout, diff)
+			return nil
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type graphDiffParams struct {
+	graphParams
+
+	json bool
+}
+
+func (p *graphDiffParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().BoolVar(&p.json, "json", false, "print the differences as JSON")
+}
+
+// graphAt returns the graph of the packages in the directory dir of the
+// repository at the revision rev, with subpackages flattened into their
+// origins.
+func (p *graphDiffParams) graphAt(ctx context.Context, repo *git.Repository, rev, dir string) (*dag.Graph, error) {
+	tree, err := wgit.TreeAt(repo, rev)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "." {
+		tree, err = tree.Tree(dir)
+		if err != nil {
+			return nil, fmt.Errorf("finding %q: %w", dir, err)
+		}
+	}
+	fsys := wgit.TreeFS(tree)
+
+	pipelineDirs := p.pipelineDirs
+	if len(pipelineDirs) == 0 {
+		// Pipelines are only read from disk, so write out those at the revision.
+		tmp, err := os.MkdirTemp("", "wolfictl-graph-diff-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+		if err := copyFS(fsys, "pipelines", tmp); err != nil {
+			return nil, fmt.Errorf("writing pipelines: %w", err)
+		}
+		pipelineDirs = []string{tmp}
+	}
+
+	_, g, err := p.buildFrom(ctx, fsys, pipelineDirs)
+	if err != nil {
+		return nil, err
+	}
+	return g.Targets()
+}
+
+// copyFS copies the regular files under root in fsys, if it exists, to dst.
+func copyFS(fsys fs.FS, root, dst string) error {
+	err := fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(path, root), "/")
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		b, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, b, 0o644) //nolint:gosec // pipelines are not secret
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func printGraphDiff(w io.Writer, d *dag.GraphDiff) {
+	if d.Empty() {
+		fmt.Fprintln(w, "No differences")
+		return
+	}
+
+	section := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(lines))
+		for _, line := range lines {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	edges := func(edges []dag.NamedEdge) []string {
+		out := make([]string, 0, len(edges))
+		for _, e := range edges {
+			out = append(out, e.From+" -> "+e.To)
+		}
+		return out
+	}
+	changed := make([]string, 0, len(d.Changed))
+	for _, c := range d.Changed {
+		changed = append(changed, fmt.Sprintf("%s %s -> %s", c.Name, c.From, c.To))
+	}
+
+	section("Packages added", d.Added)
+	section("Packages removed", d.Removed)
+	section("Versions changed", changed)
+	section("Dependencies added", edges(d.AddedEdges))
+	section("Dependencies removed", edges(d.RemovedEdges))
+	section("Packages with changed transitive dependencies", d.ClosureChanged)
+}
+
+type graphDiffJSON struct {
+	Added          []string            `json:"added"`
+	Removed        []string            `json:"removed"`
+	Changed        []versionChangeJSON `json:"changed"`
+	AddedEdges     []namedEdgeJSON     `json:"addedEdges"`
+	RemovedEdges   []namedEdgeJSON     `json:"removedEdges"`
+	ClosureChanged []string            `json:"closureChanged"`
+}
+
+type versionChangeJSON struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type namedEdgeJSON struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func newGraphDiffJSON(d *dag.GraphDiff) graphDiffJSON {
+	out := graphDiffJSON{
+		Added:          append([]string{}, d.Added...),
+		Removed:        append([]string{}, d.Removed...),
+		Changed:        []versionChangeJSON{},
+		AddedEdges:     []namedEdgeJSON{},
+		RemovedEdges:   []namedEdgeJSON{},
+		ClosureChanged: append([]string{}, d.ClosureChanged...),
+	}
+	for _, c := range d.Changed {
+		out.Changed = append(out.Changed, versionChangeJSON{Name: c.Name, From: c.From, To: c.To})
+	}
+	for _, e := range d.AddedEdges {
+		out.AddedEdges = append(out.AddedEdges, namedEdgeJSON{From: e.From, To: e.To})
+	}
+	for _, e := range d.RemovedEdges {
+		out.RemovedEdges = append(out.RemovedEdges, namedEdgeJSON{From: e.From, To: e.To})
+	}
+	return out
+}
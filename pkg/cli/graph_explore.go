@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/cli/components/ctrlcwrapper"
+	"github.com/wolfi-dev/wolfictl/pkg/cli/components/graphexplorer"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func cmdGraphExplore() *cobra.Command {
+	p := &exploreParams{}
+	cmd := &cobra.Command{
+		Use:   "explore <package>",
+		Short: "Explore the dependencies and dependents of a package in the terminal",
+		Long: `Explore the dependencies and dependents of a package in the terminal.
+
+The package's dependencies are shown as a tree, in which each dependency can be expanded to show its own. Each package is shown with its version and where it comes from: a local config, an external repository, or nowhere if it could not be resolved.
+
+Keys:
+
+  ↑/↓, k/j    move the selection
+  →/←, l/h    expand or collapse the selected package, or go to its parent
+  enter       expand or collapse the selected package
+  f           explore from the selected package
+  r           switch between dependencies and dependents
+  t           switch between subpackages and their origins (the targets view)
+  /           search for a package to explore from
+  e           open the selected package's config in $VISUAL or $EDITOR
+  q, ctrl+c   quit`,
+		Example: `
+  wolfictl graph explore openssl
+
+  # Start with what depends on openssl, with subpackages flattened into origins
+  wolfictl graph explore --dependents --targets openssl`,
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, g, err := p.build(cmd.Context(), dag.WithAllowUnresolved())
+			if err != nil {
+				return err
+			}
+
+			editor := os.Getenv("VISUAL")
+			if editor == "" {
+				editor = os.Getenv("EDITOR")
+			}
+			m, err := graphexplorer.New(graphexplorer.Options{
+				Graph:      g,
+				Root:       args[0],
+				Targets:    p.targets,
+				Dependents: p.dependents,
+				Editor:     editor,
+			})
+			if err != nil {
+				return err
+			}
+
+			final, err := tea.NewProgram(ctrlcwrapper.New(m), tea.WithAltScreen()).Run()
+			if err != nil {
+				return fmt.Errorf("running explorer: %w", err)
+			}
+			if w, ok := final.(ctrlcwrapper.Model[graphexplorer.Model]); ok {
+				return w.Unwrap().Error
+			}
+			return nil
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type exploreParams struct {
+	graphParams
+
+	targets    bool
+	dependents bool
+}
+
+func (p *exploreParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().BoolVar(&p.targets, "targets", false, "start with subpackages flattened into their origins")
+	cmd.Flags().BoolVar(&p.dependents, "dependents", false, "start with the package's dependents rather than its dependencies")
+}
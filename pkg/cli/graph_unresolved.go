@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func cmdGraphUnresolved() *cobra.Command {
+	p := &unresolvedParams{}
+	cmd := &cobra.Command{
+		Use:   "unresolved",
+		Short: "List the dependencies that can't be resolved, with suggestions",
+		Long: `List the dependencies that can't be resolved to any local package or package in the repositories, with the configs that declare them.
+
+For each dependency, the command suggests local packages, subpackages and provides entries it might have meant: first those only differing from it in version numbers, such as py3.12-foo for py3-foo, then those closest to it by edit distance, such as openssl for opensl.
+
+The command exits with an error if any dependency can't be resolved.`,
+		Example: `
+  wolfictl graph unresolved
+
+  # Include runtime dependencies, without the public Wolfi repository
+  wolfictl graph unresolved --runtime-deps -r '' -k ''`,
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, g, err := p.build(cmd.Context(), dag.WithAllowUnresolved())
+			if err != nil {
+				return err
+			}
+			dangling, err := g.Dangling(p.suggestions)
+			if err != nil {
+				return err
+			}
+
+			if p.json {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(newDanglingJSON(dangling)); err != nil {
+					return err
+				}
+			} else {
+				printDangling(os.Stdout, dangling)
+			}
+
+			if len(dangling) > 0 {
+				return fmt.Errorf("found %d unresolved dependencies", len(dangling))
+			}
+			return nil
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type unresolvedParams struct {
+	graphParams
+
+	suggestions int
+	json        bool
+}
+
+func (p *unresolvedParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().IntVar(&p.suggestions, "suggestions", 3, "maximum number of suggestions per dependency (0 for no limit)")
+	cmd.Flags().BoolVar(&p.json, "json", false, "print the unresolved dependencies as JSON")
+}
+
+// configPath returns the path of the config defining p, if it's local.
+func configPath(p dag.Package) string {
+	if c, ok := p.(*dag.Configuration); ok {
+		return c.Path
+	}
+	return ""
+}
+
+func printDangling(w io.Writer, dangling []dag.Dangling) {
+	if len(dangling) == 0 {
+		fmt.Fprintln(w, "No unresolved dependencies")
+		return
+	}
+	for _, d := range dangling {
+		fmt.Fprintln(w, d.Dependency)
+		for _, u := range d.Dependents {
+			fmt.Fprintf(w, "  needed by %s in %s (%s)\n", u.Package.Name(), configPath(u.Package), strings.Join(u.Fields(), ", "))
+		}
+		if len(d.Suggestions) > 0 {
+			names := make([]string, 0, len(d.Suggestions))
+			for _, s := range d.Suggestions {
+				if s.Provider != "" {
+					names = append(names, fmt.Sprintf("%s (provided by %s)", s.Name, s.Provider))
+					continue
+				}
+				names = append(names, s.Name)
+			}
+			fmt.Fprintf(w, "  did you mean: %s\n", strings.Join(names, ", "))
+		}
+	}
+}
+
+type danglingJSON struct {
+	Dependency  string               `json:"dependency"`
+	Dependents  []danglingDepJSON    `json:"dependents"`
+	Suggestions []nameSuggestionJSON `json:"suggestions"`
+}
+
+type danglingDepJSON struct {
+	Package string   `json:"package"`
+	Path    string   `json:"path,omitempty"`
+	Fields  []string `json:"fields"`
+}
+
+type nameSuggestionJSON struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider,omitempty"`
+	Stream   bool   `json:"stream"`
+	Distance int    `json:"distance"`
+}
+
+func newDanglingJSON(dangling []dag.Dangling) []danglingJSON {
+	out := make([]danglingJSON, 0, len(dangling))
+	for _, d := range dangling {
+		dj := danglingJSON{
+			Dependency:  d.Dependency,
+			Dependents:  make([]danglingDepJSON, 0, len(d.Dependents)),
+			Suggestions: make([]nameSuggestionJSON, 0, len(d.Suggestions)),
+		}
+		for _, u := range d.Dependents {
+			dj.Dependents = append(dj.Dependents, danglingDepJSON{
+				Package: u.Package.Name(),
+				Path:    configPath(u.Package),
+				Fields:  u.Fields(),
+			})
+		}
+		for _, s := range d.Suggestions {
+			dj.Suggestions = append(dj.Suggestions, nameSuggestionJSON{
+				Name:     s.Name,
+				Provider: s.Provider,
+				Stream:   s.Stream,
+				Distance: s.Distance,
+			})
+		}
+		out = append(out, dj)
+	}
+	return out
+}
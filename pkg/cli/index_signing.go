@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/chainguard-dev/clog"
+	"github.com/spf13/cobra"
+
+	wapk "github.com/wolfi-dev/wolfictl/pkg/apk"
+)
+
+// signingParams are the flags of commands rewriting an APKINDEX.tar.gz, which
+// verify the signature of the index they read and sign the index they write.
+type signingParams struct {
+	keys     []string
+	keyring  []string
+	insecure bool
+}
+
+// addFlagsTo adds the flags to cmd, signing with defaultKeys unless
+// --signing-key is given. Without any key, the index is written unsigned.
+func (p *signingParams) addFlagsTo(cmd *cobra.Command, defaultKeys ...string) {
+	cmd.Flags().StringSliceVar(&p.keys, "signing-key", defaultKeys, "RSA private key to sign the index with, repeated to sign it with several keys, or none to write it unsigned")
+	cmd.Flags().StringSliceVar(&p.keyring, "keyring", []string{"https://packages.wolfi.dev/os/wolfi-signing.rsa.pub"}, "public key, as a path or https URL, to verify the signature of the input index with")
+	cmd.Flags().BoolVar(&p.insecure, "insecure", false, "rewrite the input index without verifying its signature")
+}
+
+// verify verifies the signature of the APKINDEX.tar.gz archive read from src
+// against the keyring. With --insecure, it isn't verified and the keyring
+// isn't even loaded, so that it needn't be reachable.
+func (p signingParams) verify(ctx context.Context, src string, archive []byte) error {
+	log := clog.FromContext(ctx)
+	if p.insecure {
+		log.Warnf("not verifying %s: --insecure", src)
+		return nil
+	}
+
+	keyring, err := wapk.LoadKeyring(ctx, p.keyring...)
+	if err != nil {
+		return fmt.Errorf("loading keyring: %w", err)
+	}
+	key, err := wapk.VerifyIndex(archive, keyring)
+	if err == nil {
+		log.Infof("verified signature of %s with %s", src, key)
+		return nil
+	}
+	if errors.Is(err, wapk.ErrUnsigned) {
+		return fmt.Errorf("%s: %w, use --insecure to rewrite it anyway", src, err)
+	}
+	return fmt.Errorf("verifying %s: %w, use --insecure to rewrite it anyway", src, err)
+}
+
+// sign returns the APKINDEX.tar.gz archive of index, signed with the signing
+// keys if there are any.
+func (p signingParams) sign(index *apk.APKIndex) ([]byte, error) {
+	r, err := apk.ArchiveFromIndex(index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive from index object: %w", err)
+	}
+	archive, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.keys) == 0 {
+		return archive, nil
+	}
+	return wapk.SignIndex(archive, p.keys...)
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+
+	wapk "github.com/wolfi-dev/wolfictl/pkg/apk"
+)
+
+func TestSigningParams(t *testing.T) {
+	index := &apk.APKIndex{Packages: []*apk.Package{{Name: "foo", Version: "1.0-r0"}}}
+
+	// Without signing keys, the index is written unsigned.
+	unsigned, err := signingParams{}.sign(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wapk.VerifyIndex(unsigned, nil); !errors.Is(err, wapk.ErrUnsigned) {
+		t.Errorf("VerifyIndex() of an index signed without keys = %v, want %v", err, wapk.ErrUnsigned)
+	}
+
+	// With --insecure, the keyring isn't loaded at all.
+	missing := signingParams{keyring: []string{filepath.Join(t.TempDir(), "missing.rsa.pub")}}
+	if err := missing.verify(t.Context(), "test index", unsigned); err == nil {
+		t.Error("verify() with a missing keyring didn't fail")
+	}
+	missing.insecure = true
+	if err := missing.verify(t.Context(), "test index", unsigned); err != nil {
+		t.Errorf("verify() with --insecure loaded the keyring: %v", err)
+	}
+}
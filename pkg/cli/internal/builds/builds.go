@@ -1,8 +1,10 @@
 package builds
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
+	"path"
 	"path/filepath"
 	"slices"
 
@@ -132,3 +134,51 @@ type BuildGroup struct {
 	Origin      Package
 	Subpackages []Package
 }
+
+// Packages returns the packages in the given filesystem by architecture, which
+// is laid out like Melange's "packages" directory. The packages of each
+// architecture are read from its APKINDEX.tar.gz if there is one, or from the
+// APK files themselves otherwise, e.g. when Melange was run without signing.
+func Packages(fsys fs.FS) (map[string][]*apk.Package, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read packages dir: %w", err)
+	}
+
+	packages := make(map[string][]*apk.Package)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		arch := e.Name()
+
+		f, err := fsys.Open(path.Join(arch, "APKINDEX.tar.gz"))
+		if err == nil {
+			index, err := apk.IndexFromArchive(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read APKINDEX for %s: %w", arch, err)
+			}
+			packages[arch] = index.Packages
+			continue
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		groups, err := Find(fsys, []string{arch})
+		if err != nil {
+			return nil, err
+		}
+		for _, bg := range groups {
+			for _, p := range append([]Package{bg.Origin}, bg.Subpackages...) {
+				if p.PkgInfo == nil {
+					// A build group without its origin package.
+					continue
+				}
+				packages[arch] = append(packages[arch], p.PkgInfo.AsPackage(nil, uint64(p.FileInfo.Size())))
+			}
+		}
+	}
+	return packages, nil
+}
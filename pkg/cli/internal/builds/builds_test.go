@@ -0,0 +1,42 @@
+package builds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackages(t *testing.T) {
+	// A packages directory with an index for x86_64, and only APKs for aarch64.
+	dir := t.TempDir()
+	for src, dst := range map[string]string{
+		"../../../dag/testdata/packages/x86_64/APKINDEX.tar.gz": "x86_64/APKINDEX.tar.gz",
+		"../../../tar/testdata/hello-wolfi-2.12-r1.apk":         "aarch64/hello-wolfi-2.12-r1.apk",
+	} {
+		b, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(dst)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, dst), b, 0o644))
+	}
+
+	packages, err := Packages(os.DirFS(dir))
+	require.NoError(t, err)
+
+	var found bool
+	for _, p := range packages["x86_64"] {
+		if p.Name == "libffi" {
+			found = true
+			assert.Equal(t, "3.4.2-r0", p.Version)
+		}
+	}
+	assert.True(t, found, "libffi not read from the index")
+
+	require.Len(t, packages["aarch64"], 1)
+	p := packages["aarch64"][0]
+	assert.Equal(t, "hello-wolfi", p.Name)
+	assert.Equal(t, "2.12-r1", p.Version)
+	assert.Equal(t, []string{"so:ld-linux-aarch64.so.1", "so:libc.so.6"}, p.Dependencies)
+}
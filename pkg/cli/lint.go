@@ -13,6 +13,8 @@ type lintOptions struct {
 	list      bool
 	skipRules []string
 	severity  string
+
+	reportUnusedNoLint bool
 }
 
 func cmdLint() *cobra.Command {
@@ -33,6 +35,7 @@ func cmdLint() *cobra.Command {
 	cmd.Flags().BoolVarP(&o.list, "list", "l", false, "prints the all of available rules and exits")
 	cmd.Flags().StringArrayVarP(&o.skipRules, "skip-rule", "", []string{}, "list of rules to skip")
 	cmd.Flags().StringVarP(&o.severity, "severity", "s", "warning", "minimum severity level to report (error, warning, info)")
+	cmd.Flags().BoolVar(&o.reportUnusedNoLint, "report-unused-nolint", false, "report #nolint directives that don't suppress anything")
 
 	cmd.AddCommand(cmdLintYam())
 
@@ -95,6 +98,7 @@ func (o lintOptions) makeLintOptions() [][]lint.Option {
 		opts = append(opts, []lint.Option{
 			lint.WithPath(path),
 			lint.WithSkipRules(o.skipRules),
+			lint.WithReportUnusedNoLint(o.reportUnusedNoLint),
 		})
 	}
 	return opts
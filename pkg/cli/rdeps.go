@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+	"golang.org/x/exp/slices"
+)
+
+func cmdRdeps() *cobra.Command {
+	p := &rdepsParams{}
+	cmd := &cobra.Command{
+		Use:   "rdeps <package>...",
+		Short: "List the packages that depend on the given packages, in rebuild order",
+		Long: `List the packages that depend on the given packages, directly or transitively, in the order they would need to be rebuilt after changing the given packages.
+
+Subpackages are flattened into their origin packages, since that's what gets rebuilt. A subpackage can be given in place of its origin. Use --subpackages to list dependents by package or subpackage name instead.
+
+By default only build-time dependencies (environment.contents.packages) are followed. Use --type runtime to follow runtime dependencies (dependencies.runtime) or --type test to follow test dependencies (test.environment.contents.packages) instead, or give several types to follow any of them.`,
+		Example: `
+  # Everything to rebuild after an openssl change
+  wolfictl rdeps openssl
+
+  # Only the packages that directly use glibc at build time or runtime
+  wolfictl rdeps --depth 1 --type buildtime,runtime glibc
+
+  # The packages whose tests use a test-only tool
+  wolfictl rdeps --depth 1 --type test bats`,
+		Args:          cobra.MinimumNArgs(1),
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			types, err := dag.ParseEdgeTypes(p.types)
+			if err != nil {
+				return err
+			}
+			p.runtimeDeps = slices.Contains(types, dag.EdgeRuntime)
+			p.testDeps = slices.Contains(types, dag.EdgeTest)
+
+			pkgs, g, err := p.build(ctx)
+			if err != nil {
+				return err
+			}
+			if !p.subpackages {
+				g, err = g.Targets()
+				if err != nil {
+					return fmt.Errorf("targets: %w", err)
+				}
+			}
+
+			sorted, err := rebuildOrder(g, pkgs, args, p.depth, types)
+			if err != nil {
+				return err
+			}
+
+			var out []string
+			for _, pkg := range sorted {
+				out = append(out, pkg.Name())
+			}
+			if len(out) > 0 {
+				fmt.Fprintln(os.Stdout, strings.Join(out, "\n"))
+			}
+			return nil
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type rdepsParams struct {
+	graphParams
+
+	depth       int
+	types       []string
+	subpackages bool
+}
+
+// rebuildOrder returns the packages in g depending on the named packages,
+// following dependencies of the given types up to depth edges away, in the
+// order to rebuild them. The named packages themselves aren't included.
+func rebuildOrder(g *dag.Graph, pkgs *dag.Packages, names []string, depth int, types []dag.EdgeType) ([]dag.Package, error) {
+	roots, err := lookupNodes(g, pkgs, names)
+	if err != nil {
+		return nil, err
+	}
+	dependents, err := g.Dependents(roots, depth, types...)
+	if err != nil {
+		return nil, err
+	}
+	sorted, err := dependents.ReverseSorted()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]dag.Package, 0, len(sorted))
+	for _, pkg := range sorted {
+		if slices.Contains(roots, dag.PackageHash(pkg)) {
+			continue
+		}
+		out = append(out, pkg)
+	}
+	return out, nil
+}
+
+func (p *rdepsParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().IntVar(&p.depth, "depth", 0, "maximum number of edges between a package and its listed dependents (0 for no limit, 1 for direct dependents)")
+	cmd.Flags().StringSliceVarP(&p.types, "type", "t", []string{string(dag.EdgeBuildtime)}, "types of dependencies to follow: buildtime, runtime, test")
+	cmd.Flags().BoolVar(&p.subpackages, "subpackages", false, "list subpackages separately instead of flattening them into their origins")
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+	"github.com/wolfi-dev/wolfictl/pkg/graphserver"
+)
+
+func cmdServe() *cobra.Command {
+	p := &serveParams{}
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve queries of the package dependency graph over HTTP",
+		Long: `Serve read-only queries of the package dependency graph over HTTP, answered as JSON.
+
+The graph is built once at startup, and built again whenever a YAML file in the config or pipeline directories changes. Queries are answered from the previous graph until the new one is ready, and if it fails to build, e.g. because of a broken config, the previous graph is kept.
+
+Endpoints:
+
+  GET /packages                        the local origin packages
+  GET /deps/{package}                  the direct dependencies of a package
+  GET /rdeps/{package}?depth=&type=    the packages depending on a package, in rebuild order
+  GET /build-order?package=a,b         the given packages in the order to build them
+  GET /path?from=&to=                  a shortest path of dependencies between two packages
+  GET /unresolved                      the dependencies that could not be resolved
+  GET /status                          when the graph was last loaded
+
+/rdeps follows buildtime dependencies, like wolfictl rdeps, unless other types are listed with ?type=, e.g. ?type=buildtime,runtime. Subpackages are flattened into their origins, unless ?subpackages=true is given to /deps or /rdeps. Errors are answered with a status and {"error": "..."}.`,
+		Example: `
+  wolfictl serve --addr localhost:8080 --runtime-deps
+
+  curl 'localhost:8080/rdeps/openssl?depth=1'
+  curl 'localhost:8080/path?from=curl&to=perl'`,
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			log := clog.FromContext(ctx)
+
+			log.Infof("building graph")
+			s, err := graphserver.New(ctx, graphserver.Options{
+				Load: func(ctx context.Context) (*dag.Packages, *dag.Graph, error) {
+					return p.build(ctx, dag.WithAllowUnresolved())
+				},
+				Dirs:     append([]string{p.dir}, p.defaultPipelineDirs()...),
+				Interval: p.interval,
+			})
+			if err != nil {
+				return err
+			}
+
+			l, err := net.Listen("tcp", p.addr)
+			if err != nil {
+				return err
+			}
+			log.Infof("serving on http://%s", l.Addr())
+			if err := s.Serve(ctx, l); !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type serveParams struct {
+	graphParams
+
+	addr     string
+	interval time.Duration
+}
+
+func (p *serveParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", false, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().StringVar(&p.addr, "addr", "127.0.0.1:8080", "address to listen on")
+	cmd.Flags().DurationVar(&p.interval, "interval", time.Second, "how often to check the configs for changes")
+}
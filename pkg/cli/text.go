@@ -1,10 +1,12 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"chainguard.dev/apko/pkg/build/types"
 	"chainguard.dev/melange/pkg/config"
@@ -14,8 +16,14 @@ import (
 
 func cmdText() *cobra.Command {
 	var dir, arch, t string
+	var maxParallel, shards, shardIndex int
+	var shardWeights string
+	var ninjaMelange, ninjaMelangeFlags string
 	var pipelineDirs []string
 	var extraKeys, extraRepos []string
+	var localBuilds string
+	var testDeps bool
+	var cache graphCacheFlags
 	text := &cobra.Command{
 		Use:   "text",
 		Short: "Print a sorted list of downstream dependent packages",
@@ -23,7 +31,17 @@ func cmdText() *cobra.Command {
 
 If this command is successful, it means there is a solveable dependency graph and the packages can be built in the order they are printed.
 
-If this fails, there may be an unsatisfiable dependency or a cycle in the graph.`,
+If this fails, there may be an unsatisfiable dependency or a cycle in the graph.
+
+With --test-deps, packages are also ordered after the packages their tests depend on (test.environment.contents.packages), so that those exist by the time each package is tested.
+
+With --type waves, packages are instead grouped into waves, one line per wave, where every package only depends on packages in earlier waves. All packages in a wave can be built concurrently. --type waves-json prints the same as JSON. Use --max-parallel to limit the size of each wave to the number of available builders.
+
+With --type ninja, a Ninja build file is printed instead, with one build edge per origin package running melange on its config. Each edge produces the APKs of the package and its subpackages in packages/<arch>, and depends on the APKs of the packages it needs. Builds run --ninja-melange with --ninja-melange-flags, which set the melange and melange_flags variables at the top of the file; to change them, generate the file again. The file is specific to --arch, as the dependencies and the APK paths are resolved for it, so generate one per architecture.
+
+With --type matrix, the packages are printed as a JSON build matrix for GitHub Actions or Buildkite, {"include": [{"package": ..., "arch": ..., "needs": [...]}]}, in build order, where needs are the packages that must be built first.
+
+With --shards N and --shard-index i, only the packages in shard i of N are printed, with any --type. Shards are balanced by package count, or by the weights in --shard-weights, a file with one "<package> <weight>" pair per line. Packages that depend on each other are kept in the same shard where possible, so that shards can be built concurrently. When a group of dependent packages is too large for one shard it is split across consecutive shards, and a package then only depends on packages in the same or an earlier shard.`,
 		Args:   cobra.NoArgs,
 		Hidden: true,
 		RunE: func(cmd *cobra.Command, _ []string) error {
@@ -32,20 +50,59 @@ If this fails, there may be an unsatisfiable dependency or a cycle in the graph.
 				pipelineDirs = []string{filepath.Join(dir, "pipelines")}
 			}
 
+			if maxParallel < 0 {
+				return fmt.Errorf("--max-parallel must be at least 0, got %d", maxParallel)
+			}
+			if shards < 1 {
+				return fmt.Errorf("--shards must be at least 1, got %d", shards)
+			}
+			if shardIndex < 0 || shardIndex >= shards {
+				return fmt.Errorf("--shard-index must be between 0 and %d, got %d", shards-1, shardIndex)
+			}
+
 			arch := types.ParseArchitecture(arch).ToAPK()
 
-			pkgs, err := dag.NewPackages(ctx, os.DirFS(dir), dir, pipelineDirs)
+			pkgsOpts, graphOpts, err := cache.options()
+			if err != nil {
+				return err
+			}
+			builds, err := localBuildsOptions(localBuilds)
+			if err != nil {
+				return err
+			}
+			graphOpts = append(graphOpts, builds...)
+			pkgs, err := dag.NewPackages(ctx, os.DirFS(dir), dir, pipelineDirs, pkgsOpts...)
 			if err != nil {
 				return fmt.Errorf("constructing new package set from directory %q: %w", dir, err)
 			}
-			g, err := dag.NewGraph(ctx, pkgs,
+			graphOpts = append(graphOpts,
 				dag.WithKeys(extraKeys...),
 				dag.WithRepos(extraRepos...),
 				dag.WithArch(arch))
+			if testDeps {
+				graphOpts = append(graphOpts, dag.WithTestDeps())
+			}
+			g, err := dag.NewGraph(ctx, pkgs, graphOpts...)
 			if err != nil {
 				return fmt.Errorf("creating graph: %w", err)
 			}
 
+			if shards > 1 {
+				g, err = shard(g, shards, shardIndex, shardWeights)
+				if err != nil {
+					return fmt.Errorf("sharding graph: %w", err)
+				}
+			}
+
+			switch textType(t) {
+			case typeWaves, typeWavesJSON:
+				return textWaves(g, textType(t), maxParallel, os.Stdout)
+			case typeNinja:
+				return textNinja(g, arch, ninjaMelange, ninjaMelangeFlags, os.Stdout)
+			case typeMatrix:
+				return textMatrix(g, arch, os.Stdout)
+			}
+
 			return text(g, pkgs, arch, textType(t), os.Stdout)
 		},
 	}
@@ -53,8 +110,17 @@ If this fails, there may be an unsatisfiable dependency or a cycle in the graph.
 	text.Flags().StringSliceVar(&pipelineDirs, "pipeline-dir", nil, "directory used to extend defined built-in pipelines")
 	text.Flags().StringVarP(&arch, "arch", "a", "x86_64", "architecture to build for")
 	text.Flags().StringVarP(&t, "type", "t", string(typeTarget), fmt.Sprintf("What type of text to emit; values can be one of: %v", textTypes))
+	text.Flags().IntVar(&maxParallel, "max-parallel", 0, "with --type waves, the maximum number of packages per wave (0 for no limit)")
+	text.Flags().StringVar(&ninjaMelange, "ninja-melange", "melange", "with --type ninja, the melange command to build packages with")
+	text.Flags().StringVar(&ninjaMelangeFlags, "ninja-melange-flags", "--repository-append packages", "with --type ninja, extra flags of melange build")
+	text.Flags().IntVar(&shards, "shards", 1, "number of shards to split the packages into")
+	text.Flags().IntVar(&shardIndex, "shard-index", 0, "with --shards, the zero-based index of the shard to print")
+	text.Flags().StringVar(&shardWeights, "shard-weights", "", "with --shards, file with one \"<package> <weight>\" pair per line to balance shards by (packages not listed weigh 1)")
 	text.Flags().StringSliceVarP(&extraKeys, "keyring-append", "k", []string{"https://packages.wolfi.dev/os/wolfi-signing.rsa.pub"}, "path to extra keys to include in the build environment keyring")
 	text.Flags().StringSliceVarP(&extraRepos, "repository-append", "r", []string{"https://packages.wolfi.dev/os"}, "path to extra repositories to include in the build environment")
+	text.Flags().BoolVar(&testDeps, "test-deps", false, "also order packages after the packages their tests depend on")
+	addLocalBuildsFlagTo(text, &localBuilds)
+	cache.addFlagsTo(text)
 	return text
 }
 
@@ -66,6 +132,10 @@ const (
 	typePackageName           textType = "name"
 	typePackageVersion        textType = "version"
 	typePackageNameAndVersion textType = "name-version"
+	typeWaves                 textType = "waves"
+	typeWavesJSON             textType = "waves-json"
+	typeNinja                 textType = "ninja"
+	typeMatrix                textType = "matrix"
 )
 
 var textTypes = []textType{
@@ -74,6 +144,10 @@ var textTypes = []textType{
 	typePackageName,
 	typePackageVersion,
 	typePackageNameAndVersion,
+	typeWaves,
+	typeWavesJSON,
+	typeNinja,
+	typeMatrix,
 }
 
 func text(g *dag.Graph, pkgs *dag.Packages, arch string, t textType, w io.Writer) error {
@@ -150,6 +224,89 @@ func text(g *dag.Graph, pkgs *dag.Packages, arch string, t textType, w io.Writer
 	return nil
 }
 
+// shard returns the subgraph of g with only the origin packages in the given
+// shard and their subpackages.
+func shard(g *dag.Graph, n, index int, weightsFile string) (*dag.Graph, error) {
+	var weights dag.Weights
+	if weightsFile != "" {
+		f, err := os.Open(weightsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		weights, err = dag.ReadWeights(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading weights from %q: %w", weightsFile, err)
+		}
+	}
+
+	filtered, err := g.Filter(dag.FilterLocal())
+	if err != nil {
+		return nil, err
+	}
+	targets, err := filtered.Targets()
+	if err != nil {
+		return nil, err
+	}
+	shards, err := targets.Shards(n, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	origins := make(map[string]struct{}, len(shards[index]))
+	for _, pkg := range shards[index] {
+		origins[pkg.Name()] = struct{}{}
+	}
+	return g.Filter(func(pkg dag.Package) bool {
+		c, ok := pkg.(*dag.Configuration)
+		if !ok {
+			return false
+		}
+		_, ok = origins[c.Package.Name]
+		return ok
+	})
+}
+
+// textWaves prints the local packages grouped into build waves.
+func textWaves(g *dag.Graph, t textType, maxParallel int, w io.Writer) error {
+	filtered, err := g.Filter(dag.FilterLocal())
+	if err != nil {
+		return err
+	}
+	targets, err := filtered.Targets()
+	if err != nil {
+		return err
+	}
+	waves, err := targets.Waves(maxParallel)
+	if err != nil {
+		return err
+	}
+
+	type wave struct {
+		Wave     int      `json:"wave"`
+		Packages []string `json:"packages"`
+	}
+	out := make([]wave, 0, len(waves))
+	for i, pkgs := range waves {
+		names := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			names = append(names, pkg.Name())
+		}
+		out = append(out, wave{Wave: i + 1, Packages: names})
+	}
+
+	if t == typeWavesJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	for _, wave := range out {
+		fmt.Fprintf(w, "%s\n", strings.Join(wave.Packages, " "))
+	}
+	return nil
+}
+
 func makefileEntry(pkgName string, p *config.Package) string {
 	return fmt.Sprintf("$(eval $(call build-package,%s,%s-%d))", pkgName, p.Version, p.Epoch)
 }
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+// planStep is a local origin package to build, after the local origin packages
+// it needs.
+type planStep struct {
+	config *dag.Configuration
+	needs  []*dag.Configuration
+}
+
+// buildPlan returns the local origin packages in g in the order to build them.
+func buildPlan(g *dag.Graph) ([]planStep, error) {
+	filtered, err := g.Filter(dag.FilterLocal())
+	if err != nil {
+		return nil, err
+	}
+	targets, err := filtered.Targets()
+	if err != nil {
+		return nil, err
+	}
+	sorted, err := targets.ReverseSorted()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]planStep, 0, len(sorted))
+	for _, pkg := range sorted {
+		c, ok := pkg.(*dag.Configuration)
+		if !ok {
+			return nil, fmt.Errorf("unexpected package %s in build plan", dag.PackageHash(pkg))
+		}
+		step := planStep{config: c}
+		for _, dep := range targets.DependenciesOf(dag.PackageHash(pkg)) {
+			d, err := targets.Graph.Vertex(dep)
+			if err != nil {
+				return nil, err
+			}
+			dc, ok := d.(*dag.Configuration)
+			if !ok {
+				return nil, fmt.Errorf("unexpected dependency %s of %s in build plan", dep, c.Name())
+			}
+			step.needs = append(step.needs, dc)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// apkPaths returns the paths of the APKs that building c produces for arch,
+// the origin package first.
+func apkPaths(c *dag.Configuration, arch string) []string {
+	version := fmt.Sprintf("%s-r%d", c.Package.Version, c.Package.Epoch)
+	paths := []string{path.Join("packages", arch, fmt.Sprintf("%s-%s.apk", c.Package.Name, version))}
+	for _, sp := range c.Subpackages {
+		paths = append(paths, path.Join("packages", arch, fmt.Sprintf("%s-%s.apk", sp.Name, version)))
+	}
+	return paths
+}
+
+// ninjaEscape escapes the characters that are special in ninja paths.
+func ninjaEscape(s string) string {
+	return strings.NewReplacer("$", "$$", " ", "$ ", ":", "$:", "\n", "$\n").Replace(s)
+}
+
+func ninjaPaths(paths []string) string {
+	escaped := make([]string, 0, len(paths))
+	for _, p := range paths {
+		escaped = append(escaped, ninjaEscape(p))
+	}
+	return strings.Join(escaped, " ")
+}
+
+// ninjaValue escapes s for the value of a ninja variable, where unlike in
+// paths spaces and colons are literal.
+func ninjaValue(s string) string {
+	return strings.NewReplacer("$", "$$", "\n", "$\n").Replace(s)
+}
+
+// textNinja prints a ninja build file building the local packages with
+// melange, one build edge per origin package, producing the APKs of the package
+// and its subpackages after those of the packages it needs. Builds run the
+// melange command with melangeFlags added.
+func textNinja(g *dag.Graph, arch, melange, melangeFlags string, w io.Writer) error {
+	steps, err := buildPlan(g)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "# Generated by wolfictl text --type ninja. Do not edit.\n\n")
+	fmt.Fprintf(w, "arch = %s\n", arch)
+	fmt.Fprintf(w, "melange = %s\n", ninjaValue(melange))
+	fmt.Fprintf(w, "melange_flags = %s\n\n", ninjaValue(melangeFlags))
+	fmt.Fprintf(w, "rule melange\n")
+	fmt.Fprintf(w, "  command = $melange build $in --arch $arch --out-dir packages $melange_flags\n")
+	fmt.Fprintf(w, "  description = melange build $name ($arch)\n")
+
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		var needs []string
+		for _, n := range step.needs {
+			needs = append(needs, apkPaths(n, arch)[0])
+		}
+		fmt.Fprintf(w, "\nbuild %s: melange %s", ninjaPaths(apkPaths(step.config, arch)), ninjaEscape(step.config.Path))
+		if len(needs) > 0 {
+			fmt.Fprintf(w, " | %s", ninjaPaths(needs))
+		}
+		fmt.Fprintf(w, "\n  name = %s\n", step.config.Package.Name)
+		fmt.Fprintf(w, "build %s: phony %s\n", ninjaEscape(step.config.Package.Name), ninjaEscape(apkPaths(step.config, arch)[0]))
+		names = append(names, ninjaEscape(step.config.Package.Name))
+	}
+
+	if len(names) > 0 {
+		fmt.Fprintf(w, "\ndefault %s\n", strings.Join(names, " "))
+	}
+	return nil
+}
+
+type matrixJSON struct {
+	Include []matrixEntryJSON `json:"include"`
+}
+
+type matrixEntryJSON struct {
+	Package string   `json:"package"`
+	Arch    string   `json:"arch"`
+	Needs   []string `json:"needs"`
+}
+
+// textMatrix prints the local packages as a CI build matrix, in the order to
+// build them, each with the packages it needs built first.
+func textMatrix(g *dag.Graph, arch string, w io.Writer) error {
+	steps, err := buildPlan(g)
+	if err != nil {
+		return err
+	}
+
+	out := matrixJSON{Include: make([]matrixEntryJSON, 0, len(steps))}
+	for _, step := range steps {
+		entry := matrixEntryJSON{
+			Package: step.config.Package.Name,
+			Arch:    arch,
+			Needs:   make([]string, 0, len(step.needs)),
+		}
+		for _, n := range step.needs {
+			entry.Needs = append(entry.Needs, n.Package.Name)
+		}
+		out.Include = append(out.Include, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func testPlanGraph(t *testing.T) *dag.Graph {
+	t.Helper()
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"lib.yaml": &fstest.MapFile{Data: []byte(`package:
+  name: lib
+  version: "1.0"
+  epoch: 2
+subpackages:
+  - name: lib-dev
+pipeline:
+  - runs: echo lib
+`)},
+		"app.yaml": &fstest.MapFile{Data: []byte(`package:
+  name: app
+  version: "2.0"
+  epoch: 0
+environment:
+  contents:
+    packages:
+      - lib-dev
+pipeline:
+  - runs: echo app
+`)},
+	}
+	pkgs, err := dag.NewPackages(ctx, fsys, "os", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := dag.NewGraph(ctx, pkgs, dag.WithArch("x86_64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestTextNinja(t *testing.T) {
+	var buf bytes.Buffer
+	if err := textNinja(testPlanGraph(t), "x86_64", "melange", "--repository-append packages", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Generated by wolfictl text --type ninja. Do not edit.
+
+arch = x86_64
+melange = melange
+melange_flags = --repository-append packages
+
+rule melange
+  command = $melange build $in --arch $arch --out-dir packages $melange_flags
+  description = melange build $name ($arch)
+
+build packages/x86_64/lib-1.0-r2.apk packages/x86_64/lib-dev-1.0-r2.apk: melange os/lib.yaml
+  name = lib
+build lib: phony packages/x86_64/lib-1.0-r2.apk
+
+build packages/x86_64/app-2.0-r0.apk: melange os/app.yaml | packages/x86_64/lib-1.0-r2.apk
+  name = app
+build app: phony packages/x86_64/app-2.0-r0.apk
+
+default lib app
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("ninja mismatch (-want +got):\n%s", diff)
+	}
+
+	// The melange command and its flags are set when generating the file.
+	buf.Reset()
+	if err := textNinja(testPlanGraph(t), "x86_64", "/usr/local/bin/melange", "--runner docker --env-file $HOME/env", &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"melange = /usr/local/bin/melange\n",
+		"melange_flags = --runner docker --env-file $$HOME/env\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("ninja file doesn't contain %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestTextMatrix(t *testing.T) {
+	var buf bytes.Buffer
+	if err := textMatrix(testPlanGraph(t), "x86_64", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got matrixJSON
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := matrixJSON{Include: []matrixEntryJSON{
+		{Package: "lib", Arch: "x86_64", Needs: []string{}},
+		{Package: "app", Arch: "x86_64", Needs: []string{"lib"}},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("matrix mismatch (-want +got):\n%s", diff)
+	}
+}
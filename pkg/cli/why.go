@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dominikbraun/graph"
+	"github.com/spf13/cobra"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+func cmdWhy() *cobra.Command {
+	p := &whyParams{}
+	cmd := &cobra.Command{
+		Use:   "why <package> <dependency>",
+		Short: "Explain why a package depends on another",
+		Long: `Explain why a package depends on another, directly or transitively, by printing the shortest path of dependencies between them.
+
+For each hop, the output shows the field of the melange config that declared the dependency (environment.contents.packages for build-time dependencies, dependencies.runtime for runtime dependencies, or subpackages for a subpackage depending on its origin), and, when the dependency was satisfied by a provides entry rather than a package name, that entry.`,
+		Example: `
+  # Why does building curl pull in perl?
+  wolfictl why curl perl
+
+  # Every way in which app depends on openssl
+  wolfictl why --all app openssl`,
+		Args:          cobra.ExactArgs(2),
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkgs, g, err := p.build(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			sources, err := lookupNodes(g, pkgs, args[:1])
+			if err != nil {
+				return err
+			}
+			targets, err := lookupNodes(g, pkgs, args[1:])
+			if err != nil {
+				return err
+			}
+
+			var paths [][]dag.Hop
+			for _, source := range sources {
+				for _, target := range targets {
+					found, err := p.paths(g, source, target)
+					if errors.Is(err, graph.ErrTargetNotReachable) {
+						continue
+					}
+					if err != nil {
+						return err
+					}
+					paths = append(paths, found...)
+				}
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("%s does not depend on %s", args[0], args[1])
+			}
+			if !p.all {
+				shortest := paths[0]
+				for _, path := range paths[1:] {
+					if len(path) < len(shortest) {
+						shortest = path
+					}
+				}
+				paths = [][]dag.Hop{shortest}
+			}
+
+			for i, path := range paths {
+				if i > 0 {
+					fmt.Fprintln(os.Stdout)
+				}
+				printPath(os.Stdout, path)
+			}
+			return nil
+		},
+	}
+	p.addFlagsTo(cmd)
+	return cmd
+}
+
+type whyParams struct {
+	graphParams
+
+	all bool
+}
+
+func (p *whyParams) addFlagsTo(cmd *cobra.Command) {
+	p.graphParams.addFlagsTo(cmd)
+	cmd.Flags().BoolVar(&p.runtimeDeps, "runtime-deps", true, "include runtime dependencies in the graph")
+	cmd.Flags().BoolVar(&p.testDeps, "test-deps", false, "include test dependencies in the graph")
+	cmd.Flags().BoolVar(&p.all, "all", false, "print every path instead of only the shortest")
+}
+
+func (p *whyParams) paths(g *dag.Graph, source, target string) ([][]dag.Hop, error) {
+	if p.all {
+		paths, err := g.AllPaths(source, target)
+		if err == nil && len(paths) == 0 {
+			err = graph.ErrTargetNotReachable
+		}
+		return paths, err
+	}
+	hops, err := g.ShortestPath(source, target)
+	if err != nil {
+		return nil, err
+	}
+	return [][]dag.Hop{hops}, nil
+}
+
+// printPath prints the packages on path, followed by one line per hop
+// explaining the dependency.
+func printPath(w io.Writer, path []dag.Hop) {
+	names := []string{path[0].From.Name()}
+	for _, hop := range path {
+		names = append(names, hop.To.Name())
+	}
+	fmt.Fprintln(w, strings.Join(names, " -> "))
+
+	for _, hop := range path {
+		fmt.Fprintf(w, "  %s\n", describeHop(hop))
+	}
+}
+
+// describeHop describes the dependency of a hop, including the config field
+// that declared it and the provides entry that satisfied it.
+func describeHop(hop dag.Hop) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s -> %s: %s", hopName(hop.From), hopName(hop.To), strings.Join(hop.Fields(), ", "))
+	if hop.Dependency != "" {
+		fmt.Fprintf(&b, " %q", hop.Dependency)
+	}
+	if hop.Provides != "" {
+		fmt.Fprintf(&b, ", provided by %s as %q", hop.To.Name(), hop.Provides)
+	}
+	return b.String()
+}
+
+func hopName(p dag.Package) string {
+	if p.Version() == "" {
+		return p.Name()
+	}
+	return p.Name() + "-" + p.Version()
+}
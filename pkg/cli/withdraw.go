@@ -2,114 +2,235 @@ package cli
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
-
-	"golang.org/x/exp/slices"
+	"time"
 
 	"chainguard.dev/apko/pkg/apk/apk"
-	"chainguard.dev/melange/pkg/sign"
 	"github.com/chainguard-dev/clog"
 
 	"github.com/spf13/cobra"
+
+	wapk "github.com/wolfi-dev/wolfictl/pkg/apk"
 )
 
 func cmdWithdraw() *cobra.Command {
-	key := ""
-	withdrawPackagesFile := ""
+	signing := signingParams{}
+	p := withdrawParams{}
+	repo := repoParams{}
 	cmd := &cobra.Command{
-		Use:           "withdraw example-pkg-1.2.3-r4",
-		Short:         "Withdraw packages from an APKINDEX.tar.gz",
-		Example:       "withdraw --signing-key ./foo.rsa example-pkg-1.2.3-r4 also-bad-2.3.4-r1 <old/APKINDEX.tar.gz >new/APKINDEX.tar.gz",
+		Use:   "withdraw example-pkg-1.2.3-r4",
+		Short: "Withdraw packages from an APKINDEX.tar.gz",
+		Example: `withdraw --signing-key ./foo.rsa --keyring ./foo.rsa.pub example-pkg-1.2.3-r4 also-bad-2.3.4-r1 <old/APKINDEX.tar.gz >new/APKINDEX.tar.gz
+withdraw 'openssl<3.1.4-r2' 'py3-*~1.2' <old/APKINDEX.tar.gz >new/APKINDEX.tar.gz
+withdraw --origin 'openssl' --built-after 2024-01-02 --built-before 2024-01-03T12:00:00Z <old/APKINDEX.tar.gz >new/APKINDEX.tar.gz
+withdraw --repo gs://example-bucket/os --quarantine withdrawn example-pkg-1.2.3-r4`,
+		Long: `Withdraw packages from an APKINDEX.tar.gz
+
+The index is read from stdin and written without the withdrawn packages
+to stdout. The signature of the input index is verified against the
+keys in --keyring first, and an index without a valid signature is
+refused unless --insecure is given. The new index is signed with each
+of the keys given with --signing-key.
+
+Packages are selected by the arguments and the lines of --packages-file,
+each one of:
+
+  - an exact name-version, such as foo-1.2.3-r4, with an optional .apk suffix
+  - a glob of names, such as 'py3-*', selecting all their versions
+  - a glob of names with a version constraint, such as 'openssl<3.1.4-r2'
+
+--origin selects packages the same way by their origin instead of their
+name, withdrawing the origin package together with all its subpackages.
+--built-after and --built-before restrict the selected packages to
+those built in that range, as RFC 3339 times or dates.
+
+Before writing the new index, the dependencies of the remaining packages
+are resolved against their names and provides: if any dependency was
+only satisfied by withdrawn packages, the broken dependencies are
+reported and no index is written, unless --allow-broken is given.
+
+With --repo, the packages are withdrawn from <arch>/APKINDEX.tar.gz
+under the root of a package repository, a local directory or a gs://
+path, for each of the architectures given with --arches or supported by
+the distro. Every index is checked before any is written, and a summary
+of the withdrawn packages is printed per architecture. The command fails
+if a selector matches nothing in some architecture. With --quarantine,
+the withdrawn .apk files are moved to <quarantine>/<arch>/ under the
+root once the indexes are written.`,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			gone := make(map[string]bool)
-
-			// Add packages from command line arguments
-			for _, s := range args {
-				gone[strings.TrimSuffix(s, ".apk")] = false
+			wd, err := p.withdrawal(args)
+			if err != nil {
+				return err
 			}
-
-			// Add packages from file if specified
-			if withdrawPackagesFile != "" {
-				filePackages, err := readPackagesFromFile(withdrawPackagesFile)
-				if err != nil {
-					return fmt.Errorf("reading packages file: %w", err)
-				}
-				for _, pkg := range filePackages {
-					gone[strings.TrimSuffix(pkg, ".apk")] = false
-				}
+			if repo.root == "" && (repo.quarantine != "" || len(repo.arches) > 0) {
+				return fmt.Errorf("--arches and --quarantine require --repo")
 			}
-
-			return withdraw(cmd.Context(), cmd.OutOrStdout(), cmd.InOrStdin(), key, gone)
+			if repo.root != "" {
+				return withdrawFromRepo(cmd.Context(), cmd.OutOrStdout(), repo, signing, wd, p.allowBroken)
+			}
+			return withdraw(cmd.Context(), cmd.OutOrStdout(), cmd.InOrStdin(), signing, wd, p.allowBroken)
 		},
 	}
 
-	cmd.Flags().StringVar(&key, "signing-key", "melange.rsa", "the signing key to use")
-	cmd.Flags().StringVar(&withdrawPackagesFile, "packages-file", "", "file containing list of packages to withdraw (one per line, supports comments with #)")
+	signing.addFlagsTo(cmd, "melange.rsa")
+	p.addFlagsTo(cmd)
+	repo.addFlagsTo(cmd)
 
 	return cmd
 }
 
-func withdraw(ctx context.Context, w io.Writer, r io.Reader, key string, gone map[string]bool) error {
-	log := clog.FromContext(ctx)
+// withdrawParams are the flags selecting the packages to withdraw.
+type withdrawParams struct {
+	packagesFile string
+	origins      []string
+	builtAfter   string
+	builtBefore  string
+	allowBroken  bool
+}
 
-	index, err := apk.IndexFromArchive(io.NopCloser(r))
-	if err != nil {
-		return fmt.Errorf("failed to read apkindex from archive file: %w", err)
-	}
+func (p *withdrawParams) addFlagsTo(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&p.packagesFile, "packages-file", "", "file containing list of packages to withdraw (one per line, supports comments with #)")
+	cmd.Flags().StringSliceVar(&p.origins, "origin", nil, "withdraw the packages built from the origins matching this selector, with all their subpackages")
+	cmd.Flags().StringVar(&p.builtAfter, "built-after", "", "only withdraw packages built after this time")
+	cmd.Flags().StringVar(&p.builtBefore, "built-before", "", "only withdraw packages built before this time")
+	cmd.Flags().BoolVar(&p.allowBroken, "allow-broken", false, "write the index even if withdrawing breaks dependencies of remaining packages")
+}
 
-	index.Packages = slices.DeleteFunc(index.Packages, func(pkg *apk.Package) bool {
-		pkgver := pkg.Name + "-" + pkg.Version
-		_, ok := gone[pkgver]
-		if ok {
-			log.Infof("withdrawing %q", pkgver)
-			gone[pkgver] = true
+// withdrawal returns the selection of packages to withdraw given by the flags
+// and the package selectors in args.
+func (p withdrawParams) withdrawal(args []string) (wapk.Withdrawal, error) {
+	specs := append([]string(nil), args...)
+	if p.packagesFile != "" {
+		filePackages, err := readPackagesFromFile(p.packagesFile)
+		if err != nil {
+			return wapk.Withdrawal{}, fmt.Errorf("reading packages file: %w", err)
 		}
-		return ok
-	})
+		specs = append(specs, filePackages...)
+	}
 
-	for pkg, ok := range gone {
-		if !ok {
-			log.Warnf("did not withdraw %q", pkg)
+	var wd wapk.Withdrawal
+	for _, spec := range specs {
+		s, err := wapk.ParseSelector(spec, false)
+		if err != nil {
+			return wapk.Withdrawal{}, err
 		}
+		wd.Selectors = append(wd.Selectors, s)
+	}
+	for _, spec := range p.origins {
+		s, err := wapk.ParseSelector(spec, true)
+		if err != nil {
+			return wapk.Withdrawal{}, err
+		}
+		wd.Selectors = append(wd.Selectors, s)
+	}
+	if len(wd.Selectors) == 0 {
+		return wapk.Withdrawal{}, fmt.Errorf("no packages to withdraw")
 	}
 
-	archive, err := apk.ArchiveFromIndex(index)
-	if err != nil {
-		return fmt.Errorf("failed to create archive from index object: %w", err)
+	var err error
+	if wd.BuiltAfter, err = parseBuildTime(p.builtAfter); err != nil {
+		return wapk.Withdrawal{}, fmt.Errorf("parsing --built-after: %w", err)
+	}
+	if wd.BuiltBefore, err = parseBuildTime(p.builtBefore); err != nil {
+		return wapk.Withdrawal{}, fmt.Errorf("parsing --built-before: %w", err)
 	}
+	return wd, nil
+}
 
-	tmp, err := os.CreateTemp("", "wolifctl-withdraw")
-	if err != nil {
-		return fmt.Errorf("creating temp file: %w", err)
+// parseBuildTime parses an RFC 3339 time or a date, the zero time if s is
+// empty.
+func parseBuildTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
 	}
+	return time.Parse(time.RFC3339, s)
+}
 
-	if _, err := io.Copy(tmp, archive); err != nil {
-		return fmt.Errorf("writing temp file: %w", err)
+func withdraw(ctx context.Context, w io.Writer, r io.Reader, signing signingParams, wd wapk.Withdrawal, allowBroken bool) error {
+	log := clog.FromContext(ctx)
+
+	archive, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading apkindex: %w", err)
+	}
+	res, err := withdrawFromIndex(ctx, "input index", archive, signing, wd, allowBroken)
+	if err != nil {
+		return err
+	}
+	for _, s := range res.unused {
+		log.Warnf("did not withdraw %q", s.Spec)
+	}
+	if _, err := w.Write(res.signed); err != nil {
+		return fmt.Errorf("copying index: %w", err)
 	}
 
-	if err := tmp.Close(); err != nil {
-		return fmt.Errorf("close temp: %w", err)
+	return nil
+}
+
+// withdrawnIndex is an index with packages withdrawn.
+type withdrawnIndex struct {
+	// signed is the signed APKINDEX.tar.gz archive of the new index.
+	signed []byte
+
+	withdrawn []*apk.Package
+
+	// unused are the selectors that didn't select any package.
+	unused []wapk.Selector
+}
+
+// withdrawFromIndex withdraws the packages selected by wd from the
+// APKINDEX.tar.gz archive read from src, after verifying its signature, and
+// signs the new index. It fails if withdrawing the packages breaks
+// dependencies of the remaining ones, unless allowBroken.
+func withdrawFromIndex(ctx context.Context, src string, archive []byte, signing signingParams, wd wapk.Withdrawal, allowBroken bool) (*withdrawnIndex, error) {
+	log := clog.FromContext(ctx)
+
+	if err := signing.verify(ctx, src, archive); err != nil {
+		return nil, err
 	}
 
-	if err := sign.SignIndex(ctx, key, tmp.Name()); err != nil {
-		return fmt.Errorf("signing index: %w", err)
+	index, err := apk.IndexFromArchive(io.NopCloser(bytes.NewReader(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apkindex from archive file: %w", err)
 	}
 
-	signed, err := os.Open(tmp.Name())
+	withdrawn, unused, err := wd.Apply(index)
 	if err != nil {
-		return fmt.Errorf("opening %s: %w", tmp.Name(), err)
+		return nil, err
+	}
+	for _, pkg := range withdrawn {
+		log.Infof("withdrawing %q from %s", pkg.Name+"-"+pkg.Version, src)
 	}
 
-	if _, err := io.Copy(w, signed); err != nil {
-		return fmt.Errorf("copying index: %w", err)
+	broken, err := wapk.BrokenDependencies(index.Packages, withdrawn)
+	if err != nil {
+		return nil, fmt.Errorf("resolving dependencies of remaining packages: %w", err)
+	}
+	for _, b := range broken {
+		var by []string
+		for _, pkg := range b.Withdrawn {
+			by = append(by, pkg.Name+"-"+pkg.Version)
+		}
+		log.Warnf("%s-%s depends on %q, only provided by withdrawn %s", b.Package.Name, b.Package.Version, b.Dependency, strings.Join(by, ", "))
+	}
+	if len(broken) > 0 && !allowBroken {
+		return nil, fmt.Errorf("withdrawing would break %d dependencies of remaining packages, use --allow-broken to withdraw anyway", len(broken))
 	}
 
-	return nil
+	signed, err := signing.sign(index)
+	if err != nil {
+		return nil, fmt.Errorf("signing index: %w", err)
+	}
+	return &withdrawnIndex{signed: signed, withdrawn: withdrawn, unused: unused}, nil
 }
 
 // readPackagesFromFile reads package names from a file, skipping blank lines and comments
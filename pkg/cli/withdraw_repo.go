@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/chainguard-dev/clog"
+	"github.com/spf13/cobra"
+
+	wapk "github.com/wolfi-dev/wolfictl/pkg/apk"
+	"github.com/wolfi-dev/wolfictl/pkg/distro"
+)
+
+// repoParams are the flags of withdraw operating on the indexes of every
+// architecture of a package repository instead of an index on stdin.
+type repoParams struct {
+	root       string
+	arches     []string
+	quarantine string
+}
+
+func (p *repoParams) addFlagsTo(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&p.root, "repo", "", "root of the package repository to withdraw from, as a local directory or a gs:// path, instead of reading an index from stdin")
+	cmd.Flags().StringSliceVar(&p.arches, "arches", nil, "with --repo, architectures to withdraw from (default: the architectures supported by the distro)")
+	cmd.Flags().StringVar(&p.quarantine, "quarantine", "", "with --repo, move the withdrawn .apk files to <arch>/ under this path, relative to the root of the repository")
+}
+
+// resolveArches returns the architectures to withdraw from, detecting the
+// distro's supported architectures if none were given.
+func (p repoParams) resolveArches() ([]string, error) {
+	if len(p.arches) > 0 {
+		return p.arches, nil
+	}
+	d, err := distro.Detect()
+	if err != nil {
+		return nil, fmt.Errorf("no architectures specified, and distro auto-detection failed: %w", err)
+	}
+	return d.Absolute.SupportedArchitectures, nil
+}
+
+// withdrawFromRepo withdraws the packages selected by wd from the index of
+// each architecture of the repository, and prints a summary per architecture
+// to w. The indexes are all rewritten or none is: any index failing to verify
+// or left with broken dependencies fails the withdrawal before anything is
+// written. Selectors matching nothing in an architecture fail the command once
+// the indexes are written.
+func withdrawFromRepo(ctx context.Context, w io.Writer, p repoParams, signing signingParams, wd wapk.Withdrawal, allowBroken bool) error {
+	log := clog.FromContext(ctx)
+
+	arches, err := p.resolveArches()
+	if err != nil {
+		return err
+	}
+	store, err := openRepoStore(ctx, p.root)
+	if err != nil {
+		return err
+	}
+
+	type archWithdrawal struct {
+		arch string
+		*withdrawnIndex
+	}
+	var results []archWithdrawal
+	for _, arch := range arches {
+		name := path.Join(arch, "APKINDEX.tar.gz")
+		archive, err := store.read(ctx, name)
+		if err != nil {
+			return err
+		}
+		res, err := withdrawFromIndex(ctx, store.url(name), archive, signing, wd, allowBroken)
+		if err != nil {
+			return fmt.Errorf("%s: %w", arch, err)
+		}
+		results = append(results, archWithdrawal{arch: arch, withdrawnIndex: res})
+	}
+
+	// The indexes stop referring to the withdrawn packages before they're
+	// moved away.
+	for _, res := range results {
+		name := path.Join(res.arch, "APKINDEX.tar.gz")
+		if err := store.write(ctx, name, res.signed); err != nil {
+			return err
+		}
+		log.Infof("wrote %s", store.url(name))
+	}
+	if p.quarantine != "" {
+		for _, res := range results {
+			for _, pkg := range res.withdrawn {
+				from, to := path.Join(res.arch, pkg.Filename()), path.Join(p.quarantine, res.arch, pkg.Filename())
+				if err := store.move(ctx, from, to); errors.Is(err, fs.ErrNotExist) {
+					log.Warnf("not moving %s: %v", store.url(from), err)
+					continue
+				} else if err != nil {
+					return err
+				}
+				log.Infof("moved %s to %s", store.url(from), store.url(to))
+			}
+		}
+	}
+
+	misses := 0
+	for _, res := range results {
+		fmt.Fprintf(w, "%s: withdrew %d packages\n", res.arch, len(res.withdrawn))
+		for _, pkg := range res.withdrawn {
+			fmt.Fprintf(w, "  - %s-%s\n", pkg.Name, pkg.Version)
+		}
+		for _, s := range res.unused {
+			fmt.Fprintf(w, "  ! %s: not found\n", s.Spec)
+		}
+		misses += len(res.unused)
+	}
+	if misses > 0 {
+		return fmt.Errorf("%d package selectors matched nothing", misses)
+	}
+	return nil
+}
+
+// repoStore reads and writes the files of a package repository by their paths
+// relative to its root.
+type repoStore interface {
+	read(ctx context.Context, name string) ([]byte, error)
+	write(ctx context.Context, name string, b []byte) error
+	move(ctx context.Context, from, to string) error
+
+	// url returns the location of the file, for messages.
+	url(name string) string
+}
+
+// openRepoStore returns the store of the repository at root, a local directory
+// or a gs://bucket/prefix path.
+func openRepoStore(ctx context.Context, root string) (repoStore, error) {
+	if !strings.HasPrefix(root, "gs://") {
+		return dirStore(root), nil
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(root, "gs://"), "/")
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCS client: %w", err)
+	}
+	return &gcsStore{bucket: client.Bucket(bucket), name: bucket, prefix: prefix, generations: map[string]int64{}}, nil
+}
+
+// dirStore is a repository in a local directory.
+type dirStore string
+
+func (d dirStore) read(_ context.Context, name string) ([]byte, error) {
+	return os.ReadFile(d.url(name))
+}
+
+func (d dirStore) write(_ context.Context, name string, b []byte) error {
+	return os.WriteFile(d.url(name), b, 0o644)
+}
+
+func (d dirStore) move(_ context.Context, from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(d.url(to)), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(d.url(from), d.url(to))
+}
+
+func (d dirStore) url(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
+
+// gcsStore is a repository under a prefix of a GCS bucket. Objects are only
+// overwritten if they weren't changed since they were read.
+type gcsStore struct {
+	bucket *storage.BucketHandle
+	name   string
+	prefix string
+
+	// generations are the generations of the objects read.
+	generations map[string]int64
+}
+
+func (g *gcsStore) object(name string) *storage.ObjectHandle {
+	return g.bucket.Object(path.Join(g.prefix, name))
+}
+
+func (g *gcsStore) read(ctx context.Context, name string) ([]byte, error) {
+	r, err := g.object(name).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", g.url(name), err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", g.url(name), err)
+	}
+	g.generations[name] = r.Attrs.Generation
+	return b, nil
+}
+
+func (g *gcsStore) write(ctx context.Context, name string, b []byte) error {
+	obj := g.object(name)
+	if gen, ok := g.generations[name]; ok {
+		obj = obj.If(storage.Conditions{GenerationMatch: gen})
+	}
+	w := obj.NewWriter(ctx)
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return fmt.Errorf("writing %s: %w", g.url(name), err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", g.url(name), err)
+	}
+	return nil
+}
+
+func (g *gcsStore) move(ctx context.Context, from, to string) error {
+	src := g.object(from)
+	if _, err := g.object(to).CopierFrom(src).Run(ctx); err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return fmt.Errorf("moving %s: %w", g.url(from), fs.ErrNotExist)
+		}
+		return fmt.Errorf("copying %s to %s: %w", g.url(from), g.url(to), err)
+	}
+	if err := src.Delete(ctx); err != nil {
+		return fmt.Errorf("deleting %s: %w", g.url(from), err)
+	}
+	return nil
+}
+
+func (g *gcsStore) url(name string) string {
+	return "gs://" + path.Join(g.name, g.prefix, name)
+}
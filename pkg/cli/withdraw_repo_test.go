@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/google/go-cmp/cmp"
+
+	wapk "github.com/wolfi-dev/wolfictl/pkg/apk"
+	"github.com/wolfi-dev/wolfictl/pkg/apk/apktest"
+)
+
+func TestWithdrawFromRepo(t *testing.T) {
+	keys := t.TempDir()
+	upstream := apktest.WriteSigningKey(t, keys, "upstream.rsa")
+	signing := signingParams{keys: []string{upstream}, keyring: []string{upstream + ".pub"}}
+
+	// writeRepo writes a repository with the given packages per architecture,
+	// and an .apk file for each of them.
+	writeRepo := func(t *testing.T, pkgs map[string][]*apk.Package) string {
+		t.Helper()
+		root := t.TempDir()
+		for arch, pkgs := range pkgs {
+			if err := os.MkdirAll(filepath.Join(root, arch), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for _, pkg := range pkgs {
+				if err := os.WriteFile(filepath.Join(root, arch, pkg.Filename()), []byte(pkg.Name), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r, err := apk.ArchiveFromIndex(&apk.APKIndex{Packages: pkgs})
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed, err := wapk.SignIndex(archive, upstream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(root, arch, "APKINDEX.tar.gz"), signed, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return root
+	}
+	readIndex := func(t *testing.T, root, arch string) []string {
+		t.Helper()
+		f, err := os.Open(filepath.Join(root, arch, "APKINDEX.tar.gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		index, err := apk.IndexFromArchive(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, pkg := range index.Packages {
+			got = append(got, pkg.Name+"-"+pkg.Version)
+		}
+		return got
+	}
+	exists := func(root string, elem ...string) bool {
+		_, err := os.Stat(filepath.Join(append([]string{root}, elem...)...))
+		return err == nil
+	}
+
+	repo := map[string][]*apk.Package{
+		"x86_64": {
+			{Name: "foo", Version: "1.0-r0", Arch: "x86_64"},
+			{Name: "bar", Version: "2.0-r0", Arch: "x86_64"},
+		},
+		"aarch64": {
+			{Name: "foo", Version: "1.0-r0", Arch: "aarch64"},
+			{Name: "bar", Version: "2.0-r0", Arch: "aarch64"},
+			{Name: "baz", Version: "3.0-r0", Arch: "aarch64"},
+		},
+	}
+
+	t.Run("every architecture", func(t *testing.T) {
+		root := writeRepo(t, repo)
+		p := repoParams{root: root, arches: []string{"x86_64", "aarch64"}, quarantine: "withdrawn"}
+		var out bytes.Buffer
+		if err := withdrawFromRepo(t.Context(), &out, p, signing, withdrawal(t, "foo-1.0-r0"), false); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, arch := range p.arches {
+			if got := readIndex(t, root, arch); len(got) == 0 || strings.Contains(strings.Join(got, " "), "foo") {
+				t.Errorf("%s: remaining packages %v", arch, got)
+			}
+			if exists(root, arch, "foo-1.0-r0.apk") || !exists(root, "withdrawn", arch, "foo-1.0-r0.apk") {
+				t.Errorf("%s: foo-1.0-r0.apk wasn't quarantined", arch)
+			}
+		}
+		want := `x86_64: withdrew 1 packages
+  - foo-1.0-r0
+aarch64: withdrew 1 packages
+  - foo-1.0-r0
+`
+		if diff := cmp.Diff(want, out.String()); diff != "" {
+			t.Errorf("summary mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("misses", func(t *testing.T) {
+		root := writeRepo(t, repo)
+		p := repoParams{root: root, arches: []string{"x86_64", "aarch64"}}
+		var out bytes.Buffer
+		if err := withdrawFromRepo(t.Context(), &out, p, signing, withdrawal(t, "baz"), false); err == nil {
+			t.Error("withdrawFromRepo() didn't fail on baz missing from x86_64")
+		}
+		if !strings.Contains(out.String(), "! baz: not found") {
+			t.Errorf("summary doesn't report the miss:\n%s", out.String())
+		}
+		// The indexes are still written, and the .apk files left in place.
+		if got := readIndex(t, root, "aarch64"); len(got) != 2 {
+			t.Errorf("aarch64: remaining packages %v", got)
+		}
+		if !exists(root, "aarch64", "baz-3.0-r0.apk") {
+			t.Error("baz-3.0-r0.apk was moved without --quarantine")
+		}
+	})
+
+	t.Run("nothing written on failure", func(t *testing.T) {
+		broken := map[string][]*apk.Package{
+			"x86_64":  repo["x86_64"],
+			"aarch64": append(repo["aarch64"][:2:2], &apk.Package{Name: "qux", Version: "1-r0", Arch: "aarch64", Dependencies: []string{"foo"}}),
+		}
+		root := writeRepo(t, broken)
+		before, err := os.ReadFile(filepath.Join(root, "x86_64", "APKINDEX.tar.gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := repoParams{root: root, arches: []string{"x86_64", "aarch64"}, quarantine: "withdrawn"}
+		if err := withdrawFromRepo(t.Context(), io.Discard, p, signing, withdrawal(t, "foo"), false); err == nil {
+			t.Fatal("withdrawFromRepo() didn't refuse to break qux")
+		}
+		after, err := os.ReadFile(filepath.Join(root, "x86_64", "APKINDEX.tar.gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(before, after) {
+			t.Error("x86_64 index was rewritten")
+		}
+		if !exists(root, "x86_64", "foo-1.0-r0.apk") {
+			t.Error("foo-1.0-r0.apk was quarantined")
+		}
+	})
+}
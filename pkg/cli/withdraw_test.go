@@ -1,10 +1,19 @@
 package cli
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/google/go-cmp/cmp"
+
+	wapk "github.com/wolfi-dev/wolfictl/pkg/apk"
+	"github.com/wolfi-dev/wolfictl/pkg/apk/apktest"
 )
 
 func TestReadPackagesFromFile(t *testing.T) {
@@ -100,3 +109,149 @@ func TestReadPackagesFromFileNotFound(t *testing.T) {
 		t.Error("readPackagesFromFile() expected error for non-existent file, got nil")
 	}
 }
+
+// withdrawal returns the withdrawal of the packages selected by args.
+func withdrawal(t *testing.T, args ...string) wapk.Withdrawal {
+	t.Helper()
+	wd, err := withdrawParams{}.withdrawal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
+
+func TestWithdraw(t *testing.T) {
+	dir := t.TempDir()
+	upstream := apktest.WriteSigningKey(t, dir, "upstream.rsa")
+	first, second := apktest.WriteSigningKey(t, dir, "first.rsa"), apktest.WriteSigningKey(t, dir, "second.rsa")
+
+	r, err := apk.ArchiveFromIndex(&apk.APKIndex{Packages: []*apk.Package{
+		{Name: "foo", Version: "1.0-r0"},
+		{Name: "foo", Version: "1.0-r1"},
+		{Name: "bar", Version: "2.0-r0"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := wapk.SignIndex(unsigned, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signing := signingParams{keys: []string{first, second}, keyring: []string{upstream + ".pub"}}
+
+	t.Run("signed", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := withdraw(t.Context(), &out, bytes.NewReader(signed), signing, withdrawal(t, "foo-1.0-r0", "missing-1-r0"), false); err != nil {
+			t.Fatal(err)
+		}
+
+		index, err := apk.IndexFromArchive(io.NopCloser(bytes.NewReader(out.Bytes())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, pkg := range index.Packages {
+			got = append(got, pkg.Name+"-"+pkg.Version)
+		}
+		if diff := cmp.Diff([]string{"foo-1.0-r1", "bar-2.0-r0"}, got); diff != "" {
+			t.Errorf("remaining packages mismatch (-want +got):\n%s", diff)
+		}
+
+		// The new index is signed with each of the signing keys.
+		for _, key := range []string{first, second} {
+			keyring, err := wapk.LoadKeyring(t.Context(), key+".pub")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := wapk.VerifyIndex(out.Bytes(), keyring); err != nil {
+				t.Errorf("verifying with %s: %v", key, err)
+			}
+		}
+	})
+
+	t.Run("unverified input", func(t *testing.T) {
+		for name, input := range map[string][]byte{"unsigned": unsigned, "untrusted": signed} {
+			signing := signing
+			if name == "untrusted" {
+				signing.keyring = []string{first + ".pub"}
+			}
+			var out bytes.Buffer
+			if err := withdraw(t.Context(), &out, bytes.NewReader(input), signing, withdrawal(t, "bar"), false); err == nil {
+				t.Errorf("%s: withdraw() didn't refuse the input", name)
+			}
+			if out.Len() != 0 {
+				t.Errorf("%s: withdraw() wrote an index", name)
+			}
+
+			signing.insecure = true
+			if err := withdraw(t.Context(), &out, bytes.NewReader(input), signing, withdrawal(t, "bar"), false); err != nil {
+				t.Errorf("%s: withdraw() with --insecure: %v", name, err)
+			}
+		}
+	})
+}
+
+func TestWithdrawBrokenDependencies(t *testing.T) {
+	r, err := apk.ArchiveFromIndex(&apk.APKIndex{Packages: []*apk.Package{
+		{Name: "openssl", Version: "3.1.3-r0", Origin: "openssl", Provides: []string{"cmd:openssl=3.1.3-r0"}},
+		{Name: "libssl3", Version: "3.1.3-r0", Origin: "openssl", Provides: []string{"so:libssl.so.3=3"}},
+		{Name: "curl", Version: "8.0-r0", Dependencies: []string{"so:libssl.so.3"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signing := signingParams{keys: []string{apktest.WriteSigningKey(t, t.TempDir(), "test.rsa")}, insecure: true}
+	wd, err := withdrawParams{origins: []string{"openssl<3.1.4"}}.withdrawal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := withdraw(t.Context(), &out, bytes.NewReader(archive), signing, wd, false); err == nil {
+		t.Error("withdraw() didn't refuse to break curl")
+	}
+	if out.Len() != 0 {
+		t.Error("withdraw() wrote an index with broken dependencies")
+	}
+
+	if err := withdraw(t.Context(), &out, bytes.NewReader(archive), signing, wd, true); err != nil {
+		t.Fatalf("withdraw() with --allow-broken: %v", err)
+	}
+	index, err := apk.IndexFromArchive(io.NopCloser(bytes.NewReader(out.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Packages) != 1 || index.Packages[0].Name != "curl" {
+		t.Errorf("remaining packages: %v", index.Packages)
+	}
+}
+
+func TestWithdrawParams(t *testing.T) {
+	if _, err := (withdrawParams{}).withdrawal(nil); err == nil {
+		t.Error("withdrawal() without selectors didn't fail")
+	}
+
+	wd, err := withdrawParams{origins: []string{"*"}, builtAfter: "2024-01-02", builtBefore: "2024-01-03T12:00:00Z"}.withdrawal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC); !wd.BuiltAfter.Equal(want) {
+		t.Errorf("BuiltAfter = %v, want %v", wd.BuiltAfter, want)
+	}
+	if want := time.Date(2024, time.January, 3, 12, 0, 0, 0, time.UTC); !wd.BuiltBefore.Equal(want) {
+		t.Errorf("BuiltBefore = %v, want %v", wd.BuiltBefore, want)
+	}
+
+	if _, err := (withdrawParams{origins: []string{"*"}, builtAfter: "yesterday"}).withdrawal(nil); err == nil {
+		t.Error("withdrawal() with an invalid --built-after didn't fail")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"chainguard.dev/apko/pkg/build/types"
 	"chainguard.dev/melange/pkg/config"
 	"github.com/stretchr/testify/require"
 	"github.com/wolfi-dev/wolfictl/pkg/configs/rwfs/os/testerfs"
@@ -101,3 +102,67 @@ func TestBuildConfigsIndexUpdateSubpackages(t *testing.T) {
 		t.Errorf("unexpected file modification results (-want, +got):\n%s", diff)
 	}
 }
+
+func TestBuildConfigsIndexUpdateKeepsComments(t *testing.T) {
+	testfiles := []string{
+		"config-5.yaml",
+	}
+
+	fsys, err := testerfs.NewWithFileMask(
+		os.DirFS("testdata/rwfs-index"),
+		testfiles...,
+	)
+	require.NoError(t, err)
+
+	index, err := NewIndexFromPaths(context.Background(), fsys, testfiles...)
+	require.NoError(t, err)
+
+	packageSectionUpdater := NewPackageSectionUpdater(func(cfg config.Configuration) (config.Package, error) {
+		p := cfg.Package
+		p.Name = "foobar"
+		return p, nil
+	})
+
+	s := index.Select().WhereName("commented")
+	err = s.Update(context.Background(), packageSectionUpdater)
+	require.NoError(t, err)
+
+	if diff := fsys.DiffAll(); diff != "" {
+		t.Errorf("unexpected file modification results (-want, +got):\n%s", diff)
+	}
+}
+
+func TestBuildConfigsIndexUpdateMovesCommentsWithItems(t *testing.T) {
+	testfiles := []string{
+		"config-6.yaml",
+	}
+
+	fsys, err := testerfs.NewWithFileMask(
+		os.DirFS("testdata/rwfs-index"),
+		testfiles...,
+	)
+	require.NoError(t, err)
+
+	index, err := NewIndexFromPaths(context.Background(), fsys, testfiles...)
+	require.NoError(t, err)
+
+	// Removing busybox at index 0 mustn't move its comment to build-base, and
+	// the comment of the edited step goes away with its old command.
+	environmentSectionUpdater := NewEnvironmentSectionUpdater(func(cfg config.Configuration) (types.ImageConfiguration, error) {
+		env := cfg.Environment
+		env.Contents.Packages = append(env.Contents.Packages[1:], "ca-certificates-bundle")
+		return env, nil
+	})
+	pipelineSectionUpdater := NewPipelineSectionUpdater(func(cfg config.Configuration) ([]config.Pipeline, error) {
+		pipes := cfg.Pipeline
+		pipes[1].Runs = "make install"
+		return pipes, nil
+	})
+
+	require.NoError(t, index.Select().WhereName("reordered").Update(context.Background(), environmentSectionUpdater))
+	require.NoError(t, index.Select().WhereName("reordered").Update(context.Background(), pipelineSectionUpdater))
+
+	if diff := fsys.DiffAll(); diff != "" {
+		t.Errorf("unexpected file modification results (-want, +got):\n%s", diff)
+	}
+}
@@ -83,12 +83,17 @@ func (t *testFile) Info() (fs.FileInfo, error) {
 	return t.fileInfo, nil
 }
 
-// Stat helps implement fs.File.
+// Stat helps implement fs.File. Files loaded from the underlying filesystem are
+// described by the info observed there, since their path is relative to it.
 func (t *testFile) Stat() (fs.FileInfo, error) {
 	if log := t.logger; log != nil {
 		log.Debug("testFile: Stat", "path", t.path)
 	}
 
+	if t.fileInfo != nil {
+		return t.fileInfo, nil
+	}
+
 	return os.Stat(t.path)
 }
 
@@ -26,7 +26,8 @@ type SectionUpdater[K any, T Configuration] func(configuration T) (K, error)
 
 // NewTargetedYAMLASTMutater returns a YAMLASTMutater designed to update a
 // single "section" of the YAML AST. The section is root-level mapping key, the
-// data of which is described by type K.
+// data of which is described by type K. Comments in the section are kept on the
+// keys and items that are still present after the update.
 func NewTargetedYAMLASTMutater[K any, T Configuration](
 	sectionName string,
 	updater SectionUpdater[K, T],
@@ -47,11 +48,74 @@ func NewTargetedYAMLASTMutater[K any, T Configuration](
 			return err
 		}
 
+		previous := *sectionNode
 		err = sectionNode.Encode(updatedSectionData)
 		if err != nil {
 			return err
 		}
+		copyComments(&previous, sectionNode)
 
 		return nil
 	}
 }
+
+// copyComments copies the comments of the nodes in the "from" YAML AST to the
+// corresponding nodes in the "to" AST. Mapping entries are matched by key, and
+// sequence items by identity (see itemIdentity), so that comments follow their
+// items when items are inserted, removed or reordered. The comments of items
+// that are gone, or that have no identity, are dropped.
+func copyComments(from, to *yaml.Node) {
+	to.HeadComment = from.HeadComment
+	to.LineComment = from.LineComment
+	to.FootComment = from.FootComment
+
+	switch {
+	case from.Kind == yaml.MappingNode && to.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(to.Content); i += 2 {
+			for j := 0; j+1 < len(from.Content); j += 2 {
+				if from.Content[j].Value == to.Content[i].Value {
+					copyComments(from.Content[j], to.Content[i])
+					copyComments(from.Content[j+1], to.Content[i+1])
+					break
+				}
+			}
+		}
+
+	case from.Kind == yaml.SequenceNode && to.Kind == yaml.SequenceNode:
+		// Repeated identities, like several "uses: strip" steps, are matched
+		// in order.
+		items := make(map[string][]*yaml.Node)
+		for _, item := range from.Content {
+			if id, ok := itemIdentity(item); ok {
+				items[id] = append(items[id], item)
+			}
+		}
+		for _, item := range to.Content {
+			id, ok := itemIdentity(item)
+			if !ok || len(items[id]) == 0 {
+				continue
+			}
+			copyComments(items[id][0], item)
+			items[id] = items[id][1:]
+		}
+	}
+}
+
+// itemIdentity returns what identifies a sequence item across updates: the
+// value of a scalar, or the name, uses or runs of a mapping such as a pipeline
+// step or a subpackage.
+func itemIdentity(n *yaml.Node) (string, bool) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return "=" + n.Value, true
+	case yaml.MappingNode:
+		for _, key := range []string{"name", "uses", "runs"} {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == key {
+					return key + "=" + n.Content[i+1].Value, true
+				}
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,276 @@
+package dag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+)
+
+// ArchGraphs are the graphs of the same packages for several architectures.
+type ArchGraphs struct {
+	// Arches are the architectures, in the order given to NewArchGraphs.
+	Arches []string
+
+	// Graphs are the graphs by architecture, with subpackages flattened into
+	// their origins. Each only has the packages that build for its
+	// architecture.
+	Graphs map[string]*Graph
+
+	packages *Packages
+}
+
+// NewArchGraphs builds the graph of pkgs for each of the given architectures,
+// only including the packages whose target-architecture includes it.
+// Unresolved dependencies don't fail the build of a graph, see Report. Any
+// WithArch or WithAllowUnresolved in options is overridden.
+func NewArchGraphs(ctx context.Context, pkgs *Packages, arches []string, options ...GraphOptions) (*ArchGraphs, error) {
+	if len(arches) == 0 {
+		return nil, fmt.Errorf("no architectures given")
+	}
+	ag := &ArchGraphs{
+		Arches:   arches,
+		Graphs:   make(map[string]*Graph, len(arches)),
+		packages: pkgs,
+	}
+	for _, arch := range arches {
+		archPkgs, err := pkgs.WithArch(arch)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arch, err)
+		}
+		opts := append(append([]GraphOptions{}, options...), WithArch(arch), WithAllowUnresolved())
+		g, err := NewGraph(ctx, archPkgs, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arch, err)
+		}
+		g, err = g.Targets()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arch, err)
+		}
+		ag.Graphs[arch] = g
+	}
+	return ag, nil
+}
+
+// ArchReport is what differs between the graphs of several architectures.
+type ArchReport struct {
+	// Divergent are the packages whose dependencies differ by architecture.
+	Divergent []ArchDivergence
+
+	// Partial are the packages that only build on some architectures, while
+	// some of their dependents build on more and are left with an unresolved
+	// dependency there.
+	Partial []PartialArch
+
+	// Unresolved are the dependencies that could not be resolved on some of
+	// the architectures the package builds on, but could be on others.
+	Unresolved []ArchUnresolved
+}
+
+// ArchDivergence is a package whose dependencies differ by architecture.
+type ArchDivergence struct {
+	Package string
+
+	// Only are, by architecture, the dependencies the package only has on
+	// some of its architectures.
+	Only map[string][]string
+}
+
+// PartialArch is a package that doesn't build on some architectures that some
+// of its dependents build on and need it.
+type PartialArch struct {
+	Package string
+	Arches  []string
+	Missing []string
+
+	// Dependents are the packages that build on at least one of the missing
+	// architectures and miss the package there.
+	Dependents []string
+}
+
+// ArchUnresolved is a dependency that could only be resolved on some of the
+// architectures the package builds on.
+type ArchUnresolved struct {
+	Package    string
+	Dependency string
+
+	// Arches are the architectures on which the dependency is unresolved.
+	Arches []string
+}
+
+// Empty reports whether there are no differences between architectures.
+func (r *ArchReport) Empty() bool {
+	return len(r.Divergent) == 0 && len(r.Partial) == 0 && len(r.Unresolved) == 0
+}
+
+// Report compares the graphs of the architectures.
+func (ag *ArchGraphs) Report() (*ArchReport, error) {
+	// local maps each arch to the local packages building on it, and those to
+	// the names of their dependencies.
+	local := make(map[string]map[string]map[string]struct{}, len(ag.Arches))
+	// unresolved maps each arch to the unresolved dependencies by package.
+	unresolved := make(map[string]map[string]map[string]struct{}, len(ag.Arches))
+	for _, arch := range ag.Arches {
+		g := ag.Graphs[arch]
+		amap, err := g.Graph.AdjacencyMap()
+		if err != nil {
+			return nil, err
+		}
+		local[arch] = map[string]map[string]struct{}{}
+		for node, deps := range amap {
+			p, err := g.Graph.Vertex(node)
+			if err != nil {
+				return nil, err
+			}
+			if p.Source() != Local {
+				continue
+			}
+			names := map[string]struct{}{}
+			for dep := range deps {
+				d, err := g.Graph.Vertex(dep)
+				if err != nil {
+					return nil, err
+				}
+				names[d.Name()] = struct{}{}
+			}
+			local[arch][p.Name()] = names
+		}
+
+		unresolved[arch] = map[string]map[string]struct{}{}
+		found, err := g.Unresolved()
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range found {
+			if unresolved[arch][u.Package.Name()] == nil {
+				unresolved[arch][u.Package.Name()] = map[string]struct{}{}
+			}
+			unresolved[arch][u.Package.Name()][u.Dependency] = struct{}{}
+		}
+	}
+
+	// archesOf maps each local package to the architectures it builds on.
+	archesOf := map[string][]string{}
+	for _, arch := range ag.Arches {
+		for name := range local[arch] {
+			archesOf[name] = append(archesOf[name], arch)
+		}
+	}
+	names := sortedKeys(archesOf)
+
+	report := &ArchReport{}
+	for _, name := range names {
+		arches := archesOf[name]
+		if len(arches) < 2 {
+			continue
+		}
+
+		only := map[string][]string{}
+		for _, arch := range arches {
+			for dep := range local[arch][name] {
+				everywhere := true
+				for _, other := range arches {
+					if _, ok := local[other][name][dep]; !ok {
+						everywhere = false
+						break
+					}
+				}
+				if !everywhere {
+					only[arch] = append(only[arch], dep)
+				}
+			}
+		}
+		if len(only) > 0 {
+			for arch := range only {
+				sort.Strings(only[arch])
+			}
+			report.Divergent = append(report.Divergent, ArchDivergence{Package: name, Only: only})
+		}
+
+		deps := map[string]map[string]struct{}{}
+		for _, arch := range arches {
+			for dep := range unresolved[arch][name] {
+				if deps[dep] == nil {
+					deps[dep] = map[string]struct{}{}
+				}
+				deps[dep][arch] = struct{}{}
+			}
+		}
+		for _, dep := range sortedKeys(deps) {
+			if len(deps[dep]) == len(arches) {
+				// Unresolved everywhere, not an architecture-specific problem.
+				continue
+			}
+			report.Unresolved = append(report.Unresolved, ArchUnresolved{
+				Package:    name,
+				Dependency: dep,
+				Arches:     ag.ordered(deps[dep]),
+			})
+		}
+	}
+
+	for _, name := range names {
+		arches := archesOf[name]
+		if len(arches) == len(ag.Arches) {
+			continue
+		}
+		has := map[string]struct{}{}
+		for _, arch := range arches {
+			has[arch] = struct{}{}
+		}
+		var missing []string
+		for _, arch := range ag.Arches {
+			if _, ok := has[arch]; !ok {
+				missing = append(missing, arch)
+			}
+		}
+
+		// A dependent is affected if, where the package doesn't build, one of
+		// its dependencies is left unresolved that the package would provide.
+		dependents := map[string]struct{}{}
+		for _, m := range missing {
+			for dependent, deps := range unresolved[m] {
+				for dep := range deps {
+					if ag.providedBy(dep, name) {
+						dependents[dependent] = struct{}{}
+					}
+				}
+			}
+		}
+		if len(dependents) == 0 {
+			continue
+		}
+		report.Partial = append(report.Partial, PartialArch{
+			Package:    name,
+			Arches:     arches,
+			Missing:    missing,
+			Dependents: sortedKeys(dependents),
+		})
+	}
+
+	return report, nil
+}
+
+// providedBy reports whether the dependency dep, as declared with its version
+// constraint if any, is the origin package name, one of its subpackages or
+// something one of them provides, on any architecture.
+func (ag *ArchGraphs) providedBy(dep, name string) bool {
+	for _, c := range ag.packages.Config(apk.ResolvePackageNameVersionPin(dep).Name, false) {
+		if c.Package.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ordered returns the architectures in set in the order of ag.Arches.
+func (ag *ArchGraphs) ordered(set map[string]struct{}) []string {
+	var out []string
+	for _, arch := range ag.Arches {
+		if _, ok := set[arch]; ok {
+			out = append(out, arch)
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package dag
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchGraphs(t *testing.T) {
+	ctx := context.Background()
+
+	// libx only builds on x86_64, and virtual is provided by a different
+	// package on each architecture.
+	fsys := fstest.MapFS{
+		"libx.yaml":     testConfig{name: "libx", arch: "x86_64"}.file(),
+		"impl-x86.yaml": testConfig{name: "impl-x86", arch: "x86_64", provides: []string{"virtual=1"}}.file(),
+		"impl-arm.yaml": testConfig{name: "impl-arm", arch: "aarch64", provides: []string{"virtual=1"}}.file(),
+		"app.yaml":      testConfig{name: "app", deps: []string{"libx", "virtual"}}.file(),
+		"tool.yaml":     testConfig{name: "tool", deps: []string{"missing"}}.file(),
+	}
+	pkgs, err := NewPackages(ctx, fsys, ".", nil)
+	require.NoError(t, err)
+
+	ag, err := NewArchGraphs(ctx, pkgs, []string{"x86_64", "aarch64"})
+	require.NoError(t, err)
+	require.Len(t, ag.Graphs, 2)
+
+	report, err := ag.Report()
+	require.NoError(t, err)
+	assert.False(t, report.Empty())
+
+	assert.Equal(t, []ArchDivergence{{
+		Package: "app",
+		Only: map[string][]string{
+			"x86_64":  {"impl-x86"},
+			"aarch64": {"impl-arm"},
+		},
+	}}, report.Divergent)
+	assert.Equal(t, []PartialArch{{
+		Package:    "libx",
+		Arches:     []string{"x86_64"},
+		Missing:    []string{"aarch64"},
+		Dependents: []string{"app"},
+	}}, report.Partial)
+	// tool's dependency is unresolved everywhere, so it isn't reported.
+	assert.Equal(t, []ArchUnresolved{{
+		Package:    "app",
+		Dependency: "libx",
+		Arches:     []string{"aarch64"},
+	}}, report.Unresolved)
+
+	_, err = NewArchGraphs(ctx, pkgs, nil)
+	assert.Error(t, err)
+}
+
+func TestArchGraphsVersionedDependency(t *testing.T) {
+	ctx := context.Background()
+
+	// app's dependency on libx is versioned, which doesn't keep it from being
+	// a dependent of libx where libx doesn't build.
+	fsys := fstest.MapFS{
+		"libx.yaml": testConfig{name: "libx", arch: "x86_64"}.file(),
+		"app.yaml":  testConfig{name: "app", deps: []string{"libx>=1.0"}}.file(),
+	}
+	pkgs, err := NewPackages(ctx, fsys, ".", nil)
+	require.NoError(t, err)
+
+	ag, err := NewArchGraphs(ctx, pkgs, []string{"x86_64", "aarch64"})
+	require.NoError(t, err)
+
+	report, err := ag.Report()
+	require.NoError(t, err)
+	assert.Equal(t, []PartialArch{{
+		Package:    "libx",
+		Arches:     []string{"x86_64"},
+		Missing:    []string{"aarch64"},
+		Dependents: []string{"app"},
+	}}, report.Partial)
+	assert.Equal(t, []ArchUnresolved{{
+		Package:    "app",
+		Dependency: "libx>=1.0",
+		Arches:     []string{"aarch64"},
+	}}, report.Unresolved)
+}
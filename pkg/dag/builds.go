@@ -0,0 +1,45 @@
+package dag
+
+import (
+	"path/filepath"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+)
+
+// LocalBuilds are packages built locally, e.g. by melange into its packages
+// directory in the current session, by architecture.
+type LocalBuilds struct {
+	// Dir is the directory the packages were built into, with a subdirectory
+	// for each architecture. It is the source of the packages in the graph.
+	Dir string
+
+	// Packages are the packages by architecture.
+	Packages map[string][]*apk.Package
+}
+
+// index returns the index of the packages built for arch, or nil if there are
+// none.
+func (b *LocalBuilds) index(arch string) apk.NamedIndex {
+	if b == nil || len(b.Packages[arch]) == 0 {
+		return nil
+	}
+	repo := apk.Repository{URI: filepath.Join(b.Dir, arch)}
+	index := &apk.APKIndex{
+		Description: "local builds",
+		Packages:    b.Packages[arch],
+	}
+	// Like the local repository, the index is unnamed: apk only resolves
+	// dependencies pinned with @name against a named one.
+	return apk.NewNamedRepositoryWithIndex("", repo.WithIndex(index))
+}
+
+// WithLocalBuilds resolves dependencies against the packages in builds too,
+// alongside the local configs and any repositories. Unlike repositories, built
+// packages need no keys, so this also works offline and while bootstrapping,
+// before anything has been published.
+func WithLocalBuilds(builds *LocalBuilds) GraphOptions {
+	return func(o *graphOptions) error {
+		o.builds = builds
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package dag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBuilds(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("resolve cycle", func(t *testing.T) {
+		// The same as resolving the cycle against the repository in
+		// testdata/cycle/packages, but without its key.
+		testDir := "testdata/cycle"
+		dir := filepath.Join(testDir, "packages")
+		f, err := os.Open(filepath.Join(dir, "x86_64", "APKINDEX.tar.gz"))
+		require.NoError(t, err)
+		index, err := apk.IndexFromArchive(f)
+		require.NoError(t, err)
+
+		pkgs, err := NewPackages(ctx, os.DirFS(testDir), testDir, nil)
+		require.NoError(t, err)
+		graph, err := NewGraph(ctx, pkgs, WithLocalBuilds(&LocalBuilds{
+			Dir:      dir,
+			Packages: map[string][]*apk.Package{"x86_64": index.Packages},
+		}))
+		require.NoError(t, err)
+
+		amap, err := graph.Graph.AdjacencyMap()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"b:1.2.3-r1@local", "c:1.5.5-r1@local", "d:1.0.0-r0@testdata/cycle/packages/x86_64"}, sortedKeys(amap["a:1.3.5-r1@local"]))
+		assert.ElementsMatch(t, []string{"a:1.3.5-r1@local"}, sortedKeys(amap["d:2.0.0-r1@local"]))
+	})
+
+	t.Run("by arch", func(t *testing.T) {
+		testDir := "testdata/subpackages"
+		builds := &LocalBuilds{
+			Dir: "packages",
+			Packages: map[string][]*apk.Package{
+				"x86_64":  {{Name: "busybox", Version: "1.36.1-r0", Arch: "x86_64", Origin: "busybox"}},
+				"aarch64": {{Name: "wolfi-baselayout", Version: "20230201-r0", Arch: "aarch64", Origin: "wolfi-baselayout"}},
+			},
+		}
+		pkgs, err := NewPackages(ctx, os.DirFS(testDir), testDir, nil)
+		require.NoError(t, err)
+		graph, err := NewGraph(ctx, pkgs, WithAllowUnresolved(), WithLocalBuilds(builds))
+		require.NoError(t, err)
+
+		amap, err := graph.Graph.AdjacencyMap()
+		require.NoError(t, err)
+		assert.Contains(t, amap["one:1.2.3-r1@local"], "busybox:1.36.1-r0@packages/x86_64")
+
+		unresolved, err := graph.Unresolved()
+		require.NoError(t, err)
+		for _, u := range unresolved {
+			assert.Equal(t, "wolfi-baselayout", u.Dependency)
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package dag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+)
+
+// cacheVersion is part of every cache key, to be changed whenever what is
+// cached changes so that older entries are ignored.
+const cacheVersion = "2"
+
+// Cache is an on-disk cache of compiled configurations and resolved
+// dependencies, so that building the graph of unchanged configs doesn't
+// compile and resolve them all again.
+//
+// Entries are addressed by the digests of everything they were computed from:
+// the content of a config and the pipelines it could use, and the indexes a
+// dependency was resolved against. Changing a config only invalidates the
+// entries it affects. Stale entries are never read again and the directory can
+// be removed at any time.
+type Cache struct {
+	dir string
+}
+
+// NewCache returns a Cache in the given directory, creating it if needed.
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// DefaultCacheDir returns the directory in the user's cache directory where
+// the graph is cached by default.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wolfictl", "graph"), nil
+}
+
+func (c *Cache) path(kind, key string) string {
+	return filepath.Join(c.dir, kind, key[:2], key+".json")
+}
+
+// get decodes the entry of the given kind and key into v, reporting whether
+// there was one. A corrupt entry is treated as missing.
+func (c *Cache) get(kind, key string, v any) bool {
+	b, err := os.ReadFile(c.path(kind, key))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+// put stores v as the entry of the given kind and key. The entry is written to
+// a temporary file first, so that concurrent readers never see a partial one.
+func (c *Cache) put(kind, key string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	p := c.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// cacheKey returns the key for an entry computed from the given parts.
+func cacheKey(parts ...string) string {
+	h := sha256.New()
+	io.WriteString(h, cacheVersion)
+	for _, part := range parts {
+		// Separate the parts so that they can't run into each other.
+		fmt.Fprintf(h, "\x00%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// melangeVersion returns the version of melange wolfictl is built with, which
+// decides how configs are parsed and which built-in pipelines they can use.
+func melangeVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == "chainguard.dev/melange" {
+			if dep.Replace != nil {
+				return dep.Replace.Path + "@" + dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// pipelinesDigest returns the digest of the content of the pipeline
+// directories, which configs can use pipelines from. Missing directories are
+// part of the digest, so that creating one changes it.
+func pipelinesDigest(pipelineDirs []string) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, melangeVersion())
+	for i, dir := range pipelineDirs {
+		// Only the content matters, not where the directory is.
+		fmt.Fprintf(h, "\x00dir %d\n", i)
+		err := fs.WalkDir(os.DirFS(dir), ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			b, err := os.ReadFile(filepath.Join(dir, path))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s %s\n", path, digest(b))
+			return nil
+		})
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			io.WriteString(h, "missing\n")
+		case err != nil:
+			return "", fmt.Errorf("hashing pipeline directory %q: %w", dir, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolutionCache caches what the resolvers of a graph find for each
+// dependency.
+//
+// A dependency resolved against the local repository is keyed by the local
+// packages that could fulfill it, rather than the whole repository, so that
+// changing a config only invalidates the resolutions of what it provides.
+type resolutionCache struct {
+	cache       *Cache
+	arch        string
+	localSource string
+
+	// local are the entries of the local repository by the name of each
+	// package and everything it provides.
+	local map[string][]string
+
+	// digests are the digests of the other indexes by source.
+	digests map[string]string
+}
+
+func newResolutionCache(cache *Cache, arch string, localRepo apk.NamedIndex) *resolutionCache {
+	rc := &resolutionCache{
+		cache:       cache,
+		arch:        arch,
+		localSource: localRepo.Source(),
+		local:       map[string][]string{},
+		digests:     map[string]string{},
+	}
+	for _, p := range localRepo.Packages() {
+		entry := indexEntry(p.Package)
+		names := map[string]struct{}{p.Name: {}}
+		for _, prov := range p.Provides {
+			names[apk.ResolvePackageNameVersionPin(prov).Name] = struct{}{}
+		}
+		for name := range names {
+			rc.local[name] = append(rc.local[name], entry)
+		}
+	}
+	for name := range rc.local {
+		sort.Strings(rc.local[name])
+	}
+	return rc
+}
+
+// key returns the key of the resolution of dep against the given indexes.
+func (rc *resolutionCache) key(indexes []apk.NamedIndex, dep string) string {
+	parts := []string{"resolution", rc.arch, dep}
+	for _, index := range indexes {
+		if index.Source() == rc.localSource {
+			name := apk.ResolvePackageNameVersionPin(dep).Name
+			parts = append(parts, "local", strings.Join(rc.local[name], "\n"))
+			continue
+		}
+		d, ok := rc.digests[index.Source()]
+		if !ok {
+			d = indexDigest(index)
+			rc.digests[index.Source()] = d
+		}
+		parts = append(parts, index.Source(), d)
+	}
+	return cacheKey(parts...)
+}
+
+func (rc *resolutionCache) get(key string) (resolutionEntry, bool) {
+	var entry resolutionEntry
+	ok := rc.cache.get("resolutions", key, &entry)
+	return entry, ok
+}
+
+func (rc *resolutionCache) put(key string, resolved []resolvedPackage, err error) error {
+	entry := resolutionEntry{Packages: resolved}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return rc.cache.put("resolutions", key, entry)
+}
+
+// resolutionEntry is how a resolution is cached.
+type resolutionEntry struct {
+	Packages []resolvedPackage `json:"packages,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// err returns the error the resolver returned, if any.
+func (e resolutionEntry) err() error {
+	if e.Error == "" {
+		return nil
+	}
+	return errors.New(e.Error)
+}
+
+// indexEntry describes a package of an index, with everything that can affect
+// which packages a dependency resolves to.
+func indexEntry(p *apk.Package) string {
+	return fmt.Sprintf("%s %s %s %s %d %x [%s] [%s]",
+		p.Name, p.Version, p.Arch, p.Origin, p.ProviderPriority, p.Checksum,
+		strings.Join(p.Provides, " "), strings.Join(p.Dependencies, " "))
+}
+
+// indexDigest returns the digest of the packages of an index.
+func indexDigest(index apk.NamedIndex) string {
+	h := sha256.New()
+	for _, p := range index.Packages() {
+		fmt.Fprintln(h, indexEntry(p.Package))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
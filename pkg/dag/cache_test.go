@@ -0,0 +1,154 @@
+package dag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	testDir := "testdata/provides"
+
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"app.yaml", "libfoo.yaml"} {
+		b, err := os.ReadFile(filepath.Join(testDir, name))
+		require.NoError(t, err)
+		fsys[name] = &fstest.MapFile{Data: b}
+	}
+
+	cache, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	entries := func(kind string) int {
+		matches, err := filepath.Glob(filepath.Join(cache.dir, kind, "*", "*.json"))
+		require.NoError(t, err)
+		return len(matches)
+	}
+	build := func(fsys fstest.MapFS) (*Graph, []NamedEdge) {
+		pkgs, err := NewPackages(ctx, fsys, testDir, nil, WithConfigCache(cache))
+		require.NoError(t, err)
+		g, err := NewGraph(ctx, pkgs, WithRepos(packageRepo), WithKeys(key), WithAllowUnresolved(), WithRuntimeDeps(), WithResolutionCache(cache))
+		require.NoError(t, err)
+		edges, err := namedEdges(g)
+		require.NoError(t, err)
+		out := make([]NamedEdge, 0, len(edges))
+		for e := range edges {
+			out = append(out, e)
+		}
+		sortEdges(out)
+		return g, out
+	}
+
+	// The cached graph is the same as one built without the cache.
+	pkgs, err := NewPackages(ctx, fsys, testDir, nil)
+	require.NoError(t, err)
+	uncached, err := NewGraph(ctx, pkgs, WithRepos(packageRepo), WithKeys(key), WithAllowUnresolved(), WithRuntimeDeps())
+	require.NoError(t, err)
+	want, err := namedEdges(uncached)
+	require.NoError(t, err)
+
+	_, first := build(fsys)
+	assert.Len(t, first, len(want))
+	for _, e := range first {
+		assert.Contains(t, want, e)
+	}
+	configs, resolutions := entries("configs"), entries("resolutions")
+	assert.Equal(t, 2, configs)
+	assert.NotZero(t, resolutions)
+
+	// Nothing changed, so nothing is parsed or resolved again, and no resolver
+	// is created.
+	g, second := build(fsys)
+	assert.Equal(t, first, second)
+	assert.Equal(t, configs, entries("configs"))
+	assert.Equal(t, resolutions, entries("resolutions"))
+	require.NotEmpty(t, g.repos)
+	for key := range g.repos {
+		assert.NotContains(t, g.resolvers, key)
+	}
+
+	// Configs are complete when their compilation comes from the cache,
+	// including their YAML AST.
+	cached, err := NewPackages(ctx, fsys, testDir, nil, WithConfigCache(cache))
+	require.NoError(t, err)
+	for _, name := range []string{"app", "libfoo"} {
+		got, want := cached.Config(name, true), pkgs.Config(name, true)
+		require.Len(t, got, 1)
+		require.Len(t, want, 1)
+		assert.NotNil(t, got[0].Root(), name)
+		assert.Equal(t, want[0].Environment, got[0].Environment, name)
+		assert.Equal(t, want[0].Test, got[0].Test, name)
+		assert.Equal(t, want[0].Package, got[0].Package, name)
+	}
+
+	// Bumping libfoo only invalidates its config and the resolutions of what
+	// it provides, not those of app's other dependencies.
+	fsys["libfoo.yaml"] = &fstest.MapFile{Data: []byte(strings.Replace(string(fsys["libfoo.yaml"].Data), `version: "1.0.0"`, `version: "1.0.1"`, 1))}
+	_, third := build(fsys)
+	assert.Contains(t, third, NamedEdge{From: "app", To: "libfoo"})
+	assert.Equal(t, configs+1, entries("configs"))
+	added := entries("resolutions") - resolutions
+	assert.Positive(t, added)
+	assert.Less(t, added, resolutions)
+}
+
+func TestCacheCompiledEnvironments(t *testing.T) {
+	ctx := context.Background()
+
+	pipelines := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(pipelines, "mytool.yaml"), []byte(`name: mytool
+needs:
+  packages:
+    - make
+pipeline:
+  - runs: make
+`), 0o644))
+
+	fsys := fstest.MapFS{"tool.yaml": &fstest.MapFile{Data: []byte(`package:
+  name: tool
+  version: 1.0.0
+  epoch: 0
+environment:
+  contents:
+    packages:
+      - busybox
+pipeline:
+  - uses: mytool
+subpackages:
+  - name: tool-dev
+    test:
+      pipeline:
+        - uses: mytool
+test:
+  pipeline:
+    - uses: mytool
+`)}}
+
+	cache, err := NewCache(t.TempDir())
+	require.NoError(t, err)
+
+	uncached, err := NewPackages(ctx, fsys, ".", []string{pipelines})
+	require.NoError(t, err)
+	want := uncached.Config("tool", true)[0]
+	require.Contains(t, want.Environment.Contents.Packages, "make")
+
+	for i := 0; i < 2; i++ {
+		pkgs, err := NewPackages(ctx, fsys, ".", []string{pipelines}, WithConfigCache(cache))
+		require.NoError(t, err)
+		got := pkgs.Config("tool", true)[0]
+
+		// The second time, the environments come from the cache and the
+		// config is parsed, with its YAML AST.
+		assert.NotNil(t, got.Root(), i)
+		assert.Equal(t, want.Environment.Contents.Packages, got.Environment.Contents.Packages, i)
+		assert.Equal(t, want.Test.Environment.Contents.Packages, got.Test.Environment.Contents.Packages, i)
+		assert.Equal(t, want.Subpackages[0].Test.Environment.Contents.Packages, got.Subpackages[0].Test.Environment.Contents.Packages, i)
+	}
+}
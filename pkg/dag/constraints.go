@@ -0,0 +1,191 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+)
+
+// Constraint is a dependency as declared in the config of a package, such as
+// foo, foo>=1.2, foo~3.1 or so:libfoo.so.3, together with the package it
+// resolved to.
+type Constraint struct {
+	// Package is the package declaring the dependency.
+	Package    Package
+	Dependency string
+	Types      []EdgeType
+
+	// Resolved is the package the dependency resolved to. Its Version is the
+	// resolved version. It is unresolved if the dependency couldn't be resolved
+	// in a graph built WithAllowUnresolved.
+	Resolved Package
+}
+
+// Name returns the name of the package or provides entry the dependency asks
+// for, without its version constraint.
+func (c Constraint) Name() string {
+	return apk.ResolvePackageNameVersionPin(c.Dependency).Name
+}
+
+// Fields returns the fields of the melange configuration of Package that
+// declared the dependency.
+func (c Constraint) Fields() []string {
+	return Unresolved{Types: c.Types}.Fields()
+}
+
+// Versioned reports whether the dependency constrains the version it accepts.
+func (c Constraint) Versioned() bool {
+	return apk.ResolvePackageNameVersionPin(c.Dependency).Version != ""
+}
+
+// SatisfiedBy reports whether a package or provides entry of the same name with
+// the given version satisfies the constraint.
+func (c Constraint) SatisfiedBy(version string) (bool, error) {
+	parsed := apk.ResolvePackageNameVersionPin(c.Dependency)
+	// Parse the version the way apk parses provides entries, as that's what
+	// so: versions are adjusted for.
+	v, err := apk.ParseVersion(apk.ResolvePackageNameVersionPin(parsed.Name + "=" + version).Version)
+	if err != nil {
+		return false, fmt.Errorf("parsing version %q of %s: %w", version, parsed.Name, err)
+	}
+	return parsed.SatisfiedBy(v)
+}
+
+// Constraint returns the dependency declared by source that the edge from
+// source to target resolved. Edges that don't come from a declared dependency,
+// such as those from a subpackage to its origin or those flattened by Targets,
+// have no Dependency. If source declared several dependencies resolving to
+// target, such as foo as a buildtime and foo>=2 as a runtime dependency, the
+// first one is returned with the types of all of them. EdgeConstraints returns
+// each of them.
+func (g Graph) Constraint(source, target string) (Constraint, error) {
+	edge, err := g.Graph.Edge(source, target)
+	if err != nil {
+		return Constraint{}, err
+	}
+	from, err := g.Graph.Vertex(source)
+	if err != nil {
+		return Constraint{}, err
+	}
+	to, err := g.Graph.Vertex(target)
+	if err != nil {
+		return Constraint{}, err
+	}
+	return Constraint{
+		Package:    from,
+		Dependency: edge.Properties.Attributes[attributeDepName],
+		Types:      edgeTypes(edge.Properties.Attributes),
+		Resolved:   to,
+	}, nil
+}
+
+// EdgeConstraints returns every dependency declared by source that the edge
+// from source to target resolved, sorted by dependency, each with the types it
+// was declared as.
+func (g Graph) EdgeConstraints(source, target string) ([]Constraint, error) {
+	edge, err := g.Graph.Edge(source, target)
+	if err != nil {
+		return nil, err
+	}
+	from, err := g.Graph.Vertex(source)
+	if err != nil {
+		return nil, err
+	}
+	to, err := g.Graph.Vertex(target)
+	if err != nil {
+		return nil, err
+	}
+	var out []Constraint
+	for _, d := range declaredDependencies(edge.Properties.Attributes) {
+		out = append(out, Constraint{
+			Package:    from,
+			Dependency: d.dependency,
+			Types:      d.types,
+			Resolved:   to,
+		})
+	}
+	return out, nil
+}
+
+// Constraints returns the dependencies declared by the packages in the graph,
+// each with the package it resolved to, sorted by the name of the package
+// declaring them and then by dependency.
+func (g Graph) Constraints() ([]Constraint, error) {
+	edges, err := g.Graph.Edges()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Constraint
+	for _, e := range edges {
+		cs, err := g.EdgeConstraints(e.Source, e.Target)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cs...)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Package.Name() != out[j].Package.Name() {
+			return out[i].Package.Name() < out[j].Package.Name()
+		}
+		if out[i].Dependency != out[j].Dependency {
+			return out[i].Dependency < out[j].Dependency
+		}
+		return PackageHash(out[i].Resolved) < PackageHash(out[j].Resolved)
+	})
+	return out, nil
+}
+
+// Unsatisfied is a versioned dependency on a local package, subpackage or
+// provides entry that the current version of it no longer satisfies, so the
+// dependency resolved to another package, if any, such as an older build of it
+// in a repository.
+type Unsatisfied struct {
+	Constraint
+
+	// Local are the local packages, subpackages and provides entries of the
+	// name the dependency asks for, none of which satisfies it.
+	Local []*Configuration
+}
+
+// Unsatisfied returns the versioned dependencies in the graph on local
+// packages that the local packages don't satisfy, sorted like Constraints.
+// These are typically caused by a version bump breaking a dependent pinned to
+// the previous version.
+func (g Graph) Unsatisfied() ([]Unsatisfied, error) {
+	constraints, err := g.Constraints()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Unsatisfied
+	for _, c := range constraints {
+		if !c.Versioned() {
+			continue
+		}
+		if _, ok := c.Resolved.(*Configuration); ok {
+			continue
+		}
+		local := g.packages.Config(c.Name(), false)
+		if len(local) == 0 {
+			continue
+		}
+
+		satisfied := false
+		for _, l := range local {
+			ok, err := c.SatisfiedBy(l.Version())
+			if err != nil {
+				return nil, fmt.Errorf("%s: checking %s: %w", c.Package.Name(), c.Dependency, err)
+			}
+			if ok {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			out = append(out, Unsatisfied{Constraint: c, Local: local})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,145 @@
+package dag
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraints(t *testing.T) {
+	ctx := context.Background()
+
+	// libfoo was bumped to 2.0.0 locally, while the repository still has 1.9.0.
+	fsys := fstest.MapFS{
+		"libfoo.yaml": testConfig{name: "libfoo", version: "2.0.0"}.file(),
+		"app.yaml":    testConfig{name: "app", version: "1.0.0", deps: []string{"libfoo<2"}}.file(),
+		"pinned.yaml": testConfig{name: "pinned", version: "1.0.0", deps: []string{"libfoo~1.8"}}.file(),
+		"tool.yaml":   testConfig{name: "tool", version: "1.0.0", deps: []string{"libfoo>=1.5", "libfoo-other"}}.file(),
+	}
+	pkgs, err := NewPackages(ctx, fsys, ".", nil)
+	require.NoError(t, err)
+	g, err := NewGraph(ctx, pkgs, WithAllowUnresolved(), WithLocalBuilds(&LocalBuilds{
+		Dir: "packages",
+		Packages: map[string][]*apk.Package{
+			"x86_64": {{Name: "libfoo", Version: "1.9.0-r0", Arch: "x86_64", Origin: "libfoo"}},
+		},
+	}))
+	require.NoError(t, err)
+
+	t.Run("edge", func(t *testing.T) {
+		c, err := g.Constraint("app:1.0.0-r0@local", "libfoo:1.9.0-r0@packages/x86_64")
+		require.NoError(t, err)
+		assert.Equal(t, "libfoo<2", c.Dependency)
+		assert.Equal(t, "libfoo", c.Name())
+		assert.Equal(t, "1.9.0-r0", c.Resolved.Version())
+		assert.Equal(t, []EdgeType{EdgeBuildtime}, c.Types)
+
+		c, err = g.Constraint("tool:1.0.0-r0@local", "libfoo:2.0.0-r0@local")
+		require.NoError(t, err)
+		assert.Equal(t, "libfoo>=1.5", c.Dependency)
+		assert.Equal(t, "2.0.0-r0", c.Resolved.Version())
+	})
+
+	t.Run("all", func(t *testing.T) {
+		constraints, err := g.Constraints()
+		require.NoError(t, err)
+		var got []string
+		for _, c := range constraints {
+			got = append(got, c.Package.Name()+" "+c.Dependency+" "+PackageHash(c.Resolved))
+		}
+		assert.Equal(t, []string{
+			"app libfoo<2 libfoo:1.9.0-r0@packages/x86_64",
+			"pinned libfoo~1.8 libfoo~1.8:@unknown",
+			"tool libfoo-other libfoo-other:@unknown",
+			"tool libfoo>=1.5 libfoo:2.0.0-r0@local",
+		}, got)
+	})
+
+	t.Run("unsatisfied", func(t *testing.T) {
+		unsatisfied, err := g.Unsatisfied()
+		require.NoError(t, err)
+		require.Len(t, unsatisfied, 2)
+
+		assert.Equal(t, "app", unsatisfied[0].Package.Name())
+		assert.Equal(t, "libfoo<2", unsatisfied[0].Dependency)
+		assert.True(t, unsatisfied[0].Resolved.Resolved())
+		require.Len(t, unsatisfied[0].Local, 1)
+		assert.Equal(t, "2.0.0-r0", unsatisfied[0].Local[0].Version())
+
+		assert.Equal(t, "pinned", unsatisfied[1].Package.Name())
+		assert.False(t, unsatisfied[1].Resolved.Resolved())
+	})
+
+	t.Run("satisfied by", func(t *testing.T) {
+		for _, tt := range []struct {
+			dep, version string
+			want         bool
+		}{
+			{"foo", "1.0-r0", true},
+			{"foo>=1.2", "1.2.0-r3", true},
+			{"foo>=1.2", "1.1.9-r0", false},
+			{"foo~3.1", "3.1.4-r0", true},
+			{"foo~3.1", "3.2.0-r0", false},
+			{"so:libfoo.so.3", "3", true},
+			{"so:libfoo.so.3=3", "3", true},
+		} {
+			got, err := Constraint{Dependency: tt.dep}.SatisfiedBy(tt.version)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got, "%s by %s", tt.dep, tt.version)
+		}
+	})
+}
+
+func TestConstraintsMergedEdge(t *testing.T) {
+	ctx := context.Background()
+
+	// Both dependencies of app resolve to the build of libfoo in the
+	// repository, so they share an edge.
+	fsys := fstest.MapFS{
+		"libfoo.yaml": testConfig{name: "libfoo", version: "2.0.0"}.file(),
+		"app.yaml":    testConfig{name: "app", version: "1.0.0", runtime: []string{"libfoo~1.9"}, deps: []string{"libfoo<2"}}.file(),
+	}
+	pkgs, err := NewPackages(ctx, fsys, ".", nil)
+	require.NoError(t, err)
+	g, err := NewGraph(ctx, pkgs, WithRuntimeDeps(), WithLocalBuilds(&LocalBuilds{
+		Dir: "packages",
+		Packages: map[string][]*apk.Package{
+			"x86_64": {{Name: "libfoo", Version: "1.9.0-r0", Arch: "x86_64", Origin: "libfoo"}},
+		},
+	}))
+	require.NoError(t, err)
+
+	types, err := g.EdgeTypes("app:1.0.0-r0@local", "libfoo:1.9.0-r0@packages/x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, []EdgeType{EdgeBuildtime, EdgeRuntime}, types)
+
+	edge, err := g.EdgeConstraints("app:1.0.0-r0@local", "libfoo:1.9.0-r0@packages/x86_64")
+	require.NoError(t, err)
+	require.Len(t, edge, 2)
+	assert.Equal(t, "libfoo<2", edge[0].Dependency)
+	assert.Equal(t, []EdgeType{EdgeBuildtime}, edge[0].Types)
+	assert.Equal(t, "libfoo~1.9", edge[1].Dependency)
+	assert.Equal(t, []EdgeType{EdgeRuntime}, edge[1].Types)
+
+	constraints, err := g.Constraints()
+	require.NoError(t, err)
+	var got []string
+	for _, c := range constraints {
+		got = append(got, c.Package.Name()+" "+c.Dependency+" "+PackageHash(c.Resolved))
+	}
+	assert.Equal(t, []string{
+		"app libfoo<2 libfoo:1.9.0-r0@packages/x86_64",
+		"app libfoo~1.9 libfoo:1.9.0-r0@packages/x86_64",
+	}, got)
+
+	unsatisfied, err := g.Unsatisfied()
+	require.NoError(t, err)
+	require.Len(t, unsatisfied, 2)
+	assert.Equal(t, "libfoo<2", unsatisfied[0].Dependency)
+	assert.Equal(t, "libfoo~1.9", unsatisfied[1].Dependency)
+	assert.Equal(t, []string{"dependencies.runtime"}, unsatisfied[1].Fields())
+}
@@ -0,0 +1,277 @@
+package dag
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dominikbraun/graph"
+)
+
+// bootstrapSuffixes are the suffixes of packages conventionally built to break
+// a dependency cycle, providing a minimal version of the package with the same
+// name without the suffix.
+var bootstrapSuffixes = []string{"-stage0", "-bootstrap"}
+
+// Cycle is an elementary cycle of dependencies, as the hops from each package
+// to the next, ending with the package it started from.
+type Cycle []Hop
+
+func (c Cycle) String() string {
+	names := make([]string, 0, len(c)+1)
+	for _, hop := range c {
+		names = append(names, hop.From.Name())
+	}
+	if len(c) > 0 {
+		names = append(names, c[0].From.Name())
+	}
+	return strings.Join(names, " -> ")
+}
+
+// CyclicComponents returns the strongly connected components of the graph with
+// more than one node, i.e. the groups of packages that all transitively depend
+// on each other. Nodes are sorted within each component, and components by
+// their first node.
+func (g Graph) CyclicComponents() ([][]string, error) {
+	sccs, err := graph.StronglyConnectedComponents(g.Graph)
+	if err != nil {
+		return nil, err
+	}
+	var out [][]string
+	for _, scc := range sccs {
+		if len(scc) < 2 {
+			continue
+		}
+		sort.Strings(scc)
+		out = append(out, scc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out, nil
+}
+
+// Cycles returns the elementary cycles of the graph, shortest first. If limit
+// is greater than zero, at most limit cycles are returned, since there can be
+// exponentially many. Cycles only exist in a graph built WithAllowCycles.
+func (g Graph) Cycles(limit int) ([]Cycle, error) {
+	amap, err := g.Graph.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	components, err := g.CyclicComponents()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths [][]string
+	for _, component := range components {
+		paths = append(paths, elementaryCycles(amap, component, limit-len(paths))...)
+		if limit > 0 && len(paths) >= limit {
+			break
+		}
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return strings.Join(paths[i], " ") < strings.Join(paths[j], " ")
+	})
+
+	cycles := make([]Cycle, 0, len(paths))
+	for _, path := range paths {
+		closed := make([]string, 0, len(path)+1)
+		closed = append(closed, path...)
+		hops, err := g.hops(append(closed, path[0]))
+		if err != nil {
+			return nil, err
+		}
+		cycles = append(cycles, hops)
+	}
+	return cycles, nil
+}
+
+// elementaryCycles finds the elementary cycles among the nodes of a strongly
+// connected component with Johnson's algorithm, returning each as the list of
+// its nodes starting with its smallest. If limit is greater than zero, it stops
+// after finding limit cycles.
+func elementaryCycles(amap map[string]map[string]graph.Edge[string], component []string, limit int) [][]string {
+	var cycles [][]string
+	full := func() bool { return limit > 0 && len(cycles) >= limit }
+
+	// Johnson's algorithm considers the cycles through each node in turn, in
+	// the subgraph of the nodes not considered yet.
+	for i, start := range component {
+		if full() {
+			break
+		}
+		remaining := map[string]bool{}
+		for _, node := range component[i:] {
+			remaining[node] = true
+		}
+		adj := func(node string) []string {
+			var out []string
+			for _, dep := range sortedKeys(amap[node]) {
+				if remaining[dep] {
+					out = append(out, dep)
+				}
+			}
+			return out
+		}
+
+		blocked := map[string]bool{}
+		blockers := map[string]map[string]bool{}
+		var unblock func(string)
+		unblock = func(node string) {
+			blocked[node] = false
+			for b := range blockers[node] {
+				delete(blockers[node], b)
+				if blocked[b] {
+					unblock(b)
+				}
+			}
+		}
+
+		var stack []string
+		var circuit func(string) bool
+		circuit = func(node string) bool {
+			found := false
+			stack = append(stack, node)
+			blocked[node] = true
+			for _, next := range adj(node) {
+				if full() {
+					break
+				}
+				switch {
+				case next == start:
+					cycles = append(cycles, append([]string{}, stack...))
+					found = true
+				case !blocked[next]:
+					if circuit(next) {
+						found = true
+					}
+				}
+			}
+			if found {
+				unblock(node)
+			} else {
+				for _, next := range adj(node) {
+					if blockers[next] == nil {
+						blockers[next] = map[string]bool{}
+					}
+					blockers[next][node] = true
+				}
+			}
+			stack = stack[:len(stack)-1]
+			return found
+		}
+		circuit(start)
+	}
+	return cycles
+}
+
+// Suggestion is a dependency to break in order to break cycles.
+type Suggestion struct {
+	Hop Hop
+
+	// Cycles is the number of cycles that contain the dependency.
+	Cycles int
+
+	// Bootstrap is the name of a package in the graph that conventionally
+	// provides a minimal build of the dependency to break cycles with, such as
+	// a -stage0 package, if there is one.
+	Bootstrap string
+}
+
+// SuggestBreaks suggests a set of dependencies that, once broken, leave none of
+// the given cycles. Dependencies that could be satisfied by a bootstrap package
+// already in the graph are preferred, then dependencies in more cycles, then
+// runtime and test dependencies, which don't affect how a package is built. A subpackage
+// can't stop depending on its origin, so those edges are never suggested.
+func (g Graph) SuggestBreaks(cycles []Cycle) []Suggestion {
+	type candidate struct {
+		Suggestion
+		key    string
+		cycles map[int]bool
+	}
+	candidates := map[string]*candidate{}
+	for i, c := range cycles {
+		for _, hop := range c {
+			if len(hop.Types) == 1 && hop.Types[0] == EdgeSubpackage {
+				continue
+			}
+			key := PackageHash(hop.From) + " " + PackageHash(hop.To)
+			cand, ok := candidates[key]
+			if !ok {
+				cand = &candidate{
+					Suggestion: Suggestion{Hop: hop, Bootstrap: g.bootstrapFor(hop.To)},
+					key:        key,
+					cycles:     map[int]bool{},
+				}
+				candidates[key] = cand
+			}
+			cand.cycles[i] = true
+		}
+	}
+
+	// prefer reports whether breaking a, which breaks na more cycles, is a
+	// better suggestion than breaking b, which breaks nb more cycles.
+	prefer := func(a *candidate, na int, b *candidate, nb int) bool {
+		if (a.Bootstrap != "") != (b.Bootstrap != "") {
+			return a.Bootstrap != ""
+		}
+		if na != nb {
+			return na > nb
+		}
+		// Dependencies not needed to build the package, runtime or test
+		// ones, are the cheapest to break.
+		if NotBuildtime(a.Hop.Types) != NotBuildtime(b.Hop.Types) {
+			return NotBuildtime(a.Hop.Types)
+		}
+		return a.key < b.key
+	}
+
+	broken := map[int]bool{}
+	var out []Suggestion
+	for len(broken) < len(cycles) {
+		var best *candidate
+		bestCount := 0
+		for _, cand := range candidates {
+			count := 0
+			for i := range cand.cycles {
+				if !broken[i] {
+					count++
+				}
+			}
+			if count > 0 && (best == nil || prefer(cand, count, best, bestCount)) {
+				best, bestCount = cand, count
+			}
+		}
+		if best == nil {
+			// Only subpackage edges left, nothing to suggest.
+			break
+		}
+		for i := range best.cycles {
+			broken[i] = true
+		}
+		s := best.Suggestion
+		s.Cycles = len(best.cycles)
+		out = append(out, s)
+		delete(candidates, best.key)
+	}
+	return out
+}
+
+// bootstrapFor returns the name of a bootstrap package in the graph for p or
+// its origin, or an empty string if there is none.
+func (g Graph) bootstrapFor(p Package) string {
+	names := []string{p.Name()}
+	if origin := originName(p); origin != p.Name() {
+		names = append(names, origin)
+	}
+	for _, name := range names {
+		for _, suffix := range bootstrapSuffixes {
+			if len(g.byName[name+suffix]) > 0 {
+				return name + suffix
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package dag
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCycles(t *testing.T) {
+	ctx := context.Background()
+	testDir := "testdata/cycle"
+
+	pkgs, err := NewPackages(ctx, os.DirFS(testDir), testDir, nil)
+	require.NoError(t, err)
+
+	_, err = NewGraph(ctx, pkgs)
+	require.Error(t, err, "the local packages alone can't be built without a cycle")
+
+	g, err := NewGraph(ctx, pkgs, WithAllowCycles())
+	require.NoError(t, err)
+
+	components, err := g.CyclicComponents()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{
+		"a:1.3.5-r1@local",
+		"b:1.2.3-r1@local",
+		"c:1.5.5-r1@local",
+		"d:2.0.0-r1@local",
+	}}, components)
+
+	cycles, err := g.Cycles(0)
+	require.NoError(t, err)
+	var got []string
+	for _, c := range cycles {
+		got = append(got, c.String())
+	}
+	assert.Equal(t, []string{
+		"a -> d -> a",
+		"a -> b -> d -> a",
+		"a -> c -> d -> a",
+		"a -> b -> c -> d -> a",
+	}, got)
+	assert.Equal(t, []string{"environment.contents.packages"}, cycles[0][1].Fields())
+
+	limited, err := g.Cycles(2)
+	require.NoError(t, err)
+	assert.Len(t, limited, 2)
+
+	suggestions := g.SuggestBreaks(cycles)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "d", suggestions[0].Hop.From.Name())
+	assert.Equal(t, "a", suggestions[0].Hop.To.Name())
+	assert.Equal(t, 4, suggestions[0].Cycles)
+	assert.Empty(t, suggestions[0].Bootstrap)
+}
+
+func TestSuggestBreaksBootstrap(t *testing.T) {
+	g, err := NewGraph(context.Background(), &Packages{}, WithAllowCycles())
+	require.NoError(t, err)
+	hash := func(name string) string {
+		return PackageHash(externalPackage{name, "1", "test"})
+	}
+	for _, name := range []string{"gcc", "glibc", "glibc-stage0", "make"} {
+		require.NoError(t, g.addVertex(externalPackage{name, "1", "test"}))
+	}
+	// gcc and glibc need each other, and so do gcc and make.
+	for _, e := range [][2]string{{"gcc", "glibc"}, {"glibc", "gcc"}, {"gcc", "make"}, {"make", "gcc"}} {
+		require.NoError(t, g.Graph.AddEdge(hash(e[0]), hash(e[1])))
+	}
+
+	cycles, err := g.Cycles(0)
+	require.NoError(t, err)
+	require.Len(t, cycles, 2)
+
+	suggestions := g.SuggestBreaks(cycles)
+	require.Len(t, suggestions, 2)
+	assert.Equal(t, "gcc", suggestions[0].Hop.From.Name())
+	assert.Equal(t, "glibc", suggestions[0].Hop.To.Name())
+	assert.Equal(t, "glibc-stage0", suggestions[0].Bootstrap)
+	assert.Empty(t, suggestions[1].Bootstrap)
+}
@@ -0,0 +1,91 @@
+package dag
+
+import (
+	"fmt"
+)
+
+// Dependents returns the subgraph of g with the given nodes and every package
+// that transitively depends on them. Only edges with at least one of the given
+// types are followed, or all edges if no types are given. If depth is greater
+// than zero, only dependents at most that many edges away from the given nodes
+// are included, so a depth of 1 selects direct dependents.
+func (g Graph) Dependents(nodes []string, depth int, types ...EdgeType) (*Graph, error) {
+	pmap, err := g.Graph.PredecessorMap()
+	if err != nil {
+		return nil, err
+	}
+
+	follow := func(attrs map[string]string) bool {
+		if len(types) == 0 {
+			return true
+		}
+		have := edgeTypes(attrs)
+		if len(have) == 0 {
+			// Edges predating edge types are always environment dependencies.
+			have = []EdgeType{EdgeBuildtime}
+		}
+		for _, t := range have {
+			for _, want := range types {
+				// A subpackage is rebuilt along with its origin, whatever the
+				// dependency that's being followed.
+				if t == want || t == EdgeSubpackage {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	distance := map[string]int{}
+	queue := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if _, ok := pmap[node]; !ok {
+			return nil, fmt.Errorf("could not find node %q", node)
+		}
+		if _, ok := distance[node]; !ok {
+			distance[node] = 0
+			queue = append(queue, node)
+		}
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if depth > 0 && distance[node] >= depth {
+			continue
+		}
+		for _, dependent := range sortedKeys(pmap[node]) {
+			if _, ok := distance[dependent]; ok {
+				continue
+			}
+			if !follow(pmap[node][dependent].Properties.Attributes) {
+				continue
+			}
+			distance[dependent] = distance[node] + 1
+			queue = append(queue, dependent)
+		}
+	}
+
+	subgraph, err := g.Filter(func(p Package) bool {
+		_, ok := distance[PackageHash(p)]
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter keeps every edge between the selected packages, including those of
+	// other types, which would otherwise affect the rebuild order.
+	edges, err := subgraph.Graph.Edges()
+	if err != nil {
+		return nil, err
+	}
+	for _, edge := range edges {
+		if follow(edge.Properties.Attributes) {
+			continue
+		}
+		if err := subgraph.Graph.RemoveEdge(edge.Source, edge.Target); err != nil {
+			return nil, err
+		}
+	}
+	return subgraph, nil
+}
@@ -0,0 +1,64 @@
+package dag
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependents(t *testing.T) {
+	ctx := context.Background()
+	testDir := "testdata/subpackages"
+
+	pkgs, err := NewPackages(ctx, os.DirFS(testDir), testDir, nil)
+	require.NoError(t, err)
+	graph, err := NewGraph(ctx, pkgs, WithAllowUnresolved(), WithRuntimeDeps())
+	require.NoError(t, err)
+	graph, err = graph.Filter(FilterLocal())
+	require.NoError(t, err)
+	graph, err = graph.Targets()
+	require.NoError(t, err)
+
+	const one = "one:1.2.3-r1@local"
+
+	for _, tt := range []struct {
+		name  string
+		depth int
+		types []EdgeType
+		want  []string
+	}{{
+		name: "all edges",
+		want: []string{"one", "two", "three"},
+	}, {
+		name:  "buildtime",
+		types: []EdgeType{EdgeBuildtime},
+		want:  []string{"one", "two", "three"},
+	}, {
+		name:  "direct buildtime",
+		depth: 1,
+		types: []EdgeType{EdgeBuildtime},
+		want:  []string{"one", "two"},
+	}, {
+		name:  "runtime",
+		types: []EdgeType{EdgeRuntime},
+		want:  []string{"one", "three"},
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			dependents, err := graph.Dependents([]string{one}, tt.depth, tt.types...)
+			require.NoError(t, err)
+			sorted, err := dependents.ReverseSorted()
+			require.NoError(t, err)
+			var got []string
+			for _, p := range sorted {
+				got = append(got, p.Name())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = graph.Dependents([]string{"missing:1-r0@local"}, 0)
+	assert.Error(t, err)
+}
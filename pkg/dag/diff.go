@@ -0,0 +1,219 @@
+package dag
+
+import (
+	"sort"
+)
+
+// GraphDiff is the difference between two graphs of the same repository, for
+// instance at two commits.
+type GraphDiff struct {
+	// Added and Removed are the names of local origin packages only defined
+	// in one of the graphs.
+	Added, Removed []string
+
+	// Changed are the local origin packages whose version changed.
+	Changed []VersionChange
+
+	// AddedEdges and RemovedEdges are the dependencies, by package name, only
+	// found in one of the graphs.
+	AddedEdges, RemovedEdges []NamedEdge
+
+	// ClosureChanged are the names of the packages in both graphs for which
+	// the set of transitive dependencies changed, including by a dependency
+	// changing version. These are the packages that need to be rebuilt.
+	ClosureChanged []string
+}
+
+// VersionChange is a package whose version changed.
+type VersionChange struct {
+	Name     string
+	From, To string
+}
+
+// NamedEdge is a dependency between two packages, identified by name.
+type NamedEdge struct {
+	From, To string
+}
+
+// Empty reports whether there is no difference between the graphs.
+func (d *GraphDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 && len(d.ClosureChanged) == 0
+}
+
+// Diff returns the differences from the graph before to the graph after. Both
+// graphs are normally the result of Targets, so that subpackages are
+// flattened into their origins.
+func Diff(before, after *Graph) (*GraphDiff, error) {
+	d := &GraphDiff{}
+
+	versions := func(g *Graph) map[string]string {
+		out := map[string]string{}
+		if g.packages == nil {
+			return out
+		}
+		for _, name := range g.packages.PackageNames() {
+			if c := g.packages.PkgConfig(name); c != nil {
+				out[name] = c.Version()
+			}
+		}
+		return out
+	}
+	vBefore, vAfter := versions(before), versions(after)
+	for name, v := range vAfter {
+		old, ok := vBefore[name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, name)
+		case old != v:
+			d.Changed = append(d.Changed, VersionChange{Name: name, From: old, To: v})
+		}
+	}
+	for name := range vBefore {
+		if _, ok := vAfter[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Name < d.Changed[j].Name })
+
+	eBefore, err := namedEdges(before)
+	if err != nil {
+		return nil, err
+	}
+	eAfter, err := namedEdges(after)
+	if err != nil {
+		return nil, err
+	}
+	for e := range eAfter {
+		if _, ok := eBefore[e]; !ok {
+			d.AddedEdges = append(d.AddedEdges, e)
+		}
+	}
+	for e := range eBefore {
+		if _, ok := eAfter[e]; !ok {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+	sortEdges(d.AddedEdges)
+	sortEdges(d.RemovedEdges)
+
+	cBefore, err := closures(before)
+	if err != nil {
+		return nil, err
+	}
+	cAfter, err := closures(after)
+	if err != nil {
+		return nil, err
+	}
+	for name, deps := range cAfter {
+		old, ok := cBefore[name]
+		if !ok {
+			continue
+		}
+		if !sameSet(old, deps) {
+			d.ClosureChanged = append(d.ClosureChanged, name)
+		}
+	}
+	sort.Strings(d.ClosureChanged)
+
+	return d, nil
+}
+
+func namedEdges(g *Graph) (map[NamedEdge]struct{}, error) {
+	edges, err := g.Graph.Edges()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[NamedEdge]struct{}, len(edges))
+	for _, e := range edges {
+		from, err := g.Graph.Vertex(e.Source)
+		if err != nil {
+			return nil, err
+		}
+		to, err := g.Graph.Vertex(e.Target)
+		if err != nil {
+			return nil, err
+		}
+		out[NamedEdge{From: from.Name(), To: to.Name()}] = struct{}{}
+	}
+	return out, nil
+}
+
+func sortEdges(edges []NamedEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
+
+// closures returns, for each package name in g, the names and versions of all
+// of its transitive dependencies.
+func closures(g *Graph) (map[string]map[string]struct{}, error) {
+	amap, err := g.Graph.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string, len(amap))
+	names := make(map[string]string, len(amap))
+	for node := range amap {
+		p, err := g.Graph.Vertex(node)
+		if err != nil {
+			return nil, err
+		}
+		ids[node] = p.Name() + "-" + p.Version()
+		names[node] = p.Name()
+	}
+
+	memo := make(map[string]map[string]struct{}, len(amap))
+	var closure func(node string) map[string]struct{}
+	closure = func(node string) map[string]struct{} {
+		if c, ok := memo[node]; ok {
+			return c
+		}
+		c := map[string]struct{}{}
+		// Guard against cycles, which leave the closure incomplete rather than
+		// recursing forever.
+		memo[node] = c
+		for dep := range amap[node] {
+			c[ids[dep]] = struct{}{}
+			for id := range closure(dep) {
+				c[id] = struct{}{}
+			}
+		}
+		return c
+	}
+
+	out := make(map[string]map[string]struct{}, len(amap))
+	for node := range amap {
+		c := closure(node)
+		if existing, ok := out[names[node]]; ok {
+			// Several versions of the same package, merge their closures.
+			for id := range c {
+				existing[id] = struct{}{}
+			}
+			continue
+		}
+		merged := make(map[string]struct{}, len(c))
+		for id := range c {
+			merged[id] = struct{}{}
+		}
+		out[names[node]] = merged
+	}
+	return out, nil
+}
+
+func sameSet(a, b map[string]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package dag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	testDir := "testdata/subpackages"
+
+	before := fstest.MapFS{}
+	for _, name := range []string{"one.yaml", "two.yaml", "three.yaml"} {
+		b, err := os.ReadFile(filepath.Join(testDir, name))
+		require.NoError(t, err)
+		before[name] = &fstest.MapFile{Data: b}
+	}
+
+	// one is bumped, three gains a direct dependency on one and two is removed.
+	after := fstest.MapFS{
+		"one.yaml":   &fstest.MapFile{Data: []byte(strings.Replace(string(before["one.yaml"].Data), `version: "1.2.3"`, `version: "1.2.4"`, 1))},
+		"three.yaml": &fstest.MapFile{Data: []byte(strings.Replace(string(before["three.yaml"].Data), "      - two\n", "      - one\n", 1))},
+	}
+
+	graph := func(fsys fstest.MapFS) *Graph {
+		pkgs, err := NewPackages(ctx, fsys, testDir, nil)
+		require.NoError(t, err)
+		g, err := NewGraph(ctx, pkgs, WithAllowUnresolved())
+		require.NoError(t, err)
+		g, err = g.Targets()
+		require.NoError(t, err)
+		return g
+	}
+
+	d, err := Diff(graph(before), graph(after))
+	require.NoError(t, err)
+	assert.Empty(t, d.Added)
+	assert.Equal(t, []string{"two"}, d.Removed)
+	assert.Equal(t, []VersionChange{{Name: "one", From: "1.2.3-r1", To: "1.2.4-r1"}}, d.Changed)
+	assert.Equal(t, []NamedEdge{{From: "three", To: "one"}}, d.AddedEdges)
+	assert.Equal(t, []NamedEdge{
+		{From: "three", To: "two"},
+		{From: "two", To: "busybox"},
+		{From: "two", To: "one"},
+		{From: "two", To: "wolfi-baselayout"},
+	}, d.RemovedEdges)
+	assert.Equal(t, []string{"three"}, d.ClosureChanged)
+	assert.False(t, d.Empty())
+
+	same, err := Diff(graph(before), graph(before))
+	require.NoError(t, err)
+	assert.True(t, same.Empty())
+}
@@ -0,0 +1,192 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dominikbraun/graph"
+)
+
+const attributeEdgeType = "edge-type"
+
+// EdgeType describes why one package depends on another.
+type EdgeType string
+
+const (
+	// EdgeBuildtime is a dependency listed in environment.contents.packages.
+	EdgeBuildtime EdgeType = "buildtime"
+
+	// EdgeRuntime is a dependency listed in dependencies.runtime. Runtime edges
+	// are only part of the graph if WithRuntimeDeps is set.
+	EdgeRuntime EdgeType = "runtime"
+
+	// EdgeTest is a dependency listed in test.environment.contents.packages
+	// of a package or subpackage. Test edges are only part of the graph if
+	// WithTestDeps is set.
+	EdgeTest EdgeType = "test"
+
+	// EdgeSubpackage connects a subpackage to the origin package that builds it.
+	EdgeSubpackage EdgeType = "subpackage"
+)
+
+// EdgeTypes returns the types of the edge from source to target, sorted
+// alphabetically. An edge has more than one type when the same dependency is
+// declared in several places, or when Targets flattens several edges into one.
+func (g Graph) EdgeTypes(source, target string) ([]EdgeType, error) {
+	edge, err := g.Graph.Edge(source, target)
+	if err != nil {
+		return nil, err
+	}
+	return edgeTypes(edge.Properties.Attributes), nil
+}
+
+// ParseEdgeTypes parses the names of the types of dependencies a package
+// declares: buildtime, runtime and test.
+func ParseEdgeTypes(values []string) ([]EdgeType, error) {
+	types := make([]EdgeType, 0, len(values))
+	for _, v := range values {
+		t := EdgeType(v)
+		if t != EdgeBuildtime && t != EdgeRuntime && t != EdgeTest {
+			return nil, fmt.Errorf("unknown edge type %q, must be one of: %s, %s, %s", v, EdgeBuildtime, EdgeRuntime, EdgeTest)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+func edgeTypes(attrs map[string]string) []EdgeType {
+	v := attrs[attributeEdgeType]
+	if v == "" {
+		return nil
+	}
+	var types []EdgeType
+	for _, t := range strings.Split(v, ",") {
+		types = append(types, EdgeType(t))
+	}
+	return types
+}
+
+// NotBuildtime reports whether types only has types of dependencies that aren't
+// needed to build a package: runtime and test dependencies.
+func NotBuildtime(types []EdgeType) bool {
+	for _, t := range types {
+		if t != EdgeRuntime && t != EdgeTest {
+			return false
+		}
+	}
+	return len(types) > 0
+}
+
+// mergeEdgeTypes returns the comma-separated union of the edge types in a and b.
+func mergeEdgeTypes(a, b string) string {
+	set := map[string]struct{}{}
+	for _, s := range []string{a, b} {
+		for _, t := range strings.Split(s, ",") {
+			if t != "" {
+				set[t] = struct{}{}
+			}
+		}
+	}
+	types := make([]string, 0, len(set))
+	for t := range set {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ",")
+}
+
+// declaredAttribute returns the value of an attributeDeclared attribute for the
+// dependency dep declared as a dependency of type t.
+func declaredAttribute(t EdgeType, dep string) string {
+	return string(t) + "=" + dep
+}
+
+// mergeDeclared returns the space-separated union of the declared dependencies
+// in a and b, in the order they were first declared.
+func mergeDeclared(a, b string) string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, s := range []string{a, b} {
+		for _, d := range strings.Fields(s) {
+			if _, ok := seen[d]; !ok {
+				seen[d] = struct{}{}
+				out = append(out, d)
+			}
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// declared is a dependency as declared by the source of an edge, with the types
+// it was declared as.
+type declared struct {
+	dependency string
+	types      []EdgeType
+}
+
+// declaredDependencies returns the distinct dependencies recorded in the
+// attributeDeclared attribute of an edge, sorted by dependency. Several
+// dependencies end up on the same edge when they resolve to the same package,
+// such as foo as a buildtime and foo>=2 as a runtime dependency.
+func declaredDependencies(attrs map[string]string) []declared {
+	byDep := map[string][]EdgeType{}
+	for _, d := range strings.Fields(attrs[attributeDeclared]) {
+		t, dep, ok := strings.Cut(d, "=")
+		if !ok {
+			continue
+		}
+		byDep[dep] = append(byDep[dep], EdgeType(t))
+	}
+	out := make([]declared, 0, len(byDep))
+	for dep, types := range byDep {
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		out = append(out, declared{dependency: dep, types: types})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].dependency < out[j].dependency })
+	return out
+}
+
+// addTypedEdge adds an edge from source to target with the given attributes. If
+// the edge already exists, the edge types and declared dependencies from attrs
+// are added to it instead. The other attributes, such as attributeDepName, keep
+// the values of the first dependency resolving to target.
+func addTypedEdge(g graph.Graph[string, Package], source, target string, attrs map[string]string) error {
+	err := g.AddEdge(source, target, graph.EdgeAttributes(copyAttributes(attrs)))
+	if !errors.Is(err, graph.ErrEdgeAlreadyExists) {
+		return err
+	}
+
+	edge, err := g.Edge(source, target)
+	if err != nil {
+		return err
+	}
+	mergedTypes := mergeEdgeTypes(edge.Properties.Attributes[attributeEdgeType], attrs[attributeEdgeType])
+	mergedDeclared := mergeDeclared(edge.Properties.Attributes[attributeDeclared], attrs[attributeDeclared])
+	if mergedTypes == edge.Properties.Attributes[attributeEdgeType] && mergedDeclared == edge.Properties.Attributes[attributeDeclared] {
+		return nil
+	}
+	updated := copyAttributes(edge.Properties.Attributes)
+	updated[attributeEdgeType] = mergedTypes
+	if mergedDeclared != "" {
+		updated[attributeDeclared] = mergedDeclared
+	}
+	return g.UpdateEdge(source, target, graph.EdgeAttributes(updated))
+}
+
+// edgeTypeAttributes returns only the edge type of e, for graphs in which the
+// other attributes of e no longer apply.
+func edgeTypeAttributes(e graph.Edge[string]) map[string]string {
+	return map[string]string{
+		attributeEdgeType: e.Properties.Attributes[attributeEdgeType],
+	}
+}
+
+func copyAttributes(attrs map[string]string) map[string]string {
+	out := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,289 @@
+package dag
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/dominikbraun/graph"
+)
+
+// Durations maps package names to how long they take to build.
+type Durations map[string]time.Duration
+
+// ReadDurations reads build durations from r. Each line holds a package name
+// and a duration as understood by time.ParseDuration, separated by whitespace,
+// e.g. "gcc 1h15m". Blank lines and lines starting with # are ignored.
+func ReadDurations(r io.Reader) (Durations, error) {
+	durations := Durations{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<package> <duration>\", got %q", line, text)
+		}
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		durations[fields[0]] = d
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return durations, nil
+}
+
+// DurationsFromIndex approximates build durations from the build times of the
+// packages in an APKINDEX. Origin packages are ordered by when they were built,
+// and each one is assumed to have taken as long as the gap since the previous
+// one finished. This is only meaningful for an index written by a single
+// sequential build, such as a local melange output directory. The first origin
+// has no known duration and is omitted.
+func DurationsFromIndex(packages []*apk.Package) Durations {
+	built := map[string]time.Time{}
+	for _, p := range packages {
+		origin := p.Origin
+		if origin == "" {
+			origin = p.Name
+		}
+		if p.BuildTime.After(built[origin]) {
+			built[origin] = p.BuildTime
+		}
+	}
+
+	origins := make([]string, 0, len(built))
+	for origin := range built {
+		origins = append(origins, origin)
+	}
+	sort.Slice(origins, func(i, j int) bool {
+		ti, tj := built[origins[i]], built[origins[j]]
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return origins[i] < origins[j]
+	})
+
+	durations := Durations{}
+	for i := 1; i < len(origins); i++ {
+		durations[origins[i]] = built[origins[i]].Sub(built[origins[i-1]])
+	}
+	return durations
+}
+
+// Contribution is how much a single package adds to the critical path.
+type Contribution struct {
+	Package  Package
+	Duration time.Duration
+	// Share is the fraction of the critical path spent building the package.
+	Share float64
+}
+
+// Estimate is the result of estimating how long it takes to build a graph.
+type Estimate struct {
+	// CriticalPath is the chain of dependent packages that takes the longest
+	// to build, in build order.
+	CriticalPath []Package
+
+	// CriticalPathDuration is the time it takes to build the critical path. No
+	// number of workers can build the graph faster than this.
+	CriticalPathDuration time.Duration
+
+	// Total is the time it takes to build every package one after the other.
+	Total time.Duration
+
+	// WallClock is the estimated time it takes to build every package with the
+	// given number of workers.
+	WallClock time.Duration
+	Workers   int
+
+	// Contributions are the packages on the critical path, longest first.
+	Contributions []Contribution
+
+	// Unknown are the names of packages without a known duration, which were
+	// assumed to take the default duration.
+	Unknown []string
+}
+
+// Estimate computes the critical path of the graph and estimates how long it
+// takes to build all of its packages with the given number of workers, using
+// the durations given for each package name. Packages without a duration are
+// assumed to take defaultDuration. A workers value of zero or less means there
+// is no limit on the number of concurrent builds.
+//
+// Estimate is normally called on the result of Targets, so that subpackages
+// are flattened into their origins.
+func (g Graph) Estimate(durations Durations, defaultDuration time.Duration, workers int) (*Estimate, error) {
+	amap, err := g.Graph.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	pmap, err := g.Graph.PredecessorMap()
+	if err != nil {
+		return nil, err
+	}
+	// Sorted puts dependents before their dependencies.
+	sorted, err := g.Sorted()
+	if err != nil {
+		return nil, err
+	}
+
+	est := &Estimate{Workers: workers}
+
+	cost := make(map[string]time.Duration, len(sorted))
+	for _, p := range sorted {
+		d, ok := durations[p.Name()]
+		if !ok {
+			d = defaultDuration
+			est.Unknown = append(est.Unknown, p.Name())
+		}
+		cost[PackageHash(p)] = d
+		est.Total += d
+	}
+	sort.Strings(est.Unknown)
+
+	// finish is the earliest a package can be done, given unlimited workers.
+	finish := make(map[string]time.Duration, len(sorted))
+	via := make(map[string]string, len(sorted))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		node := PackageHash(sorted[i])
+		var start time.Duration
+		for _, dep := range sortedKeys(amap[node]) {
+			if finish[dep] > start {
+				start = finish[dep]
+				via[node] = dep
+			}
+		}
+		finish[node] = start + cost[node]
+	}
+
+	var last string
+	for _, p := range sorted {
+		node := PackageHash(p)
+		if last == "" || finish[node] > finish[last] {
+			last = node
+		}
+	}
+	for node := last; node != ""; node = via[node] {
+		p, err := g.Graph.Vertex(node)
+		if err != nil {
+			return nil, err
+		}
+		est.CriticalPath = append([]Package{p}, est.CriticalPath...)
+		est.Contributions = append(est.Contributions, Contribution{Package: p, Duration: cost[node]})
+	}
+	if last != "" {
+		est.CriticalPathDuration = finish[last]
+	}
+	for i := range est.Contributions {
+		if est.CriticalPathDuration > 0 {
+			est.Contributions[i].Share = float64(est.Contributions[i].Duration) / float64(est.CriticalPathDuration)
+		}
+	}
+	sort.SliceStable(est.Contributions, func(i, j int) bool {
+		return est.Contributions[i].Duration > est.Contributions[j].Duration
+	})
+
+	// priority is the longest time from the start of a package until all of its
+	// dependents are done, which is what list scheduling should minimize.
+	priority := make(map[string]time.Duration, len(sorted))
+	for _, p := range sorted {
+		node := PackageHash(p)
+		var longest time.Duration
+		for dependent := range pmap[node] {
+			if priority[dependent] > longest {
+				longest = priority[dependent]
+			}
+		}
+		priority[node] = cost[node] + longest
+	}
+
+	est.WallClock = simulate(amap, pmap, cost, priority, workers)
+
+	return est, nil
+}
+
+// simulate runs a list scheduling simulation of building the graph with the
+// given number of workers, always starting the ready package with the highest
+// priority first, and returns the time the last package finishes.
+func simulate(amap, pmap map[string]map[string]graph.Edge[string], cost, priority map[string]time.Duration, workers int) time.Duration {
+	remaining := make(map[string]int, len(amap))
+	var ready []string
+	for node, deps := range amap {
+		remaining[node] = len(deps)
+		if len(deps) == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	var (
+		now     time.Duration
+		running = &buildHeap{}
+	)
+	for len(ready) > 0 || running.Len() > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			if priority[ready[i]] != priority[ready[j]] {
+				return priority[ready[i]] > priority[ready[j]]
+			}
+			return ready[i] < ready[j]
+		})
+		for len(ready) > 0 && (workers <= 0 || running.Len() < workers) {
+			heap.Push(running, runningBuild{node: ready[0], done: now + cost[ready[0]]})
+			ready = ready[1:]
+		}
+
+		b := heap.Pop(running).(runningBuild) //nolint:errcheck // only builds are pushed
+		now = b.done
+		for dependent := range pmap[b.node] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	return now
+}
+
+type runningBuild struct {
+	node string
+	done time.Duration
+}
+
+// buildHeap holds the running builds, the one finishing first on top.
+type buildHeap []runningBuild
+
+func (h buildHeap) Len() int { return len(h) }
+func (h buildHeap) Less(i, j int) bool {
+	if h[i].done != h[j].done {
+		return h[i].done < h[j].done
+	}
+	return h[i].node < h[j].node
+}
+func (h buildHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *buildHeap) Push(x any)   { *h = append(*h, x.(runningBuild)) } //nolint:errcheck // only builds are pushed
+func (h *buildHeap) Pop() any {
+	old := *h
+	b := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return b
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDurations(t *testing.T) {
+	durations, err := ReadDurations(strings.NewReader(`
+# from the last full rebuild
+gcc 1h15m
+zlib	30s
+`))
+	require.NoError(t, err)
+	assert.Equal(t, Durations{"gcc": 75 * time.Minute, "zlib": 30 * time.Second}, durations)
+
+	_, err = ReadDurations(strings.NewReader("gcc\n"))
+	assert.Error(t, err)
+
+	_, err = ReadDurations(strings.NewReader("gcc forever\n"))
+	assert.Error(t, err)
+}
+
+func TestDurationsFromIndex(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	durations := DurationsFromIndex([]*apk.Package{
+		{Name: "zlib", Origin: "zlib", BuildTime: start},
+		{Name: "openssl", Origin: "openssl", BuildTime: start.Add(9 * time.Minute)},
+		{Name: "openssl-dev", Origin: "openssl", BuildTime: start.Add(10 * time.Minute)},
+		{Name: "curl", Origin: "curl", BuildTime: start.Add(13 * time.Minute)},
+	})
+	assert.Equal(t, Durations{"openssl": 10 * time.Minute, "curl": 3 * time.Minute}, durations)
+}
+
+func TestEstimate(t *testing.T) {
+	g := &Graph{Graph: newGraph(), byName: map[string][]string{}}
+	hash := func(name string) string {
+		return PackageHash(externalPackage{name, "1", "test"})
+	}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, g.addVertex(externalPackage{name, "1", "test"}))
+	}
+	// a depends on b and c, which both depend on d. e is independent.
+	for _, e := range [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}} {
+		require.NoError(t, g.Graph.AddEdge(hash(e[0]), hash(e[1])))
+	}
+	durations := Durations{
+		"a": 1 * time.Minute,
+		"b": 2 * time.Minute,
+		"c": 5 * time.Minute,
+		"d": 3 * time.Minute,
+	}
+
+	names := func(pkgs []Package) []string {
+		var out []string
+		for _, p := range pkgs {
+			out = append(out, p.Name())
+		}
+		return out
+	}
+
+	t.Run("unlimited workers", func(t *testing.T) {
+		est, err := g.Estimate(durations, 4*time.Minute, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d", "c", "a"}, names(est.CriticalPath))
+		assert.Equal(t, 9*time.Minute, est.CriticalPathDuration)
+		assert.Equal(t, 15*time.Minute, est.Total)
+		assert.Equal(t, 9*time.Minute, est.WallClock)
+		assert.Equal(t, []string{"e"}, est.Unknown)
+
+		require.Len(t, est.Contributions, 3)
+		assert.Equal(t, "c", est.Contributions[0].Package.Name())
+		assert.InDelta(t, 5.0/9, est.Contributions[0].Share, 0.001)
+	})
+
+	t.Run("one worker", func(t *testing.T) {
+		est, err := g.Estimate(durations, 4*time.Minute, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 15*time.Minute, est.WallClock)
+	})
+
+	t.Run("two workers", func(t *testing.T) {
+		// d and e start together, then c and b, then a.
+		est, err := g.Estimate(durations, 4*time.Minute, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 9*time.Minute, est.WallClock)
+	})
+}
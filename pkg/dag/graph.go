@@ -21,12 +21,15 @@ import (
 
 	"chainguard.dev/apko/pkg/apk/apk"
 	"chainguard.dev/apko/pkg/apk/auth"
+	"chainguard.dev/melange/pkg/config"
 	"github.com/chainguard-dev/clog"
 )
 
 const (
-	attributePkgList = "package-list"
-	attributeDepName = "dependency-name"
+	attributePkgList    = "package-list"
+	attributeDepName    = "dependency-name"
+	attributeProvidedBy = "provided-by"
+	attributeDeclared   = "declared-dependencies"
 )
 
 // Graph represents an interdependent set of packages defined in one or more Melange configurations,
@@ -37,8 +40,12 @@ type Graph struct {
 	packages  *Packages
 	opts      *graphOptions
 	resolvers map[string]*apk.PkgResolver // maintains a listing of all resolvers by key
+	repos     map[string][]apk.NamedIndex // the indexes of each resolver by key, created when first needed
 	byName    map[string][]string         // maintains a listing of all known hashes for a given name
 
+	resolutions *resolutionCache // caches what resolvers find, if WithResolutionCache is set
+	builtRepo   apk.NamedIndex   // the packages built locally for the graph's architecture, if WithLocalBuilds is set
+
 	discovered map[string]struct{} // For each repo we encounter, we want to attempt to discover keys for it only once
 }
 
@@ -74,9 +81,13 @@ func NewGraph(ctx context.Context, pkgs *Packages, options ...GraphOptions) (*Gr
 		packages:   pkgs,
 		opts:       opts,
 		resolvers:  make(map[string]*apk.PkgResolver),
+		repos:      make(map[string][]apk.NamedIndex),
 		byName:     map[string][]string{},
 		discovered: map[string]struct{}{},
 	}
+	if opts.allowCycles {
+		g.Graph = graph.New(PackageHash, graph.Directed())
+	}
 
 	// indexes is a cache of all repositories. Only some might be used for each package.
 	var (
@@ -92,8 +103,12 @@ func NewGraph(ctx context.Context, pkgs *Packages, options ...GraphOptions) (*Gr
 
 	localRepo := pkgs.Repository(opts.arch)
 	localRepoSource := localRepo.Source()
+	g.builtRepo = opts.builds.index(opts.arch)
 	localOnlyResolver := apk.NewPkgResolver(ctx, []apk.NamedIndex{localRepo})
 	g.resolvers[localRepoSource] = localOnlyResolver
+	if opts.cache != nil {
+		g.resolutions = newResolutionCache(opts.cache, opts.arch, localRepo)
+	}
 
 	// the order of adding packages is quite important:
 	// 1. Go through each origin package and add it as a vertex
@@ -115,7 +130,8 @@ func NewGraph(ctx context.Context, pkgs *Packages, options ...GraphOptions) (*Gr
 				}
 				parentHash := PackageHash(c)
 				attrs := map[string]string{
-					attributePkgList: parentHash,
+					attributePkgList:  parentHash,
+					attributeEdgeType: string(EdgeSubpackage),
 				}
 				if err := g.Graph.AddEdge(PackageHash(subpkgVersion), parentHash, graph.EdgeAttributes(attrs)); err != nil && !errors.Is(err, graph.ErrEdgeAlreadyExists) {
 					// a subpackage always must depend on its origin package. It is not acceptable to have any errors, other than that we already know about that dependency.
@@ -142,11 +158,76 @@ func NewGraph(ctx context.Context, pkgs *Packages, options ...GraphOptions) (*Gr
 
 		// wolfi-dev has a policy for environment packages not to use a package to fulfull a dependency, if that package is myself.
 		// if I depend on something, and the dependency is the same name as me, it must have a lower version than myself
-		addErrs := g.resolvePackages(ctx, c, "environment", localRepoSource, resolverKey, c.Environment.Contents.Packages, false)
+		addErrs := g.resolvePackages(ctx, c, "environment", EdgeBuildtime, localRepoSource, resolverKey, c.Environment.Contents.Packages, false)
 		if len(addErrs) > 0 {
 			errs = append(errs, addErrs...)
 		}
 	}
+
+	if opts.runtime {
+		for _, c := range pkgs.Packages() {
+			resolverKey, err := g.addResolverForRepos(ctx,
+				opts.arch,
+				localRepo,
+				indexes,
+				keys,
+				append(c.Environment.Contents.BuildRepositories, opts.repos...),
+				append(c.Environment.Contents.Keyring, opts.keys...),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("unable to create resolver for %s: %w", c.String(), err)
+			}
+
+			// a package is allowed to depend on itself or its own subpackages at runtime, but that is not an edge in the graph
+			errs = append(errs, g.resolvePackages(ctx, c, "runtime", EdgeRuntime, localRepoSource, resolverKey, c.Package.Dependencies.Runtime, true)...)
+			for i := range c.Subpackages {
+				sub := pkgs.subpackageConfig(c, c.Subpackages[i].Name)
+				if sub == nil {
+					continue
+				}
+				errs = append(errs, g.resolvePackages(ctx, sub, "runtime", EdgeRuntime, localRepoSource, resolverKey, c.Subpackages[i].Dependencies.Runtime, true)...)
+			}
+		}
+	}
+
+	if opts.test {
+		// resolveTest adds the test dependencies of parent, a package or subpackage
+		resolveTest := func(parent Package, test *config.Test) error {
+			if test == nil {
+				return nil
+			}
+			resolverKey, err := g.addResolverForRepos(ctx,
+				opts.arch,
+				localRepo,
+				indexes,
+				keys,
+				append(test.Environment.Contents.BuildRepositories, opts.repos...),
+				append(test.Environment.Contents.Keyring, opts.keys...),
+			)
+			if err != nil {
+				return fmt.Errorf("unable to create resolver for %s: %w", parent, err)
+			}
+			// a test runs with the package under test installed, so like at runtime a package
+			// is allowed to depend on itself or its own subpackages
+			errs = append(errs, g.resolvePackages(ctx, parent, "test", EdgeTest, localRepoSource, resolverKey, test.Environment.Contents.Packages, true)...)
+			return nil
+		}
+		for _, c := range pkgs.Packages() {
+			if err := resolveTest(c, c.Test); err != nil {
+				return nil, err
+			}
+			for i := range c.Subpackages {
+				sub := pkgs.subpackageConfig(c, c.Subpackages[i].Name)
+				if sub == nil {
+					continue
+				}
+				if err := resolveTest(sub, c.Subpackages[i].Test); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
 	if errs != nil {
 		return nil, fmt.Errorf("unable to build graph:\n%w", errors.Join(errs...))
 	}
@@ -223,6 +304,9 @@ func (g *Graph) addResolverForRepos(ctx context.Context, arch string, localRepo
 	if localRepo != nil {
 		lookupRepos = append(lookupRepos, localRepo)
 	}
+	if g.builtRepo != nil {
+		lookupRepos = append(lookupRepos, g.builtRepo)
+	}
 
 	// creating a resolver can be expensive, so we cache any that already exist that have exactly
 	// the same repositories.
@@ -237,17 +321,88 @@ func (g *Graph) addResolverForRepos(ctx context.Context, arch string, localRepo
 	resolverKey = strings.Join(keys, ",")
 
 	// add packages from build-time, as they could be local only, or might have upstream
-	if _, ok := g.resolvers[resolverKey]; !ok {
-		g.resolvers[resolverKey] = apk.NewPkgResolver(ctx, lookupRepos)
+	if _, ok := g.repos[resolverKey]; !ok {
+		g.repos[resolverKey] = lookupRepos
 	}
 	return resolverKey, nil
 }
 
+// resolver returns the resolver with the given key, creating it if needed.
+func (g *Graph) resolver(ctx context.Context, resolverKey string) (*apk.PkgResolver, error) {
+	if resolver, ok := g.resolvers[resolverKey]; ok {
+		return resolver, nil
+	}
+	repos, ok := g.repos[resolverKey]
+	if !ok {
+		return nil, fmt.Errorf("unable to find resolver for %s", resolverKey)
+	}
+	resolver := apk.NewPkgResolver(ctx, repos)
+	g.resolvers[resolverKey] = resolver
+	return resolver, nil
+}
+
+// resolvedPackage is a package a resolver found to fulfill a dependency, with
+// what building the graph needs to know about it.
+type resolvedPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Origin  string `json:"origin"`
+
+	// IndexURI and URI are those of the repository the package is from.
+	IndexURI string `json:"indexURI"`
+	URI      string `json:"uri"`
+
+	// Provides is the entry the package provides the dependency with, if it
+	// doesn't fulfill it by name.
+	Provides string `json:"provides,omitempty"`
+}
+
+// resolveDependency returns the packages the resolver with the given key finds
+// to fulfill dep, best first, using the resolution cache if there is one.
+func (g *Graph) resolveDependency(ctx context.Context, resolverKey, dep string) ([]resolvedPackage, error) {
+	var key string
+	if g.resolutions != nil {
+		if repos, ok := g.repos[resolverKey]; ok {
+			key = g.resolutions.key(repos, dep)
+			if entry, ok := g.resolutions.get(key); ok {
+				return entry.Packages, entry.err()
+			}
+		}
+	}
+
+	resolver, err := g.resolver(ctx, resolverKey)
+	if err != nil {
+		return nil, err
+	}
+	// disqualified packages
+	dq := map[*apk.RepositoryPackage]string{}
+	found, resolveErr := resolver.ResolvePackage(dep, dq)
+	resolved := make([]resolvedPackage, 0, len(found))
+	for _, r := range found {
+		resolved = append(resolved, resolvedPackage{
+			Name:     r.Name,
+			Version:  r.Version,
+			Origin:   r.Origin,
+			IndexURI: r.Repository().IndexURI(),
+			URI:      r.Repository().URI,
+			Provides: providesEntry(r.Provides, dep),
+		})
+	}
+
+	if key != "" {
+		if err := g.resolutions.put(key, resolved, resolveErr); err != nil {
+			clog.FromContext(ctx).Warnf("unable to cache resolution of %s: %v", dep, err)
+		}
+	}
+	return resolved, resolveErr
+}
+
 // resolvePackages given a package `parent`, a list of packages `pkgs` and a `resolver`,
 // use the resolver to find all of the packages that fulfill the requirements and add them
 // to the graph as the parent's dependencies.
 // Optionally, can allow self to resolve dependencies or not. This is policy driven.
-func (g *Graph) resolvePackages(ctx context.Context, parent Package, source, localRepoSource, resolverKey string, pkgs []string, allowSelf bool) (errs []error) {
+// The edges added are of the given edgeType.
+func (g *Graph) resolvePackages(ctx context.Context, parent Package, source string, edgeType EdgeType, localRepoSource, resolverKey string, pkgs []string, allowSelf bool) (errs []error) {
 	log := clog.FromContext(ctx)
 	for _, buildDep := range pkgs {
 		if buildDep == "" {
@@ -260,7 +415,7 @@ func (g *Graph) resolvePackages(ctx context.Context, parent Package, source, loc
 			continue
 		}
 
-		cycle, err := g.addAppropriatePackageFromResolver(resolverKey, parent, buildDep, localRepoSource, allowSelf)
+		cycle, err := g.addAppropriatePackageFromResolver(ctx, resolverKey, parent, buildDep, localRepoSource, edgeType, allowSelf)
 		if err != nil {
 			errs = append(errs, err)
 			continue
@@ -280,23 +435,23 @@ func (g *Graph) resolvePackages(ctx context.Context, parent Package, source, loc
 
 // addAppropriatePackageFromResolver adds the appropriate package to the graph, and returns any cycle that was created.
 // The c *Configuration is the source package, while the dep represents the dependency.
-// Whether or not this package is allowed to resolve itself is policy driven.
-func (g *Graph) addAppropriatePackageFromResolver(resolverKey string, c Package, dep, localRepo string, allowSelf bool) (*cycle, error) {
+// Whether or not this package is allowed to resolve itself is policy driven. When it is,
+// anything built by the same origin package counts as itself.
+func (g *Graph) addAppropriatePackageFromResolver(ctx context.Context, resolverKey string, c Package, dep, localRepo string, edgeType EdgeType, allowSelf bool) (*cycle, error) {
 	var (
 		pkg    Package
 		pkgKey = PackageHash(c)
 	)
-	resolver, ok := g.resolvers[resolverKey]
-	if !ok {
-		return nil, fmt.Errorf("unable to find resolver for %s", resolverKey)
+	if _, ok := g.resolvers[resolverKey]; !ok {
+		if _, ok := g.repos[resolverKey]; !ok {
+			return nil, fmt.Errorf("unable to find resolver for %s", resolverKey)
+		}
 	}
 
-	// disqualified packages
-	dq := map[*apk.RepositoryPackage]string{}
-	resolved, err := resolver.ResolvePackage(dep, dq)
+	resolved, err := g.resolveDependency(ctx, resolverKey, dep)
 	switch {
 	case (err != nil || len(resolved) == 0) && g.opts.allowUnresolved:
-		if err := g.addDanglingPackage(dep, c); err != nil {
+		if err := g.addDanglingPackage(dep, c, edgeType); err != nil {
 			return nil, fmt.Errorf("%s: unable to add dangling package %s: %w", c, dep, err)
 		}
 	case (err != nil || len(resolved) == 0):
@@ -305,17 +460,18 @@ func (g *Graph) addAppropriatePackageFromResolver(resolverKey string, c Package,
 		// no error and we had at least one package listed in `resolved`
 		// make a list of all the possible packages that could fulfill this dependency
 		var (
-			matchList = make([]string, 0, len(resolved))
-			pkgs      = make([]Package, 0, len(resolved))
+			matchList  = make([]string, 0, len(resolved))
+			pkgs       = make([]Package, 0, len(resolved))
+			providedBy []string
 		)
 		for _, r := range resolved {
 			// we only care about self-providing packages if it's a locally defined package,
 			// else it's fine that the remote repo just happens to provide the same version
-			isLocal := r.Repository().IndexURI() == localRepo
+			isLocal := r.IndexURI == localRepo
 
 			// if we allow self, and our name or origin is the same as dep, and the version is the same, then we are done
 			isSelf := r.Version == c.Version() && (dep == c.Name() || r.Origin == c.Name()) && isLocal
-			if allowSelf && isSelf {
+			if allowSelf && (isSelf || (r.Version == c.Version() && r.Origin == originName(c) && isLocal)) {
 				return nil, nil
 			}
 			// we do not allow self, so if match, ignore it and look for next one
@@ -339,13 +495,16 @@ func (g *Graph) addAppropriatePackageFromResolver(resolverKey string, c Package,
 					return nil, fmt.Errorf("unable to find package %s-%s in local repository", r.Name, r.Version)
 				}
 			} else {
-				pkg = externalPackage{r.Name, r.Version, r.Repository().URI}
+				pkg = externalPackage{r.Name, r.Version, r.URI}
 			}
 			if err := g.addVertex(pkg); err != nil && !errors.Is(err, graph.ErrVertexAlreadyExists) {
 				return nil, fmt.Errorf("unable to add vertex for %s dependency %s: %w", c, dep, err)
 			}
 			pkgs = append(pkgs, pkg)
 			matchList = append(matchList, PackageHash(pkg))
+			if r.Provides != "" {
+				providedBy = append(providedBy, PackageHash(pkg)+"="+r.Provides)
+			}
 		}
 
 		// Couldn't find any candidates for this package, exit early.
@@ -356,10 +515,15 @@ func (g *Graph) addAppropriatePackageFromResolver(resolverKey string, c Package,
 		var (
 			allPkgs = strings.Join(matchList, " ")
 			attrs   = map[string]string{
-				attributePkgList: allPkgs,
-				attributeDepName: dep,
+				attributePkgList:  allPkgs,
+				attributeDepName:  dep,
+				attributeEdgeType: string(edgeType),
+				attributeDeclared: declaredAttribute(edgeType, dep),
 			}
 		)
+		if len(providedBy) > 0 {
+			attrs[attributeProvidedBy] = strings.Join(providedBy, " ")
+		}
 		// make sure the vertexes exist
 		for _, p := range pkgs {
 			if err := g.addVertex(p); err != nil && !errors.Is(err, graph.ErrVertexAlreadyExists) {
@@ -381,9 +545,9 @@ func (g *Graph) addAppropriatePackageFromResolver(resolverKey string, c Package,
 func (g *Graph) addAppropriatePackageFromList(pkgKey string, matchList []string, attrs map[string]string) (*cycle, error) {
 	var cycleTarget string
 	for _, target := range matchList {
-		err := g.Graph.AddEdge(pkgKey, target, graph.EdgeAttributes(attrs))
+		err := addTypedEdge(g.Graph, pkgKey, target, attrs)
 		switch {
-		case err == nil || errors.Is(err, graph.ErrEdgeAlreadyExists):
+		case err == nil:
 			// no error, so we can keep the vertex and we have our match
 			return nil, nil
 		case errors.Is(err, graph.ErrEdgeCreatesCycle):
@@ -519,15 +683,26 @@ func (g *Graph) addVertex(pkg Package) error {
 	return nil
 }
 
-func (g *Graph) addDanglingPackage(name string, parent Package) error {
+func (g *Graph) addDanglingPackage(name string, parent Package, edgeType EdgeType) error {
 	pkg := danglingPackage{name}
 	if err := g.addVertex(pkg); err != nil && !errors.Is(err, graph.ErrVertexAlreadyExists) {
 		return err
 	}
-	if err := g.Graph.AddEdge(PackageHash(parent), PackageHash(pkg)); err != nil && !errors.Is(err, graph.ErrEdgeAlreadyExists) {
-		return err
+	attrs := map[string]string{
+		attributeDepName:  name,
+		attributeEdgeType: string(edgeType),
+		attributeDeclared: declaredAttribute(edgeType, name),
 	}
-	return nil
+	return addTypedEdge(g.Graph, PackageHash(parent), PackageHash(pkg), attrs)
+}
+
+// originName returns the name of the origin package that builds p. Anything that
+// is not a local configuration is its own origin.
+func originName(p Package) string {
+	if c, ok := p.(*Configuration); ok {
+		return c.Package.Name
+	}
+	return p.Name()
 }
 
 // Sorted returns a list of all package names in the Graph, sorted in topological
@@ -617,7 +792,7 @@ func (g Graph) SubgraphWithLeaves(leaves []string) (*Graph, error) {
 			if err := subgraph.addVertex(c); err != nil && !errors.Is(err, graph.ErrVertexAlreadyExists) {
 				return err
 			}
-			if err := subgraph.Graph.AddEdge(dependent, key); err != nil && !errors.Is(err, graph.ErrEdgeAlreadyExists) {
+			if err := addTypedEdge(subgraph.Graph, dependent, key, predecessorMap[key][dependent].Properties.Attributes); err != nil {
 				return err
 			}
 
@@ -737,7 +912,7 @@ func (g Graph) Filter(filter Filter) (*Graph, error) {
 				continue
 			}
 			// both the node and the dependency are in the new graph, so keep the edge
-			if err := subgraph.Graph.AddEdge(edge.Source, edge.Target); err != nil && !errors.Is(err, graph.ErrEdgeAlreadyExists) {
+			if err := addTypedEdge(subgraph.Graph, edge.Source, edge.Target, edge.Properties.Attributes); err != nil {
 				return nil, err
 			}
 		}
@@ -1018,7 +1193,7 @@ func (g Graph) Targets() (*Graph, error) { //nolint:gocyclo
 			}
 
 			if target.Source() != Local {
-				if err := subgraph.Graph.AddEdge(PackageHash(source), PackageHash(target)); err != nil && !errors.Is(err, graph.ErrEdgeAlreadyExists) {
+				if err := addTypedEdge(subgraph.Graph, PackageHash(source), PackageHash(target), edgeTypeAttributes(edge)); err != nil {
 					if !errors.Is(err, graph.ErrEdgeCreatesCycle) {
 						return nil, fmt.Errorf("%q (%q) -> %q (%q): %w", source, edge.Source, target, edge.Target, err)
 					}
@@ -1045,7 +1220,7 @@ func (g Graph) Targets() (*Graph, error) { //nolint:gocyclo
 				continue
 			}
 
-			if err := subgraph.Graph.AddEdge(PackageHash(source), PackageHash(target)); err != nil && !errors.Is(err, graph.ErrEdgeAlreadyExists) {
+			if err := addTypedEdge(subgraph.Graph, PackageHash(source), PackageHash(target), edgeTypeAttributes(edge)); err != nil {
 				if !errors.Is(err, graph.ErrEdgeCreatesCycle) {
 					return nil, fmt.Errorf("%q (%q) -> %q (%q): %w", source, edge.Source, target, edge.Target, err)
 				}
@@ -5,6 +5,11 @@ type graphOptions struct {
 	repos           []string
 	keys            []string
 	arch            string
+	runtime         bool
+	test            bool
+	allowCycles     bool
+	cache           *Cache
+	builds          *LocalBuilds
 }
 
 type GraphOptions func(*graphOptions) error
@@ -47,3 +52,45 @@ func WithArch(arch string) GraphOptions {
 		return nil
 	}
 }
+
+// WithRuntimeDeps adds the runtime dependencies of packages and subpackages,
+// i.e. dependencies.runtime, to the graph as EdgeRuntime edges.
+func WithRuntimeDeps() GraphOptions {
+	return func(o *graphOptions) error {
+		o.runtime = true
+		return nil
+	}
+}
+
+// WithTestDeps adds the test dependencies of packages and subpackages, i.e.
+// test.environment.contents.packages, to the graph as EdgeTest edges.
+func WithTestDeps() GraphOptions {
+	return func(o *graphOptions) error {
+		o.test = true
+		return nil
+	}
+}
+
+// WithAllowCycles builds the graph as declared, even if dependencies form
+// cycles, instead of failing or resolving a cycle by picking another version of
+// a dependency. Each dependency resolves to its best candidate. This is meant
+// for diagnosing cycles with Cycles; sorting, filtering or flattening such a
+// graph fails if it has a cycle.
+func WithAllowCycles() GraphOptions {
+	return func(o *graphOptions) error {
+		o.allowCycles = true
+		return nil
+	}
+}
+
+// WithResolutionCache caches what each dependency resolves to in cache, so
+// that building the graph again only resolves the dependencies whose
+// candidates changed. A resolution is keyed by the digests of the remote
+// indexes it is made against, and by the local packages that could fulfill
+// the dependency.
+func WithResolutionCache(cache *Cache) GraphOptions {
+	return func(o *graphOptions) error {
+		o.cache = cache
+		return nil
+	}
+}
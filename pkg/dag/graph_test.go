@@ -296,3 +296,86 @@ func TestTargets(t *testing.T) {
 		assert.ElementsMatch(t, want, keys, "unexpected dependencies for %s", k)
 	}
 }
+
+func TestEdgeTypes(t *testing.T) {
+	ctx := context.Background()
+	testDir := "testdata/subpackages"
+
+	pkgs, err := NewPackages(ctx, os.DirFS(testDir), testDir, nil)
+	require.NoError(t, err)
+
+	t.Run("buildtime only by default", func(t *testing.T) {
+		graph, err := NewGraph(ctx, pkgs, WithAllowUnresolved())
+		require.NoError(t, err)
+
+		types, err := graph.EdgeTypes("two:4.5.6-r1@local", "one-dev:1.2.3-r1@local")
+		require.NoError(t, err)
+		assert.Equal(t, []EdgeType{EdgeBuildtime}, types)
+
+		types, err = graph.EdgeTypes("one-dev:1.2.3-r1@local", "one:1.2.3-r1@local")
+		require.NoError(t, err)
+		assert.Equal(t, []EdgeType{EdgeSubpackage}, types)
+
+		_, err = graph.EdgeTypes("three:4.5.6-r1@local", "one:1.2.3-r1@local")
+		assert.Error(t, err)
+	})
+
+	t.Run("runtime", func(t *testing.T) {
+		graph, err := NewGraph(ctx, pkgs, WithAllowUnresolved(), WithRuntimeDeps())
+		require.NoError(t, err)
+		graph, err = graph.Filter(FilterLocal())
+		require.NoError(t, err)
+		graph, err = graph.Targets()
+		require.NoError(t, err)
+
+		expected := map[[2]string][]EdgeType{
+			{"three:4.5.6-r1@local", "one:1.2.3-r1@local"}: {EdgeRuntime},
+			{"three:4.5.6-r1@local", "two:4.5.6-r1@local"}: {EdgeBuildtime},
+			{"two:4.5.6-r1@local", "one:1.2.3-r1@local"}:   {EdgeBuildtime},
+		}
+		edges, err := graph.Graph.Edges()
+		require.NoError(t, err)
+		assert.Len(t, edges, len(expected))
+		for e, want := range expected {
+			got, err := graph.EdgeTypes(e[0], e[1])
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "unexpected edge types for %s -> %s", e[0], e[1])
+		}
+	})
+	t.Run("test", func(t *testing.T) {
+		graph, err := NewGraph(ctx, pkgs, WithAllowUnresolved(), WithTestDeps())
+		require.NoError(t, err)
+
+		// Tests depending on the package under test or its origin aren't edges.
+		types, err := graph.EdgeTypes("three:4.5.6-r1@local", "one-dev:1.2.3-r1@local")
+		require.NoError(t, err)
+		assert.Equal(t, []EdgeType{EdgeTest}, types)
+		types, err = graph.EdgeTypes("one-dev:1.2.3-r1@local", "one:1.2.3-r1@local")
+		require.NoError(t, err)
+		assert.Equal(t, []EdgeType{EdgeSubpackage}, types)
+
+		graph, err = graph.Filter(FilterLocal())
+		require.NoError(t, err)
+		graph, err = graph.Targets()
+		require.NoError(t, err)
+		expected := map[[2]string][]EdgeType{
+			{"three:4.5.6-r1@local", "one:1.2.3-r1@local"}: {EdgeTest},
+			{"three:4.5.6-r1@local", "two:4.5.6-r1@local"}: {EdgeBuildtime},
+			{"two:4.5.6-r1@local", "one:1.2.3-r1@local"}:   {EdgeBuildtime},
+		}
+		edges, err := graph.Graph.Edges()
+		require.NoError(t, err)
+		assert.Len(t, edges, len(expected))
+		for e, want := range expected {
+			got, err := graph.EdgeTypes(e[0], e[1])
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "unexpected edge types for %s -> %s", e[0], e[1])
+		}
+
+		dependents, err := graph.Dependents([]string{"one:1.2.3-r1@local"}, 1, EdgeTest)
+		require.NoError(t, err)
+		nodes, err := dependents.Nodes()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"one:1.2.3-r1@local", "three:4.5.6-r1@local"}, nodes)
+	})
+}
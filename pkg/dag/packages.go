@@ -146,11 +146,28 @@ func (p *Packages) addProvides(c *Configuration, provides []string) error {
 //
 // NewPackages(ctx, os.DirFS("/path/to/dir"), "/path/to/dir", []string{"./pipelines"})
 //
-// The repetition of the path is necessary because of how the upstream parser in
-// melange requires the full path to the directory to be passed in.
-func NewPackages(ctx context.Context, fsys fs.FS, dirPath string, pipelineDirs []string) (*Packages, error) {
+// Configs are read from fsys, which need not be a directory on disk, e.g. a
+// tree at some commit of a git repository. dirPath is only used to record the
+// path each config came from.
+func NewPackages(ctx context.Context, fsys fs.FS, dirPath string, pipelineDirs []string, options ...PackagesOptions) (*Packages, error) {
 	log := clog.FromContext(ctx)
 
+	var opts = &packagesOptions{}
+	for _, option := range options {
+		if err := option(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	loader := &configLoader{fsys: fsys, pipelineDirs: pipelineDirs, cache: opts.cache}
+	if opts.cache != nil {
+		d, err := pipelinesDigest(pipelineDirs)
+		if err != nil {
+			return nil, err
+		}
+		loader.pipelines = d
+	}
+
 	pkgs := &Packages{
 		configs:  make(map[string][]*Configuration),
 		packages: make(map[string][]*Configuration),
@@ -189,7 +206,7 @@ func NewPackages(ctx context.Context, fsys fs.FS, dirPath string, pipelineDirs [
 
 		g.Go(func() error {
 			p := filepath.Join(dirPath, path)
-			buildc, err := config.ParseConfiguration(ctx, p)
+			buildc, err := config.ParseConfiguration(ctx, path, config.WithFS(fsys))
 			if err != nil {
 				return err
 			}
@@ -239,14 +256,9 @@ func NewPackages(ctx context.Context, fsys fs.FS, dirPath string, pipelineDirs [
 			}
 			// Resolve all `uses` used by the pipeline. This updates the set of
 			// .environment.contents.packages so the next block can include those as build deps.
-			build := &build.Build{
-				PipelineDirs:  pipelineDirs,
-				Configuration: c.Configuration,
-			}
-			if err := build.Compile(ctx); err != nil {
+			if err := loader.compile(ctx, path, c.Configuration); err != nil {
 				return fmt.Errorf("compiling build: %w", err)
 			}
-			c.Environment.Contents.Packages = build.Configuration.Environment.Contents.Packages
 
 			return nil
 		})
@@ -264,6 +276,90 @@ func NewPackages(ctx context.Context, fsys fs.FS, dirPath string, pipelineDirs [
 	return pkgs, nil
 }
 
+// configLoader compiles configs, caching what compiling them adds that the
+// graph uses if it has a cache.
+type configLoader struct {
+	fsys         fs.FS
+	pipelineDirs []string
+	cache        *Cache
+
+	// pipelines is the digest of the pipeline directories.
+	pipelines string
+}
+
+// compiledEntry is how the compilation of a config is cached: the packages
+// its pipelines need, which compiling adds to the environments of its build
+// and tests. The configs themselves are always parsed, so that they are
+// complete, including their YAML AST.
+type compiledEntry struct {
+	Environment     []string            `json:"environment,omitempty"`
+	Test            []string            `json:"test,omitempty"`
+	SubpackageTests map[string][]string `json:"subpackageTests,omitempty"`
+}
+
+// compile resolves the pipelines of the configuration parsed from path,
+// adding the packages they need to its environments. On a cache hit, only the
+// environments are updated and the pipelines are left as parsed.
+func (l *configLoader) compile(ctx context.Context, path string, cfg *config.Configuration) error {
+	var key string
+	if l.cache != nil {
+		b, err := fs.ReadFile(l.fsys, path)
+		if err != nil {
+			return err
+		}
+		key = cacheKey("config", path, digest(b), l.pipelines)
+		var cached compiledEntry
+		if l.cache.get("configs", key, &cached) {
+			cached.apply(cfg)
+			return nil
+		}
+	}
+
+	build := &build.Build{
+		PipelineDirs:  l.pipelineDirs,
+		Configuration: cfg,
+	}
+	if err := build.Compile(ctx); err != nil {
+		return err
+	}
+
+	if key != "" {
+		if err := l.cache.put("configs", key, newCompiledEntry(cfg)); err != nil {
+			clog.FromContext(ctx).Warnf("unable to cache %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func newCompiledEntry(cfg *config.Configuration) compiledEntry {
+	e := compiledEntry{Environment: cfg.Environment.Contents.Packages}
+	if cfg.Test != nil {
+		e.Test = cfg.Test.Environment.Contents.Packages
+	}
+	for _, sp := range cfg.Subpackages {
+		if sp.Test == nil {
+			continue
+		}
+		if e.SubpackageTests == nil {
+			e.SubpackageTests = map[string][]string{}
+		}
+		e.SubpackageTests[sp.Name] = sp.Test.Environment.Contents.Packages
+	}
+	return e
+}
+
+func (e compiledEntry) apply(cfg *config.Configuration) {
+	cfg.Environment.Contents.Packages = e.Environment
+	if cfg.Test != nil {
+		cfg.Test.Environment.Contents.Packages = e.Test
+	}
+	for i := range cfg.Subpackages {
+		if sp := &cfg.Subpackages[i]; sp.Test != nil {
+			sp.Test.Environment.Contents.Packages = e.SubpackageTests[sp.Name]
+		}
+	}
+}
+
 // Config returns the Melange configuration for the package, provides or
 // subpackage with the given name, if the package is present in the Graph. If
 // it's not present, Config returns an empty list.
@@ -318,6 +414,17 @@ func (p *Packages) PkgConfig(pkgName string) *Configuration {
 	return nil
 }
 
+// subpackageConfig returns the configuration of the named subpackage of the
+// origin configuration c, or nil if there is none.
+func (p *Packages) subpackageConfig(c *Configuration, name string) *Configuration {
+	for _, cfg := range p.configs[name] {
+		if cfg.pkg == name && cfg.Path == c.Path && cfg.version == c.version {
+			return cfg
+		}
+	}
+	return nil
+}
+
 // PkgInfo returns the build.Package struct for a given package name.
 // If no such package name is found in the packages, return nil package and nil error.
 func (p *Packages) PkgInfo(pkgName string) *config.Package {
@@ -390,7 +497,8 @@ func wantArch(have string, want []string) bool {
 	}
 
 	for _, a := range want {
-		if a == have {
+		// melange builds packages targeting "all" for every architecture.
+		if a == have || a == "all" {
 			return true
 		}
 	}
@@ -0,0 +1,17 @@
+package dag
+
+type packagesOptions struct {
+	cache *Cache
+}
+
+type PackagesOptions func(*packagesOptions) error
+
+// WithConfigCache caches what compiling each configuration adds to it in
+// cache, so that only configs that changed are compiled again. A configuration
+// is keyed by the content of its file and of the pipeline directories.
+func WithConfigCache(cache *Cache) PackagesOptions {
+	return func(o *packagesOptions) error {
+		o.cache = cache
+		return nil
+	}
+}
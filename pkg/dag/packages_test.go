@@ -2,12 +2,63 @@ package dag
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 
+	"chainguard.dev/melange/pkg/build"
+	"chainguard.dev/melange/pkg/config"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// testConfig is a minimal melange config for tests, with a single pipeline step.
+type testConfig struct {
+	name string
+	// version defaults to 1.0.0, and arch to every architecture.
+	version string
+	arch    string
+
+	provides []string
+	runtime  []string
+	// deps are the packages of the build environment.
+	deps []string
+}
+
+func (c testConfig) file() *fstest.MapFile {
+	version := c.version
+	if version == "" {
+		version = "1.0.0"
+	}
+	s := fmt.Sprintf("package:\n  name: %s\n  version: %q\n  epoch: 0\n", c.name, version)
+	if c.arch != "" {
+		s += "  target-architecture:\n    - " + c.arch + "\n"
+	}
+	if len(c.provides) > 0 || len(c.runtime) > 0 {
+		s += "  dependencies:\n"
+		s += yamlList("    provides", "      ", c.provides)
+		s += yamlList("    runtime", "      ", c.runtime)
+	}
+	s += yamlList("environment:\n  contents:\n    packages", "      ", c.deps)
+	s += "pipeline:\n  - runs: echo " + c.name + "\n"
+	return &fstest.MapFile{Data: []byte(s)}
+}
+
+// yamlList returns key followed by the items, indented by indent, or nothing
+// if there are no items.
+func yamlList(key, indent string, items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	s := key + ":\n"
+	for _, item := range items {
+		s += indent + "- " + item + "\n"
+	}
+	return s
+}
+
 func TestNewPackages(t *testing.T) {
 	ctx := context.Background()
 
@@ -37,3 +88,93 @@ func TestNewPackages(t *testing.T) {
 		}
 	})
 }
+
+func TestWithArch(t *testing.T) {
+	ctx := context.Background()
+
+	// melange builds packages targeting "all" for every architecture, like
+	// packages without a target architecture.
+	fsys := fstest.MapFS{
+		"any.yaml":     testConfig{name: "any"}.file(),
+		"all.yaml":     testConfig{name: "all-arches", arch: "all"}.file(),
+		"x86.yaml":     testConfig{name: "x86-only", arch: "x86_64"}.file(),
+		"aarch64.yaml": testConfig{name: "arm-only", arch: "aarch64"}.file(),
+	}
+	pkgs, err := NewPackages(ctx, fsys, ".", nil)
+	require.NoError(t, err)
+
+	for arch, want := range map[string][]string{
+		"x86_64":  {"all-arches", "any", "x86-only"},
+		"aarch64": {"all-arches", "any", "arm-only"},
+	} {
+		got, err := pkgs.WithArch(arch)
+		require.NoError(t, err)
+		assert.Equal(t, want, got.PackageNames(), arch)
+	}
+}
+
+// TestNewPackagesParsesLikeDisk checks that parsing configs through the fs.FS
+// given to NewPackages yields the same configurations as melange parsing them
+// from their paths on disk, as NewPackages used to.
+func TestNewPackagesParsesLikeDisk(t *testing.T) {
+	ctx := context.Background()
+
+	// A repository with its own pipelines, used from a config in a
+	// subdirectory.
+	repo := t.TempDir()
+	pipelines := filepath.Join(repo, "pipelines")
+	require.NoError(t, os.MkdirAll(pipelines, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(pipelines, "mytool.yaml"), []byte(`name: mytool
+needs:
+  packages:
+    - make
+pipeline:
+  - runs: make
+`), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "tools"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "tools", "tool.melange.yaml"), []byte(`package:
+  name: tool
+  version: 1.0.0
+  epoch: 0
+environment:
+  contents:
+    packages:
+      - busybox
+pipeline:
+  - uses: mytool
+subpackages:
+  - name: tool-dev
+    test:
+      pipeline:
+        - uses: mytool
+`), 0o644))
+
+	for _, tt := range []struct {
+		name, dir    string
+		pipelineDirs []string
+	}{
+		{name: "basic", dir: "testdata/basic"},
+		{name: "multiple", dir: "testdata/multiple"},
+		{name: "subpackages", dir: "testdata/subpackages"},
+		{name: "provides", dir: "testdata/provides"},
+		{name: "pipelines", dir: repo, pipelineDirs: []string{pipelines}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pkgs, err := NewPackages(ctx, os.DirFS(tt.dir), tt.dir, tt.pipelineDirs)
+			require.NoError(t, err)
+			require.NotEmpty(t, pkgs.Packages())
+
+			for _, c := range pkgs.Packages() {
+				want, err := config.ParseConfiguration(ctx, c.Path)
+				require.NoError(t, err)
+				require.NoError(t, (&build.Build{PipelineDirs: tt.pipelineDirs, Configuration: want}).Compile(ctx))
+
+				assert.Equal(t, want.Package, c.Package, c.Path)
+				assert.Equal(t, want.Environment, c.Environment, c.Path)
+				assert.Equal(t, want.Pipeline, c.Pipeline, c.Path)
+				assert.Equal(t, want.Subpackages, c.Subpackages, c.Path)
+				assert.Equal(t, want.Test, c.Test, c.Path)
+			}
+		})
+	}
+}
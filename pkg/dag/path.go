@@ -0,0 +1,180 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dominikbraun/graph"
+)
+
+// Hop is a single dependency on a path through the graph.
+type Hop struct {
+	From, To Package
+	Types    []EdgeType
+
+	// Dependency is the dependency as declared by From, e.g. "so:libssl.so.3".
+	// It's empty for edges between a subpackage and its origin. If From
+	// declared several dependencies that resolved to To, such as foo at
+	// buildtime and foo>=2 at runtime, it's the first of them, and
+	// Graph.EdgeConstraints returns all of them.
+	Dependency string
+
+	// Provides is the entry in the provides of To that satisfied Dependency,
+	// e.g. "so:libssl.so.3=3", or empty if To satisfied it by name.
+	Provides string
+}
+
+// Fields returns the fields of the melange configuration of From that
+// introduced the dependency on To.
+func (h Hop) Fields() []string {
+	fields := make([]string, 0, len(h.Types))
+	for _, t := range h.Types {
+		fields = append(fields, t.Field())
+	}
+	return fields
+}
+
+// Field returns the melange configuration field in which dependencies of this
+// type are declared.
+func (t EdgeType) Field() string {
+	switch t {
+	case EdgeBuildtime:
+		return "environment.contents.packages"
+	case EdgeRuntime:
+		return "dependencies.runtime"
+	case EdgeTest:
+		return "test.environment.contents.packages"
+	case EdgeSubpackage:
+		return "subpackages"
+	default:
+		return string(t)
+	}
+}
+
+// ShortestPath returns the hops on a shortest path from the node source to the
+// node target, following dependencies. Among several shortest paths, the first
+// in alphabetical order of nodes is returned. It returns graph.ErrTargetNotReachable
+// if source doesn't depend on target, directly or transitively.
+func (g Graph) ShortestPath(source, target string) ([]Hop, error) {
+	amap, err := g.Graph.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNodes(amap, source, target); err != nil {
+		return nil, err
+	}
+
+	via := map[string]string{source: ""}
+	queue := []string{source}
+	for len(queue) > 0 && queue[0] != target {
+		node := queue[0]
+		queue = queue[1:]
+		for _, dep := range sortedKeys(amap[node]) {
+			if _, ok := via[dep]; ok {
+				continue
+			}
+			via[dep] = node
+			queue = append(queue, dep)
+		}
+	}
+	if _, ok := via[target]; !ok {
+		return nil, graph.ErrTargetNotReachable
+	}
+
+	path := []string{target}
+	for node := target; node != source; {
+		node = via[node]
+		path = append([]string{node}, path...)
+	}
+	return g.hops(path)
+}
+
+// AllPaths returns the hops on every path from the node source to the node
+// target, shortest first. The number of paths can grow exponentially with the
+// size of the graph, so this is best used between nearby packages.
+func (g Graph) AllPaths(source, target string) ([][]Hop, error) {
+	amap, err := g.Graph.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNodes(amap, source, target); err != nil {
+		return nil, err
+	}
+
+	paths, err := graph.AllPathsBetween(g.Graph, source, target)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return strings.Join(paths[i], " ") < strings.Join(paths[j], " ")
+	})
+
+	out := make([][]Hop, 0, len(paths))
+	for _, path := range paths {
+		hops, err := g.hops(path)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, hops)
+	}
+	return out, nil
+}
+
+func checkNodes(amap map[string]map[string]graph.Edge[string], nodes ...string) error {
+	for _, node := range nodes {
+		if _, ok := amap[node]; !ok {
+			return fmt.Errorf("could not find node %q", node)
+		}
+	}
+	return nil
+}
+
+// hops returns the hops between consecutive nodes of path.
+func (g Graph) hops(path []string) ([]Hop, error) {
+	hops := make([]Hop, 0, len(path))
+	for i := 1; i < len(path); i++ {
+		edge, err := g.Graph.Edge(path[i-1], path[i])
+		if err != nil {
+			return nil, err
+		}
+		attrs := edge.Properties.Attributes
+		hops = append(hops, Hop{
+			From:       edge.Source,
+			To:         edge.Target,
+			Types:      edgeTypes(attrs),
+			Dependency: attrs[attributeDepName],
+			Provides:   providedBy(attrs[attributeProvidedBy], path[i]),
+		})
+	}
+	return hops, nil
+}
+
+// providedBy returns the provides entry recorded for the node in the value of
+// an attributeProvidedBy attribute.
+func providedBy(attr, node string) string {
+	for _, pair := range strings.Fields(attr) {
+		if hash, entry, ok := strings.Cut(pair, "="); ok && hash == node {
+			return entry
+		}
+	}
+	return ""
+}
+
+// providesEntry returns the entry in provides that satisfies the dependency dep,
+// or an empty string if there is none.
+func providesEntry(provides []string, dep string) string {
+	name := dep
+	if i := strings.IndexAny(dep, "<>=~"); i >= 0 {
+		name = dep[:i]
+	}
+	for _, entry := range provides {
+		if p, _ := packageNameFromProvides(entry); p == name {
+			return entry
+		}
+	}
+	return ""
+}
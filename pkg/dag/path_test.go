@@ -0,0 +1,69 @@
+package dag
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/dominikbraun/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaths(t *testing.T) {
+	ctx := context.Background()
+	testDir := "testdata/provides"
+
+	pkgs, err := NewPackages(ctx, os.DirFS(testDir), testDir, nil)
+	require.NoError(t, err)
+	g, err := NewGraph(ctx, pkgs, WithAllowUnresolved(), WithRuntimeDeps())
+	require.NoError(t, err)
+
+	const (
+		app       = "app:2.0.0-r0@local"
+		libfoo    = "libfoo:1.0.0-r0@local"
+		libfooDev = "libfoo-dev:1.0.0-r0@local"
+	)
+
+	type hop struct {
+		from, to   string
+		types      []EdgeType
+		dependency string
+		provides   string
+	}
+	simplify := func(hops []Hop) []hop {
+		out := make([]hop, 0, len(hops))
+		for _, h := range hops {
+			out = append(out, hop{h.From.Name(), h.To.Name(), h.Types, h.Dependency, h.Provides})
+		}
+		return out
+	}
+
+	t.Run("shortest", func(t *testing.T) {
+		hops, err := g.ShortestPath(app, libfoo)
+		require.NoError(t, err)
+		assert.Equal(t, []hop{
+			{"app", "libfoo", []EdgeType{EdgeRuntime}, "so:libfoo.so.1", "so:libfoo.so.1=1"},
+		}, simplify(hops))
+		assert.Equal(t, []string{"dependencies.runtime"}, hops[0].Fields())
+	})
+
+	t.Run("all", func(t *testing.T) {
+		paths, err := g.AllPaths(app, libfoo)
+		require.NoError(t, err)
+		require.Len(t, paths, 2)
+		assert.Equal(t, []hop{
+			{"app", "libfoo-dev", []EdgeType{EdgeBuildtime}, "libfoo-dev", ""},
+			{"libfoo-dev", "libfoo", []EdgeType{EdgeSubpackage}, "", ""},
+		}, simplify(paths[1]))
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		_, err := g.ShortestPath(libfoo, app)
+		assert.True(t, errors.Is(err, graph.ErrTargetNotReachable))
+
+		_, err = g.ShortestPath(libfooDev, "missing:1-r0@local")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,188 @@
+package dag
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Weights maps package names to their relative cost of building, used to
+// balance shards.
+type Weights map[string]float64
+
+// ReadWeights reads package weights from r. Each line holds a package name and
+// a non-negative number separated by whitespace, e.g. "gcc 40". Blank lines and
+// lines starting with # are ignored.
+func ReadWeights(r io.Reader) (Weights, error) {
+	weights := Weights{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<package> <weight>\", got %q", line, text)
+		}
+		w, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("line %d: invalid weight %q", line, fields[1])
+		}
+		weights[fields[0]] = w
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return weights, nil
+}
+
+// Shards splits the graph into n shards of roughly equal weight, each in build
+// order. Packages without a weight in weights count as 1, so a nil weights
+// balances shards by package count.
+//
+// Packages connected by dependencies are kept in the same shard, so that in the
+// common case shards are independent and can be built concurrently. A group of
+// connected packages heavier than an even share of the total is split across
+// consecutive shards instead, filling the shards that have room left first.
+// Either way, a package only ever depends on packages in the same or an
+// earlier shard, so building the shards in order is always correct.
+//
+// Shards is normally called on the result of Targets, so that subpackages are
+// flattened into their origins.
+func (g Graph) Shards(n int, weights Weights) ([][]Package, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of shards must be at least 1, got %d", n)
+	}
+
+	amap, err := g.Graph.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	// Build order, dependencies first.
+	ordered, err := g.ReverseSorted()
+	if err != nil {
+		return nil, err
+	}
+
+	weightOf := func(p Package) float64 {
+		if w, ok := weights[p.Name()]; ok {
+			return w
+		}
+		return 1
+	}
+
+	// Group the packages into connected components, ignoring edge direction.
+	parent := make(map[string]string, len(amap))
+	var find func(string) string
+	find = func(node string) string {
+		if parent[node] == "" || parent[node] == node {
+			return node
+		}
+		root := find(parent[node])
+		parent[node] = root
+		return root
+	}
+	for node, deps := range amap {
+		for dep := range deps {
+			a, b := find(node), find(dep)
+			if a != b {
+				// Keep the smallest hash as the root, for determinism.
+				if a > b {
+					a, b = b, a
+				}
+				parent[b] = a
+			}
+		}
+	}
+
+	type component struct {
+		root     string
+		packages []Package
+		weight   float64
+	}
+	byRoot := map[string]*component{}
+	var components []*component
+	var total float64
+	for _, p := range ordered {
+		root := find(PackageHash(p))
+		c, ok := byRoot[root]
+		if !ok {
+			c = &component{root: root}
+			byRoot[root] = c
+			components = append(components, c)
+		}
+		c.packages = append(c.packages, p)
+		c.weight += weightOf(p)
+		total += weightOf(p)
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		if components[i].weight != components[j].weight {
+			return components[i].weight > components[j].weight
+		}
+		return components[i].root < components[j].root
+	})
+
+	capacity := total / float64(n)
+	shards := make([][]Package, n)
+	load := make([]float64, n)
+	for _, c := range components {
+		if c.weight <= capacity || n == 1 {
+			// Give the whole component to the lightest shard, the earliest on ties.
+			lightest := 0
+			for i := range load {
+				if load[i] < load[lightest] {
+					lightest = i
+				}
+			}
+			shards[lightest] = append(shards[lightest], c.packages...)
+			load[lightest] += c.weight
+			continue
+		}
+
+		// Too heavy for one shard: cut the component in build order into
+		// consecutive shards, so dependencies always land in an earlier shard.
+		// Each package goes to the first of the remaining shards it still fits
+		// in, or the lightest of them if it fits in none, so that shards
+		// already loaded by earlier components are only topped up.
+		shard := 0
+		for _, p := range c.packages {
+			w := weightOf(p)
+			next := -1
+			for i := shard; i < n; i++ {
+				if load[i]+w <= capacity {
+					next = i
+					break
+				}
+				if next == -1 || load[i] < load[next] {
+					next = i
+				}
+			}
+			shard = next
+			shards[shard] = append(shards[shard], p)
+			load[shard] += w
+		}
+	}
+
+	// Keep each shard in build order.
+	position := make(map[string]int, len(ordered))
+	for i, p := range ordered {
+		position[PackageHash(p)] = i
+	}
+	for _, shard := range shards {
+		sort.SliceStable(shard, func(i, j int) bool {
+			return position[PackageHash(shard[i])] < position[PackageHash(shard[j])]
+		})
+	}
+
+	return shards, nil
+}
@@ -0,0 +1,106 @@
+package dag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWeights(t *testing.T) {
+	weights, err := ReadWeights(strings.NewReader("# relative cost\ngcc 40\nzlib 0.5\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Weights{"gcc": 40, "zlib": 0.5}, weights)
+
+	_, err = ReadWeights(strings.NewReader("gcc -1\n"))
+	assert.Error(t, err)
+}
+
+func TestShards(t *testing.T) {
+	g := &Graph{Graph: newGraph(), byName: map[string][]string{}}
+	hash := func(name string) string {
+		return PackageHash(externalPackage{name, "1", "test"})
+	}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		require.NoError(t, g.addVertex(externalPackage{name, "1", "test"}))
+	}
+	// Components: {a, b, c} where a depends on b, which depends on c; {d, e}
+	// where d depends on e; and {f}.
+	for _, e := range [][2]string{{"a", "b"}, {"b", "c"}, {"d", "e"}} {
+		require.NoError(t, g.Graph.AddEdge(hash(e[0]), hash(e[1])))
+	}
+
+	names := func(shards [][]Package) [][]string {
+		out := [][]string{}
+		for _, shard := range shards {
+			names := []string{}
+			for _, p := range shard {
+				names = append(names, p.Name())
+			}
+			out = append(out, names)
+		}
+		return out
+	}
+
+	t.Run("by count", func(t *testing.T) {
+		shards, err := g.Shards(2, nil)
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"c", "b", "a"}, {"e", "d", "f"}}, names(shards))
+	})
+
+	t.Run("by weight", func(t *testing.T) {
+		shards, err := g.Shards(2, Weights{"f": 4})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"f"}, {"c", "b", "e", "a", "d"}}, names(shards))
+	})
+
+	t.Run("split component", func(t *testing.T) {
+		// With a, b and c weighing 2 each, their chain is heavier than a shard
+		// of weight 3, so it's cut in build order across consecutive shards.
+		shards, err := g.Shards(3, Weights{"a": 2, "b": 2, "c": 2})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"c", "e", "d"}, {"b", "f"}, {"a"}}, names(shards))
+	})
+
+	t.Run("one shard", func(t *testing.T) {
+		shards, err := g.Shards(1, nil)
+		require.NoError(t, err)
+		assert.Len(t, shards[0], 6)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := g.Shards(0, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestShardsPreloaded(t *testing.T) {
+	g := &Graph{Graph: newGraph(), byName: map[string][]string{}}
+	hash := func(name string) string {
+		return PackageHash(externalPackage{name, "1", "test"})
+	}
+	// Two chains, x1 -> ... -> x5 and y1 -> ... -> y4, both heavier than a
+	// third of the total.
+	for _, name := range []string{"x1", "x2", "x3", "x4", "x5", "y1", "y2", "y3", "y4"} {
+		require.NoError(t, g.addVertex(externalPackage{name, "1", "test"}))
+	}
+	for _, e := range [][2]string{{"x1", "x2"}, {"x2", "x3"}, {"x3", "x4"}, {"x4", "x5"}, {"y1", "y2"}, {"y2", "y3"}, {"y3", "y4"}} {
+		require.NoError(t, g.Graph.AddEdge(hash(e[0]), hash(e[1])))
+	}
+
+	shards, err := g.Shards(3, nil)
+	require.NoError(t, err)
+	var got [][]string
+	for _, shard := range shards {
+		var names []string
+		for _, p := range shard {
+			names = append(names, p.Name())
+		}
+		got = append(got, names)
+	}
+	// The x chain fills the first shard and part of the second, so the y
+	// chain is cut starting from the second shard instead of overloading the
+	// first one.
+	assert.Equal(t, [][]string{{"x5", "x4", "x3"}, {"y4", "x2", "x1"}, {"y3", "y2", "y1"}}, got)
+}
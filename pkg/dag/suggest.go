@@ -0,0 +1,82 @@
+package dag
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"chainguard.dev/apko/pkg/apk/apk"
+	"github.com/texttheater/golang-levenshtein/levenshtein"
+)
+
+// NameSuggestion is a local package, subpackage or provides entry whose name is
+// close to that of a dependency, which the dependency might have meant.
+type NameSuggestion struct {
+	Name string
+
+	// Provider is the package or subpackage providing Name, if Name is one of
+	// its provides entries rather than its own name.
+	Provider string
+
+	// Stream reports whether Name only differs from the dependency in version
+	// numbers, such as py3.12-foo for py3-foo or php-8.3-foo for php-8.2-foo.
+	Stream bool
+
+	// Distance is the edit distance between Name and the dependency.
+	Distance int
+}
+
+// Suggest returns up to limit names of local packages, subpackages and
+// provides entries that dep could have meant, best first: those only differing
+// from it in version numbers, then those closest to it by edit distance. Any
+// version constraint of dep is ignored.
+func (p *Packages) Suggest(dep string, limit int) []NameSuggestion {
+	name := apk.ResolvePackageNameVersionPin(dep).Name
+	stream := streamName(name)
+	// Allow about one typo every four characters.
+	maxDistance := max(1, len(name)/4)
+
+	var out []NameSuggestion
+	for candidate, configs := range p.configs {
+		s := NameSuggestion{
+			Name:     candidate,
+			Stream:   streamName(candidate) == stream,
+			Distance: levenshtein.DistanceForStrings([]rune(name), []rune(candidate), levenshtein.DefaultOptions),
+		}
+		if !s.Stream && s.Distance > maxDistance {
+			continue
+		}
+		for _, c := range configs {
+			if c.pkg != candidate {
+				s.Provider = c.pkg
+				break
+			}
+		}
+		out = append(out, s)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Stream != out[j].Stream {
+			return out[i].Stream
+		}
+		if out[i].Distance != out[j].Distance {
+			return out[i].Distance < out[j].Distance
+		}
+		return out[i].Name < out[j].Name
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// streamName returns name without its version numbers, which is the same for
+// the packages of every version stream of a project.
+func streamName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || r == '.' {
+			return -1
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,52 @@
+package dag
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDangling(t *testing.T) {
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"py3.12-foo.yaml": testConfig{name: "py3.12-foo"}.file(),
+		"openssl.yaml":    testConfig{name: "openssl", provides: []string{"libssl=3"}}.file(),
+		"app.yaml":        testConfig{name: "app", deps: []string{"py3-foo", "opensl", "libsssl>=3"}}.file(),
+		"tool.yaml":       testConfig{name: "tool", deps: []string{"py3-foo", "nothing-like-it"}}.file(),
+	}
+	pkgs, err := NewPackages(ctx, fsys, ".", nil)
+	require.NoError(t, err)
+	g, err := NewGraph(ctx, pkgs, WithAllowUnresolved())
+	require.NoError(t, err)
+
+	dangling, err := g.Dangling(3)
+	require.NoError(t, err)
+
+	got := map[string][]NameSuggestion{}
+	var deps []string
+	for _, d := range dangling {
+		deps = append(deps, d.Dependency)
+		got[d.Dependency] = d.Suggestions
+	}
+	assert.Equal(t, []string{"libsssl>=3", "nothing-like-it", "opensl", "py3-foo"}, deps)
+
+	assert.Equal(t, []NameSuggestion{{Name: "py3.12-foo", Stream: true, Distance: 3}}, got["py3-foo"])
+	assert.Equal(t, []NameSuggestion{{Name: "openssl", Distance: 1}}, got["opensl"])
+	assert.Equal(t, []NameSuggestion{{Name: "libssl", Provider: "openssl", Distance: 1}}, got["libsssl>=3"])
+	assert.Empty(t, got["nothing-like-it"])
+
+	for _, d := range dangling {
+		if d.Dependency != "py3-foo" {
+			continue
+		}
+		var names []string
+		for _, u := range d.Dependents {
+			names = append(names, u.Package.Name())
+		}
+		assert.Equal(t, []string{"app", "tool"}, names)
+	}
+}
@@ -0,0 +1,111 @@
+package dag
+
+import (
+	"sort"
+)
+
+// Unresolved is a dependency that could not be resolved to any package. These
+// are only part of a graph built WithAllowUnresolved.
+type Unresolved struct {
+	// Package is the package declaring the dependency.
+	Package    Package
+	Dependency string
+	Types      []EdgeType
+}
+
+// Fields returns the fields of the melange configuration of Package that
+// declared the dependency.
+func (u Unresolved) Fields() []string {
+	fields := make([]string, 0, len(u.Types))
+	for _, t := range u.Types {
+		fields = append(fields, t.Field())
+	}
+	return fields
+}
+
+// Unresolved returns the dependencies in the graph that could not be resolved,
+// sorted by the name of the package declaring them and then by dependency.
+func (g Graph) Unresolved() ([]Unresolved, error) {
+	edges, err := g.Graph.Edges()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Unresolved
+	for _, e := range edges {
+		target, err := g.Graph.Vertex(e.Target)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := target.(danglingPackage); !ok {
+			continue
+		}
+		source, err := g.Graph.Vertex(e.Source)
+		if err != nil {
+			return nil, err
+		}
+		declared := declaredDependencies(e.Properties.Attributes)
+		if len(declared) == 0 {
+			// Edges flattened by Targets only keep their types.
+			out = append(out, Unresolved{
+				Package:    source,
+				Dependency: target.Name(),
+				Types:      edgeTypes(e.Properties.Attributes),
+			})
+		}
+		for _, d := range declared {
+			out = append(out, Unresolved{
+				Package:    source,
+				Dependency: d.dependency,
+				Types:      d.types,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Package.Name() != out[j].Package.Name() {
+			return out[i].Package.Name() < out[j].Package.Name()
+		}
+		return out[i].Dependency < out[j].Dependency
+	})
+	return out, nil
+}
+
+// Dangling is a dependency that could not be resolved, with every package
+// declaring it.
+type Dangling struct {
+	Dependency string
+	Dependents []Unresolved
+
+	// Suggestions are the local packages, subpackages and provides entries the
+	// dependency might have meant, best first.
+	Suggestions []NameSuggestion
+}
+
+// Dangling returns the dependencies in the graph that could not be resolved,
+// sorted by dependency, each with up to limit suggestions of what it might
+// have meant.
+func (g Graph) Dangling(limit int) ([]Dangling, error) {
+	unresolved, err := g.Unresolved()
+	if err != nil {
+		return nil, err
+	}
+
+	byDep := map[string]*Dangling{}
+	for _, u := range unresolved {
+		d, ok := byDep[u.Dependency]
+		if !ok {
+			d = &Dangling{
+				Dependency:  u.Dependency,
+				Suggestions: g.packages.Suggest(u.Dependency, limit),
+			}
+			byDep[u.Dependency] = d
+		}
+		d.Dependents = append(d.Dependents, u)
+	}
+
+	out := make([]Dangling, 0, len(byDep))
+	for _, dep := range sortedKeys(byDep) {
+		out = append(out, *byDep[dep])
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package dag
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Waves partitions the graph into build waves, such that every package's
+// dependencies are all in earlier waves. All packages in the same wave can be
+// built concurrently.
+//
+// If maxParallel is greater than zero, no wave has more than maxParallel
+// packages. Packages that don't fit are deferred to later waves, preferring to
+// build first the packages with the longest chain of dependents, so that the
+// total number of waves stays as small as possible.
+//
+// Waves is normally called on the result of Targets, so that subpackages are
+// flattened into their origins.
+func (g Graph) Waves(maxParallel int) ([][]Package, error) {
+	amap, err := g.Graph.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	pmap, err := g.Graph.PredecessorMap()
+	if err != nil {
+		return nil, err
+	}
+
+	// height is the length of the longest chain of dependents above a node.
+	height := make(map[string]int, len(pmap))
+	var heightOf func(node string) int
+	heightOf = func(node string) int {
+		if h, ok := height[node]; ok {
+			return h
+		}
+		h := 0
+		for dependent := range pmap[node] {
+			if dh := heightOf(dependent) + 1; dh > h {
+				h = dh
+			}
+		}
+		height[node] = h
+		return h
+	}
+
+	remaining := make(map[string]int, len(amap))
+	var ready []string
+	for node, deps := range amap {
+		remaining[node] = len(deps)
+		if len(deps) == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	var (
+		waves [][]Package
+		done  int
+	)
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			hi, hj := heightOf(ready[i]), heightOf(ready[j])
+			if hi != hj {
+				return hi > hj
+			}
+			return ready[i] < ready[j]
+		})
+
+		n := len(ready)
+		if maxParallel > 0 && n > maxParallel {
+			n = maxParallel
+		}
+		current, deferred := ready[:n], ready[n:]
+		sort.Strings(current)
+
+		wave := make([]Package, 0, len(current))
+		var next []string
+		for _, node := range current {
+			pkg, err := g.Graph.Vertex(node)
+			if err != nil {
+				return nil, err
+			}
+			wave = append(wave, pkg)
+			done++
+
+			for dependent := range pmap[node] {
+				remaining[dependent]--
+				if remaining[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		waves = append(waves, wave)
+		ready = append(append([]string{}, deferred...), next...)
+	}
+
+	if done != len(amap) {
+		return nil, fmt.Errorf("unable to place %d of %d packages in a wave, the graph has a cycle", len(amap)-done, len(amap))
+	}
+
+	return waves, nil
+}
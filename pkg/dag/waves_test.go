@@ -0,0 +1,45 @@
+package dag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaves(t *testing.T) {
+	g := &Graph{Graph: newGraph(), byName: map[string][]string{}}
+	hash := func(name string) string {
+		return PackageHash(externalPackage{name, "1", "test"})
+	}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		require.NoError(t, g.addVertex(externalPackage{name, "1", "test"}))
+	}
+	for _, e := range [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}, {"f", "e"}} {
+		require.NoError(t, g.Graph.AddEdge(hash(e[0]), hash(e[1])))
+	}
+
+	names := func(waves [][]Package) [][]string {
+		var out [][]string
+		for _, wave := range waves {
+			var names []string
+			for _, p := range wave {
+				names = append(names, p.Name())
+			}
+			out = append(out, names)
+		}
+		return out
+	}
+
+	t.Run("unlimited", func(t *testing.T) {
+		waves, err := g.Waves(0)
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"d", "e"}, {"b", "c", "f"}, {"a"}}, names(waves))
+	})
+
+	t.Run("max parallel", func(t *testing.T) {
+		waves, err := g.Waves(2)
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"d", "e"}, {"b", "c"}, {"a", "f"}}, names(waves))
+	})
+}
@@ -0,0 +1,59 @@
+package git
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeFS(t *testing.T) {
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	commit := func(files map[string]string) {
+		for name, content := range files {
+			require.NoError(t, util.WriteFile(w.Filesystem, name, []byte(content), 0o644))
+			_, err := w.Add(name)
+			require.NoError(t, err)
+		}
+		_, err := w.Commit("update", &git.CommitOptions{
+			Author: &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()},
+		})
+		require.NoError(t, err)
+	}
+	commit(map[string]string{"foo.yaml": "one", "pipelines/test/run.yaml": "pipeline"})
+	commit(map[string]string{"foo.yaml": "two"})
+
+	tree, err := TreeAt(r, "HEAD~1")
+	require.NoError(t, err)
+	fsys := TreeFS(tree)
+
+	require.NoError(t, fstest.TestFS(fsys, "foo.yaml", "pipelines/test/run.yaml"))
+
+	b, err := fs.ReadFile(fsys, "foo.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(b))
+
+	tree, err = TreeAt(r, "HEAD")
+	require.NoError(t, err)
+	b, err = fs.ReadFile(TreeFS(tree), "foo.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "two", string(b))
+
+	_, err = TreeFS(tree).Open("missing.yaml")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = TreeAt(r, "no-such-branch")
+	assert.Error(t, err)
+}
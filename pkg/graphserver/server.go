@@ -0,0 +1,623 @@
+// Package graphserver serves read-only queries of the package dependency graph
+// over HTTP, as JSON.
+package graphserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainguard-dev/clog"
+	"github.com/dominikbraun/graph"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+	"golang.org/x/sync/errgroup"
+)
+
+// Loader builds the packages and their graph to serve. It's called once when
+// the server is created and again whenever the configs change.
+type Loader func(ctx context.Context) (*dag.Packages, *dag.Graph, error)
+
+// Options configure a Server.
+type Options struct {
+	// Load builds the packages and graph to serve.
+	Load Loader
+
+	// Dirs are the directories of the configs and pipelines the graph is built
+	// from. The graph is reloaded when a YAML file in one of them changes.
+	Dirs []string
+
+	// Interval is how often Dirs are checked for changes, one second by
+	// default.
+	Interval time.Duration
+}
+
+// Server answers queries about the graph of the packages returned by its
+// Loader, reloading it when the configs change.
+type Server struct {
+	load     Loader
+	dirs     []string
+	interval time.Duration
+
+	mu   sync.RWMutex
+	snap *snapshot
+}
+
+// snapshot is the graph as of one load.
+type snapshot struct {
+	pkgs    *dag.Packages
+	graph   *dag.Graph
+	targets *dag.Graph
+	loaded  time.Time
+
+	// fingerprint identifies the state of the configs the graph was loaded
+	// from.
+	fingerprint string
+}
+
+// New returns a Server with the graph loaded.
+func New(ctx context.Context, opts Options) (*Server, error) {
+	if opts.Load == nil {
+		return nil, errors.New("no loader given")
+	}
+	s := &Server{
+		load:     opts.Load,
+		dirs:     opts.Dirs,
+		interval: opts.Interval,
+	}
+	if s.interval <= 0 {
+		s.interval = time.Second
+	}
+	if err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload loads the graph again. Queries are answered from the previous graph
+// until the new one is loaded, and the previous graph is kept if loading fails.
+func (s *Server) Reload(ctx context.Context) error {
+	fp, err := fingerprint(s.dirs)
+	if err != nil {
+		return err
+	}
+	pkgs, g, err := s.load(ctx)
+	if err != nil {
+		return fmt.Errorf("loading graph: %w", err)
+	}
+	targets, err := g.Targets()
+	if err != nil {
+		return fmt.Errorf("targets: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snap = &snapshot{
+		pkgs:        pkgs,
+		graph:       g,
+		targets:     targets,
+		loaded:      time.Now(),
+		fingerprint: fp,
+	}
+	return nil
+}
+
+func (s *Server) snapshot() *snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snap
+}
+
+// Watch reloads the graph whenever a config changes, until ctx is done. A
+// failed reload is logged and retried on the next change.
+func (s *Server) Watch(ctx context.Context) error {
+	log := clog.FromContext(ctx)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	failed := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		fp, err := fingerprint(s.dirs)
+		if err != nil {
+			log.Warnf("checking for changes: %v", err)
+			continue
+		}
+		if fp == s.snapshot().fingerprint || fp == failed {
+			continue
+		}
+		log.Infof("configs changed, reloading graph")
+		if err := s.Reload(ctx); err != nil {
+			log.Errorf("reloading graph: %v", err)
+			failed = fp
+			continue
+		}
+		failed = ""
+		log.Infof("reloaded graph")
+	}
+}
+
+// fingerprint identifies the state of the YAML files in dirs by their paths,
+// sizes and modification times.
+func fingerprint(dirs []string) (string, error) {
+	h := sha256.New()
+	for _, dir := range dirs {
+		fmt.Fprintf(h, "\x00%s\n", dir)
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isYAML(path) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			fmt.Fprintln(h, "missing")
+		case err != nil:
+			return "", fmt.Errorf("checking %q for changes: %w", dir, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isYAML(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Handler returns the handler of the API:
+//
+//	GET /packages                          the local origin packages
+//	GET /deps/{package}                    the direct dependencies of a package
+//	GET /rdeps/{package}?depth=&type=      the packages depending on a package, in rebuild order
+//	GET /build-order?package=...           the given packages in the order to build them
+//	GET /path?from=&to=                    a shortest path of dependencies between two packages
+//	GET /unresolved                        the dependencies that could not be resolved
+//	GET /status                            when the graph was last loaded
+//
+// /rdeps follows buildtime dependencies, unless other types are given with
+// ?type=. Subpackages are flattened into their origins, unless
+// ?subpackages=true is given to /deps or /rdeps. Package names can be
+// subpackages or provides, which are looked up by the package providing them.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /packages", s.handlePackages)
+	mux.HandleFunc("GET /deps/{package}", s.handleDeps)
+	mux.HandleFunc("GET /rdeps/{package}", s.handleRdeps)
+	mux.HandleFunc("GET /build-order", s.handleBuildOrder)
+	mux.HandleFunc("GET /path", s.handlePath)
+	mux.HandleFunc("GET /unresolved", s.handleUnresolved)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	return mux
+}
+
+// httpError is an error with the status to answer with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+
+func (e *httpError) Unwrap() error { return e.err }
+
+func badRequest(format string, args ...any) error {
+	return &httpError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+func notFound(format string, args ...any) error {
+	return &httpError{status: http.StatusNotFound, err: fmt.Errorf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	var herr *httpError
+	if errors.As(err, &herr) {
+		status = herr.status
+	} else {
+		clog.FromContext(r.Context()).Errorf("%s: %v", r.URL, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorJSON{Error: err.Error()})
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+type packageJSON struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Path        string   `json:"path"`
+	Subpackages []string `json:"subpackages"`
+}
+
+type nodeJSON struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Source   string `json:"source"`
+	Resolved bool   `json:"resolved"`
+}
+
+func newNodeJSON(p dag.Package) nodeJSON {
+	return nodeJSON{
+		ID:       dag.PackageHash(p),
+		Name:     p.Name(),
+		Version:  p.Version(),
+		Source:   p.Source(),
+		Resolved: p.Resolved(),
+	}
+}
+
+type depJSON struct {
+	nodeJSON
+	Types []dag.EdgeType `json:"types"`
+}
+
+type hopJSON struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Types      []dag.EdgeType `json:"types"`
+	Dependency string         `json:"dependency,omitempty"`
+	Provides   string         `json:"provides,omitempty"`
+}
+
+type unresolvedJSON struct {
+	Package    string         `json:"package"`
+	Dependency string         `json:"dependency"`
+	Types      []dag.EdgeType `json:"types"`
+}
+
+type statusJSON struct {
+	Loaded   time.Time `json:"loaded"`
+	Packages int       `json:"packages"`
+}
+
+func (s *Server) handlePackages(w http.ResponseWriter, _ *http.Request) {
+	snap := s.snapshot()
+	out := []packageJSON{}
+	for _, c := range snap.pkgs.Packages() {
+		subpackages := make([]string, 0, len(c.Subpackages))
+		for i := range c.Subpackages {
+			subpackages = append(subpackages, c.Subpackages[i].Name)
+		}
+		out = append(out, packageJSON{
+			Name:        c.Name(),
+			Version:     c.Version(),
+			Path:        c.Path,
+			Subpackages: subpackages,
+		})
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleDeps(w http.ResponseWriter, r *http.Request) {
+	snap := s.snapshot()
+	g, err := snap.graphFor(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	nodes, err := snap.lookup(g, r.PathValue("package"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	out := []depJSON{}
+	seen := map[string]struct{}{}
+	for _, node := range nodes {
+		for _, dep := range g.DependenciesOf(node) {
+			if _, ok := seen[dep]; ok {
+				continue
+			}
+			seen[dep] = struct{}{}
+			p, err := g.Graph.Vertex(dep)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			types, err := g.EdgeTypes(node, dep)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if types == nil {
+				types = []dag.EdgeType{}
+			}
+			out = append(out, depJSON{nodeJSON: newNodeJSON(p), Types: types})
+		}
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleRdeps(w http.ResponseWriter, r *http.Request) {
+	snap := s.snapshot()
+	g, err := snap.graphFor(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	roots, err := snap.lookup(g, r.PathValue("package"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	depth := 0
+	if v := r.URL.Query().Get("depth"); v != "" {
+		if depth, err = strconv.Atoi(v); err != nil || depth < 0 {
+			writeError(w, r, badRequest("invalid depth %q", v))
+			return
+		}
+	}
+	// Like wolfictl rdeps, only buildtime dependencies are followed by default.
+	values := queryList(r, "type")
+	if len(values) == 0 {
+		values = []string{string(dag.EdgeBuildtime)}
+	}
+	types, err := dag.ParseEdgeTypes(values)
+	if err != nil {
+		writeError(w, r, badRequest("%w", err))
+		return
+	}
+
+	dependents, err := g.Dependents(roots, depth, types...)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	sorted, err := dependents.ReverseSorted()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	isRoot := map[string]struct{}{}
+	for _, root := range roots {
+		isRoot[root] = struct{}{}
+	}
+	out := []nodeJSON{}
+	for _, p := range sorted {
+		if _, ok := isRoot[dag.PackageHash(p)]; ok {
+			continue
+		}
+		out = append(out, newNodeJSON(p))
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleBuildOrder(w http.ResponseWriter, r *http.Request) {
+	snap := s.snapshot()
+	names := queryList(r, "package")
+	if len(names) == 0 {
+		writeError(w, r, badRequest("no packages given, use ?package="))
+		return
+	}
+	want := map[string]struct{}{}
+	for _, name := range names {
+		nodes, err := snap.lookup(snap.targets, name)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for _, node := range nodes {
+			want[node] = struct{}{}
+		}
+	}
+
+	// The build order of the whole graph is also one of the given packages,
+	// including through dependencies that aren't given.
+	sorted, err := snap.targets.ReverseSorted()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	out := []nodeJSON{}
+	for _, p := range sorted {
+		if _, ok := want[dag.PackageHash(p)]; ok {
+			out = append(out, newNodeJSON(p))
+		}
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handlePath(w http.ResponseWriter, r *http.Request) {
+	snap := s.snapshot()
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if from == "" || to == "" {
+		writeError(w, r, badRequest("both ?from= and ?to= are required"))
+		return
+	}
+	sources, err := snap.lookup(snap.graph, from)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	targets, err := snap.lookup(snap.graph, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var shortest []dag.Hop
+	for _, source := range sources {
+		for _, target := range targets {
+			hops, err := snap.graph.ShortestPath(source, target)
+			if errors.Is(err, graph.ErrTargetNotReachable) {
+				continue
+			}
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if shortest == nil || len(hops) < len(shortest) {
+				shortest = hops
+			}
+		}
+	}
+	if shortest == nil {
+		writeError(w, r, notFound("%s does not depend on %s", from, to))
+		return
+	}
+
+	out := make([]hopJSON, 0, len(shortest))
+	for _, h := range shortest {
+		types := h.Types
+		if types == nil {
+			types = []dag.EdgeType{}
+		}
+		out = append(out, hopJSON{
+			From:       dag.PackageHash(h.From),
+			To:         dag.PackageHash(h.To),
+			Types:      types,
+			Dependency: h.Dependency,
+			Provides:   h.Provides,
+		})
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleUnresolved(w http.ResponseWriter, r *http.Request) {
+	snap := s.snapshot()
+	found, err := snap.graph.Unresolved()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	out := make([]unresolvedJSON, 0, len(found))
+	for _, u := range found {
+		types := u.Types
+		if types == nil {
+			types = []dag.EdgeType{}
+		}
+		out = append(out, unresolvedJSON{
+			Package:    u.Package.Name(),
+			Dependency: u.Dependency,
+			Types:      types,
+		})
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	snap := s.snapshot()
+	writeJSON(w, statusJSON{
+		Loaded:   snap.loaded,
+		Packages: len(snap.pkgs.PackageNames()),
+	})
+}
+
+// graphFor returns the graph to answer r from: the graph of origin packages,
+// or the whole graph if ?subpackages=true is given.
+func (snap *snapshot) graphFor(r *http.Request) (*dag.Graph, error) {
+	v := r.URL.Query().Get("subpackages")
+	if v == "" {
+		return snap.targets, nil
+	}
+	sub, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, badRequest("invalid subpackages %q", v)
+	}
+	if sub {
+		return snap.graph, nil
+	}
+	return snap.targets, nil
+}
+
+// lookup returns the nodes of g for the package name, looking subpackages and
+// provides up by the origin package providing them if they aren't in g.
+func (snap *snapshot) lookup(g *dag.Graph, name string) ([]string, error) {
+	found, err := g.NodesByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		if cfgs := snap.pkgs.Config(name, false); len(cfgs) > 0 {
+			if found, err = g.NodesByName(cfgs[0].Package.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, notFound("could not find package %q", name)
+	}
+	nodes := make([]string, 0, len(found))
+	for _, p := range found {
+		nodes = append(nodes, dag.PackageHash(p))
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// queryList returns the values of the query parameter key, which can be given
+// several times or as a comma-separated list.
+func queryList(r *http.Request, key string) []string {
+	var out []string
+	for _, v := range r.URL.Query()[key] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// Serve serves the API on l, reloading the graph when the configs change,
+// until ctx is done.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	server := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 3 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		if err := server.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	g.Go(func() error {
+		return s.Watch(ctx)
+	})
+	g.Go(func() error {
+		<-ctx.Done()
+		server.Close()
+		return ctx.Err()
+	})
+	return g.Wait()
+}
@@ -0,0 +1,187 @@
+package graphserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wolfi-dev/wolfictl/pkg/dag"
+)
+
+// testServer serves the graph of a copy of the subpackages testdata, which is
+// returned so that tests can change it.
+func testServer(t *testing.T) (*Server, *httptest.Server, string) {
+	t.Helper()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	src := filepath.Join("..", "dag", "testdata", "subpackages")
+	entries, err := os.ReadDir(src)
+	require.NoError(t, err)
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(src, e.Name()))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, e.Name()), b, 0o644))
+	}
+
+	s, err := New(ctx, Options{
+		Load: func(ctx context.Context) (*dag.Packages, *dag.Graph, error) {
+			pkgs, err := dag.NewPackages(ctx, os.DirFS(dir), dir, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			g, err := dag.NewGraph(ctx, pkgs, dag.WithAllowUnresolved(), dag.WithRuntimeDeps())
+			if err != nil {
+				return nil, nil, err
+			}
+			return pkgs, g, nil
+		},
+		Dirs:     []string{dir},
+		Interval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts, dir
+}
+
+func get(t *testing.T, ts *httptest.Server, path string, v any) int {
+	t.Helper()
+	resp, err := http.Get(ts.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	return resp.StatusCode
+}
+
+func names(nodes []nodeJSON) []string {
+	out := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, n.Name)
+	}
+	return out
+}
+
+func TestServer(t *testing.T) {
+	_, ts, _ := testServer(t)
+
+	t.Run("packages", func(t *testing.T) {
+		var got []packageJSON
+		require.Equal(t, http.StatusOK, get(t, ts, "/packages", &got))
+		require.Len(t, got, 3)
+		assert.Equal(t, "one", got[0].Name)
+		assert.Equal(t, "1.2.3-r1", got[0].Version)
+		assert.Equal(t, []string{"one-dev"}, got[0].Subpackages)
+	})
+
+	t.Run("deps", func(t *testing.T) {
+		var got []depJSON
+		require.Equal(t, http.StatusOK, get(t, ts, "/deps/three", &got))
+		byName := map[string][]dag.EdgeType{}
+		for _, d := range got {
+			byName[d.Name] = d.Types
+		}
+		assert.Equal(t, []dag.EdgeType{dag.EdgeRuntime}, byName["one"])
+		assert.Equal(t, []dag.EdgeType{dag.EdgeBuildtime}, byName["two"])
+	})
+
+	t.Run("rdeps", func(t *testing.T) {
+		var got []nodeJSON
+		require.Equal(t, http.StatusOK, get(t, ts, "/rdeps/one-dev", &got))
+		assert.Equal(t, []string{"two", "three"}, names(got))
+
+		require.Equal(t, http.StatusOK, get(t, ts, "/rdeps/one?type=runtime", &got))
+		assert.Equal(t, []string{"three"}, names(got))
+
+		// Only buildtime dependencies are followed by default.
+		require.Equal(t, http.StatusOK, get(t, ts, "/rdeps/one?depth=1", &got))
+		assert.Equal(t, []string{"two"}, names(got))
+		require.Equal(t, http.StatusOK, get(t, ts, "/rdeps/one?depth=1&type=buildtime,runtime", &got))
+		assert.ElementsMatch(t, []string{"two", "three"}, names(got))
+
+		require.Equal(t, http.StatusOK, get(t, ts, "/rdeps/one?depth=1&type=buildtime", &got))
+		assert.Equal(t, []string{"two"}, names(got))
+	})
+
+	t.Run("build order", func(t *testing.T) {
+		var got []nodeJSON
+		require.Equal(t, http.StatusOK, get(t, ts, "/build-order?package=three,one", &got))
+		assert.Equal(t, []string{"one", "three"}, names(got))
+	})
+
+	t.Run("path", func(t *testing.T) {
+		var got []hopJSON
+		require.Equal(t, http.StatusOK, get(t, ts, "/path?from=two&to=one", &got))
+		require.Len(t, got, 2)
+		assert.Equal(t, "one-dev", got[0].Dependency)
+		assert.Equal(t, []dag.EdgeType{dag.EdgeSubpackage}, got[1].Types)
+
+		var e errorJSON
+		assert.Equal(t, http.StatusNotFound, get(t, ts, "/path?from=one&to=three", &e))
+		assert.Equal(t, http.StatusBadRequest, get(t, ts, "/path?from=one", &e))
+	})
+
+	t.Run("unresolved", func(t *testing.T) {
+		var got []unresolvedJSON
+		require.Equal(t, http.StatusOK, get(t, ts, "/unresolved", &got))
+		deps := map[string]struct{}{}
+		for _, u := range got {
+			deps[u.Dependency] = struct{}{}
+		}
+		assert.Equal(t, map[string]struct{}{"busybox": {}, "wolfi-baselayout": {}}, deps)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var e errorJSON
+		assert.Equal(t, http.StatusNotFound, get(t, ts, "/deps/nope", &e))
+		assert.Contains(t, e.Error, `could not find package "nope"`)
+		assert.Equal(t, http.StatusBadRequest, get(t, ts, "/rdeps/one?type=bogus", &e))
+		assert.Equal(t, http.StatusBadRequest, get(t, ts, "/build-order", &e))
+	})
+}
+
+func TestServerReload(t *testing.T) {
+	s, ts, dir := testServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Watch(ctx) }()
+
+	four := []byte(`package:
+  name: four
+  version: "1.0.0"
+  epoch: 0
+environment:
+  contents:
+    packages:
+      - three
+pipeline:
+  - runs: echo four
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "four.yaml"), four, 0o644))
+
+	assert.Eventually(t, func() bool {
+		var got []nodeJSON
+		get(t, ts, "/rdeps/three", &got)
+		return len(got) == 1 && got[0].Name == "four"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// A broken config keeps the previous graph.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "five.yaml"), []byte("package: ["), 0o644))
+	time.Sleep(100 * time.Millisecond)
+	var got []packageJSON
+	require.Equal(t, http.StatusOK, get(t, ts, "/packages", &got))
+	assert.Len(t, got, 4)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
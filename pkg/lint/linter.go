@@ -2,8 +2,8 @@ package lint
 
 import (
 	"context"
-	"fmt"
 	"sort"
+	"time"
 
 	"golang.org/x/exp/slices"
 
@@ -52,7 +52,13 @@ func (l *Linter) Lint(ctx context.Context, minSeverity Severity) (Result, error)
 
 	sort.Strings(sortedNames)
 
+	now := time.Now()
+
 	for _, name := range sortedNames {
+		pkg := namesToPkg[name]
+		nolint := newNoLintState(pkg, now)
+		evaluated := map[string]bool{}
+
 		failedRules := make(EvalRuleErrors, 0)
 		for _, rule := range rules {
 			// Check if we should skip this rule.
@@ -78,24 +84,36 @@ func (l *Linter) Lint(ctx context.Context, minSeverity Severity) (Result, error)
 				continue
 			}
 
-			if slices.Contains(namesToPkg[name].NoLint, rule.Name) {
+			// Without unused reporting there's no need to evaluate rules that
+			// are suppressed for the whole file.
+			if !l.options.ReportUnusedNoLint && nolint.fileSuppressed(rule.Name) {
 				log.Debugf("%s: skipping rule %s because file contains #nolint:%s\n", name, rule.Name, rule.Name)
 				continue
 			}
 
 			// Evaluate the rule.
-			if err := rule.LintFunc(namesToPkg[name].Config); err != nil {
+			evaluated[rule.Name] = true
+			if err := rule.LintFunc(pkg.Config); err != nil {
+				if nolint.suppress(ctx, rule) {
+					log.Debugf("%s: suppressing rule %s because of a #nolint:%s directive\n", name, rule.Name, rule.Name)
+					continue
+				}
+
 				// Only add to failedRules if the severity is inclusive of the minSeverity
 				if rule.Severity.Value <= minSeverity.Value {
-					msg := fmt.Sprintf("[%s]: %s (%s)", rule.Name, err.Error(), rule.Severity.Name)
-
-					failedRules = append(failedRules, EvalRuleError{
-						Rule:  rule,
-						Error: fmt.Errorf("%s", msg),
-					})
+					failedRules = append(failedRules, newEvalRuleError(rule, err))
 				}
 			}
 		}
+
+		// Report problems with the #nolint directives themselves.
+		for _, e := range nolint.errors(rules, evaluated, l.options.ReportUnusedNoLint) {
+			if slices.Contains(l.options.SkipRules, e.Rule.Name) || e.Rule.Severity.Value > minSeverity.Value {
+				continue
+			}
+			failedRules = append(failedRules, e)
+		}
+
 		// If we have errors we append them to the result.
 		if failedRules.WrapErrors() != nil {
 			results = append(results, EvalResult{
@@ -126,7 +144,7 @@ func (l *Linter) Print(ctx context.Context, result Result) {
 func (l *Linter) PrintRules(ctx context.Context) {
 	log := clog.FromContext(ctx)
 	log.Info("Available rules:")
-	for _, rule := range AllRules(l) {
+	for _, rule := range append(AllRules(l), noLintRules()...) {
 		log.Infof("* %s: %s\n", rule.Name, cases.Title(language.Und).String(rule.Description))
 	}
 }
@@ -103,3 +103,36 @@ func TestLinter_Dir(t *testing.T) {
 		})
 	}
 }
+
+func TestLinter_ReportUnusedNoLint(t *testing.T) {
+	ctx := context.Background()
+
+	l := newTestLinterWithFile("nolint-unused.yaml")
+	got, err := l.Lint(ctx, SeverityWarning)
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no findings without --report-unused-nolint, got: %+v", got)
+	}
+
+	l = New(WithPath(filepath.Join("testdata/files/", "nolint-unused.yaml")), WithReportUnusedNoLint(true))
+	got, err = l.Lint(ctx, SeverityWarning)
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	want := Result{
+		{
+			File: "nolint-unused",
+			Errors: EvalRuleErrors{
+				{
+					Rule:  noLintUnusedRule,
+					Error: fmt.Errorf("[nolint-unused]: #nolint:no-repeated-deps on line 1 does not suppress anything (WARNING)"),
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(got, want, EquateErrorsByString(), cmpopts.IgnoreFields(Rule{}, "LintFunc")); diff != "" {
+		t.Errorf("unexpected diff: %s\ngot: %+v", diff, got)
+	}
+}
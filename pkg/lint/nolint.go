@@ -0,0 +1,280 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing/fstest"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"chainguard.dev/melange/pkg/config"
+	"gopkg.in/yaml.v3"
+
+	"github.com/wolfi-dev/wolfictl/pkg/melange"
+)
+
+var (
+	noLintInvalidRule = Rule{
+		Name:        "nolint-invalid",
+		Description: "#nolint directives should be well-formed",
+		Severity:    SeverityError,
+	}
+	noLintReasonRule = Rule{
+		Name:        "nolint-reason",
+		Description: "every #nolint directive should explain why the rules are suppressed",
+		Severity:    SeverityError,
+	}
+	noLintExpiredRule = Rule{
+		Name:        "nolint-expired",
+		Description: "#nolint directives should not be kept past their expiry date",
+		Severity:    SeverityError,
+	}
+	noLintUnusedRule = Rule{
+		Name:        "nolint-unused",
+		Description: "#nolint directives should suppress at least one finding",
+		Severity:    SeverityWarning,
+	}
+)
+
+// noLintRules are the rules that check #nolint directives themselves. They are
+// evaluated by the linter rather than against the configuration.
+func noLintRules() Rules {
+	return Rules{noLintInvalidRule, noLintReasonRule, noLintExpiredRule, noLintUnusedRule}
+}
+
+// noLintState tracks the #nolint directives of a single configuration while
+// its rules are evaluated.
+type noLintState struct {
+	pkg        *melange.Packages
+	directives []melange.NoLint
+	now        time.Time
+
+	// used records, per directive, which of its rules suppressed a finding.
+	used []map[string]bool
+}
+
+func newNoLintState(pkg *melange.Packages, now time.Time) *noLintState {
+	s := &noLintState{
+		pkg:        pkg,
+		directives: pkg.NoLint,
+		now:        now,
+		used:       make([]map[string]bool, len(pkg.NoLint)),
+	}
+	for i := range s.used {
+		s.used[i] = map[string]bool{}
+	}
+	return s
+}
+
+// fileSuppressed returns true if the rule is suppressed for the whole file by
+// a directive that has not expired.
+func (s *noLintState) fileSuppressed(rule string) bool {
+	for _, d := range s.directives {
+		if d.FileScoped() && d.Suppresses(rule) && !d.Expired(s.now) {
+			return true
+		}
+	}
+	return false
+}
+
+// suppress reports whether a finding of the given rule is covered by one of
+// the directives, and marks the directive covering it as used.
+//
+// A file-wide directive covers any finding. A line directive covers a finding
+// only if the finding goes away once the YAML node on the targeted line (for
+// example a pipeline step or a list entry) is removed from the configuration.
+func (s *noLintState) suppress(ctx context.Context, rule Rule) bool {
+	for i, d := range s.directives {
+		if !d.Suppresses(rule.Name) || d.Expired(s.now) || !d.FileScoped() {
+			continue
+		}
+		s.used[i][rule.Name] = true
+		return true
+	}
+
+	for i, d := range s.directives {
+		if !d.Suppresses(rule.Name) || d.Expired(s.now) || d.FileScoped() {
+			continue
+		}
+		cfg, err := configWithoutLine(ctx, filepath.Join(s.pkg.Dir, s.pkg.Filename), d.Target)
+		if err != nil {
+			continue
+		}
+		if rule.LintFunc(*cfg) == nil {
+			s.used[i][rule.Name] = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// errors returns the findings about the directives themselves. Unused
+// directives are only reported if reportUnused is set, and only for rules that
+// were either evaluated or don't exist at all.
+func (s *noLintState) errors(rules Rules, evaluated map[string]bool, reportUnused bool) EvalRuleErrors {
+	errs := make(EvalRuleErrors, 0)
+	for i, d := range s.directives {
+		if d.Err != nil {
+			errs = append(errs, newEvalRuleError(noLintInvalidRule, fmt.Errorf("invalid #nolint directive on line %d: %w", d.Line, d.Err)))
+			continue
+		}
+		names := strings.Join(d.Rules, ",")
+		if d.Reason == "" {
+			errs = append(errs, newEvalRuleError(noLintReasonRule, fmt.Errorf("#nolint:%s on line %d does not give a reason (add \"// <reason>\")", names, d.Line)))
+		}
+		if d.Expired(s.now) {
+			errs = append(errs, newEvalRuleError(noLintExpiredRule, fmt.Errorf("#nolint:%s on line %d expired on %s", names, d.Line, d.Until.Format(melange.NoLintDateLayout))))
+			continue
+		}
+		if !reportUnused {
+			continue
+		}
+		for _, r := range d.Rules {
+			if s.used[i][r] {
+				continue
+			}
+			if !evaluated[r] && slices.ContainsFunc(rules, func(rule Rule) bool { return rule.Name == r }) {
+				// The rule was skipped, so we can't tell whether the
+				// directive is still needed.
+				continue
+			}
+			errs = append(errs, newEvalRuleError(noLintUnusedRule, fmt.Errorf("#nolint:%s on line %d does not suppress anything", r, d.Line)))
+		}
+	}
+	return errs
+}
+
+func newEvalRuleError(rule Rule, err error) EvalRuleError {
+	return EvalRuleError{
+		Rule:  rule,
+		Error: fmt.Errorf("[%s]: %s (%s)", rule.Name, err.Error(), rule.Severity.Name),
+	}
+}
+
+// configWithoutLine parses the configuration at path as if the YAML node
+// starting on the given line had been removed from it.
+func configWithoutLine(ctx context.Context, path string, line int) (*config.Configuration, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(b, root); err != nil {
+		return nil, err
+	}
+	if !removeLine(root, line) {
+		return nil, fmt.Errorf("no node found on line %d", line)
+	}
+
+	out, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(path)
+	return config.ParseConfiguration(ctx, name, config.WithFS(fstest.MapFS{name: {Data: out}}))
+}
+
+// removeLine removes the outermost mapping entry or sequence item starting on
+// the given line. If no such node exists, the innermost one spanning the line
+// is removed instead, which covers lines inside block scalars.
+func removeLine(root *yaml.Node, line int) bool {
+	if removeStarting(root, line) {
+		return true
+	}
+	return removeInnermost(root, line)
+}
+
+// removeStarting walks the tree top-down and removes the first mapping entry or
+// sequence item starting on the given line.
+func removeStarting(n *yaml.Node, line int) bool {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if removeStarting(c, line) {
+				return true
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Line == line {
+				n.Content = append(n.Content[:i], n.Content[i+2:]...)
+				return true
+			}
+		}
+		for i := 1; i < len(n.Content); i += 2 {
+			if removeStarting(n.Content[i], line) {
+				return true
+			}
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			if c.Line == line {
+				n.Content = append(n.Content[:i], n.Content[i+1:]...)
+				return true
+			}
+		}
+		for _, c := range n.Content {
+			if removeStarting(c, line) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func removeInnermost(n *yaml.Node, line int) bool {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if removeInnermost(c, line) {
+				return true
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if line < k.Line || line > lastLine(v) {
+				continue
+			}
+			if removeInnermost(v, line) {
+				return true
+			}
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return true
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			if line < c.Line || line > lastLine(c) {
+				continue
+			}
+			if removeInnermost(c, line) {
+				return true
+			}
+			n.Content = append(n.Content[:i], n.Content[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// lastLine approximates the last line spanned by a node, since yaml.v3 only
+// records where nodes start.
+func lastLine(n *yaml.Node) int {
+	last := n.Line
+	if n.Kind == yaml.ScalarNode && (n.Style&(yaml.LiteralStyle|yaml.FoldedStyle)) != 0 {
+		last += strings.Count(strings.TrimRight(n.Value, "\n"), "\n") + 1
+	}
+	for _, c := range n.Content {
+		if l := lastLine(c); l > last {
+			last = l
+		}
+	}
+	return last
+}
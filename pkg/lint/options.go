@@ -7,6 +7,10 @@ type Options struct {
 
 	// Skip rules removes the given slice of rules to be checked
 	SkipRules []string
+
+	// ReportUnusedNoLint reports #nolint directives that don't suppress any
+	// findings.
+	ReportUnusedNoLint bool
 }
 
 // Option represents a linter option.
@@ -25,3 +29,10 @@ func WithSkipRules(skipRules []string) Option {
 		o.SkipRules = skipRules
 	}
 }
+
+// WithReportUnusedNoLint sets whether unused #nolint directives are reported.
+func WithReportUnusedNoLint(report bool) Option {
+	return func(o *Options) {
+		o.ReportUnusedNoLint = report
+	}
+}
@@ -347,6 +347,102 @@ func TestLinter_Rules(t *testing.T) {
 			wantErr: false,
 			matches: 1,
 		},
+		{
+			file:        "nolint-missing-reason.yaml",
+			minSeverity: SeverityWarning,
+			want: EvalResult{
+				File: "nolint-missing-reason",
+				Errors: EvalRuleErrors{
+					{
+						Rule: Rule{
+							Name:     "nolint-reason",
+							Severity: SeverityError,
+						},
+						Error: fmt.Errorf("[nolint-reason]: #nolint:no-repeated-deps on line 1 does not give a reason (add \"// <reason>\") (ERROR)"),
+					},
+				},
+			},
+			wantErr: false,
+			matches: 1,
+		},
+		{
+			file:        "nolint-expired.yaml",
+			minSeverity: SeverityWarning,
+			want: EvalResult{
+				File: "nolint-expired",
+				Errors: EvalRuleErrors{
+					{
+						Rule: Rule{
+							Name:     "no-repeated-deps",
+							Severity: SeverityError,
+						},
+						Error: fmt.Errorf("[no-repeated-deps]: package foo is duplicated in environment (ERROR)"),
+					},
+					{
+						Rule: Rule{
+							Name:     "nolint-expired",
+							Severity: SeverityError,
+						},
+						Error: fmt.Errorf("[nolint-expired]: #nolint:no-repeated-deps on line 1 expired on 2020-01-31 (ERROR)"),
+					},
+				},
+			},
+			wantErr: false,
+			matches: 1,
+		},
+		{
+			file:        "nolint-invalid.yaml",
+			minSeverity: SeverityWarning,
+			want: EvalResult{
+				File: "nolint-invalid",
+				Errors: EvalRuleErrors{
+					{
+						Rule: Rule{
+							Name:     "no-repeated-deps",
+							Severity: SeverityError,
+						},
+						Error: fmt.Errorf("[no-repeated-deps]: package foo is duplicated in environment (ERROR)"),
+					},
+					{
+						Rule: Rule{
+							Name:     "nolint-invalid",
+							Severity: SeverityError,
+						},
+						Error: fmt.Errorf("[nolint-invalid]: invalid #nolint directive on line 1: unknown option \"since=2020-01-31\" (ERROR)"),
+					},
+				},
+			},
+			wantErr: false,
+			matches: 1,
+		},
+		{
+			file:        "nolint-scoped.yaml",
+			minSeverity: SeverityWarning,
+			want: EvalResult{
+				File:   "nolint-scoped",
+				Errors: EvalRuleErrors{},
+			},
+			wantErr: false,
+			matches: 0,
+		},
+		{
+			file:        "nolint-scoped-elsewhere.yaml",
+			minSeverity: SeverityWarning,
+			want: EvalResult{
+				File: "nolint-scoped-elsewhere",
+				Errors: EvalRuleErrors{
+					{
+						Rule: Rule{
+							Name:     "background-process-without-redirect",
+							Severity: SeverityWarning,
+						},
+						Error: fmt.Errorf("[background-process-without-redirect]: background process missing output redirect: croc relay --ports=1234 & (WARNING)"),
+					},
+				},
+			},
+			wantErr: false,
+			matches: 1,
+		},
 		{
 			file:        "no-epoch.yaml",
 			minSeverity: SeverityWarning,
@@ -0,0 +1,137 @@
+package melange
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+
+	"chainguard.dev/melange/pkg/renovate"
+	"chainguard.dev/melange/pkg/renovate/bump"
+	"github.com/chainguard-dev/clog"
+	"github.com/chainguard-dev/yam/pkg/yam/formatted"
+)
+
+// BumpOptions configure a version bump by BumpVersion.
+type BumpOptions struct {
+	// Version is the version to bump the package to.
+	Version string
+
+	// ExpectedCommit is the commit the git-checkout of the new version's tag is
+	// expected to resolve to. Without it, expected commits are left alone.
+	ExpectedCommit string
+
+	// Sources are local copies of the files fetched by the package, which are
+	// hashed instead of downloading them. They're matched to fetch steps by
+	// the base name of the fetched URI.
+	Sources []string
+
+	// EncodeOptions format the bumped config, typically those of the yam
+	// config of the repository.
+	EncodeOptions formatted.EncodeOptions
+}
+
+// BumpVersion returns the melange config in configFile bumped to a new
+// version by melange's bump renovator: package.version is set to the new
+// version and the epoch is reset to 0, or incremented if the version doesn't
+// change. The expected-sha256 and expected-sha512 of fetch steps are
+// recomputed, and the expected-commit of git-checkout steps of the version's
+// tag is set to the expected commit.
+//
+// Unlike renovating the config with melange, the file itself isn't changed.
+func BumpVersion(ctx context.Context, configFile string, opts BumpOptions) ([]byte, error) {
+	if opts.Version == "" {
+		return nil, fmt.Errorf("no version to bump to")
+	}
+
+	rctx, err := renovate.New(renovate.WithConfig(configFile))
+	if err != nil {
+		return nil, err
+	}
+	rc := &renovate.RenovationContext{Context: rctx}
+	if err := rc.LoadConfig(ctx); err != nil {
+		return nil, err
+	}
+
+	// The bump renovator downloads fetched files with http.DefaultClient, so
+	// the local sources are served by its transport for the time of the bump.
+	t := newSourceTransport(ctx, opts.Sources, http.DefaultClient.Transport)
+	http.DefaultClient.Transport = t
+	err = bump.New(ctx,
+		bump.WithTargetVersion(opts.Version),
+		bump.WithExpectedCommit(opts.ExpectedCommit),
+	)(ctx, rc)
+	http.DefaultClient.Transport = t.next
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+	for _, s := range opts.Sources {
+		if !t.served[path.Base(s)] {
+			return nil, fmt.Errorf("%s: no fetch step downloads %s", configFile, path.Base(s))
+		}
+	}
+
+	if opts.EncodeOptions.Indent == 0 {
+		opts.EncodeOptions.Indent = 2
+	}
+	var buf bytes.Buffer
+	enc, err := formatted.NewEncoder(&buf).UseOptions(opts.EncodeOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(rc.Configuration.Root().Content[0]); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sourceTransport answers requests for the base name of a local source with
+// its content, and passes the other requests on to next.
+type sourceTransport struct {
+	ctx     context.Context
+	sources map[string]string
+	served  map[string]bool
+	next    http.RoundTripper
+}
+
+func newSourceTransport(ctx context.Context, sources []string, next http.RoundTripper) *sourceTransport {
+	t := &sourceTransport{
+		ctx:     ctx,
+		sources: make(map[string]string, len(sources)),
+		served:  make(map[string]bool, len(sources)),
+		next:    next,
+	}
+	for _, s := range sources {
+		t.sources[path.Base(s)] = s
+	}
+	return t
+}
+
+func (t *sourceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := path.Base(req.URL.Path)
+	src, ok := t.sources[name]
+	if !ok {
+		if t.next == nil {
+			return http.DefaultTransport.RoundTrip(req)
+		}
+		return t.next.RoundTrip(req)
+	}
+	clog.FromContext(t.ctx).Infof("hashing %s for %s", src, req.URL)
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	t.served[name] = true
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       f,
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,161 @@
+package melange
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chainguard-dev/yam/pkg/yam/formatted"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bumpTestConfig = `package:
+  name: hello
+  version: "1.0.0"
+  epoch: 3 # CVE-2024-1234
+  description: says hello
+
+var-transforms:
+  - from: ${{package.version}}
+    match: \.
+    replace: _
+    to: mangled-package-version
+
+pipeline:
+  - uses: fetch
+    with:
+      uri: URL/hello-${{package.version}}.tar.gz
+      expected-sha256: old
+      expected-sha512: old
+
+  - uses: git-checkout
+    with:
+      repository: https://github.com/example/hello
+      tag: v${{vars.mangled-package-version}}
+      expected-commit: 9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b
+
+  - uses: git-checkout
+    with:
+      repository: https://github.com/example/other
+      tag: v1.0.0
+      expected-commit: e1e2e3e4e5e6e7e8e9e0e1e2e3e4e5e6e7e8e9e0
+`
+
+func writeBumpTestConfig(t *testing.T, url string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hello.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(strings.ReplaceAll(bumpTestConfig, "URL", url)), 0o644))
+	return path
+}
+
+func hashes(b []byte) (string, string) {
+	h256, h512 := sha256.Sum256(b), sha512.Sum512(b)
+	return hex.EncodeToString(h256[:]), hex.EncodeToString(h512[:])
+}
+
+func TestBumpVersion(t *testing.T) {
+	ctx := context.Background()
+	tarball := []byte("hello 1.1.0")
+	sum256, sum512 := hashes(tarball)
+
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path != "/hello-1.1.0.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(tarball) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	t.Run("download", func(t *testing.T) {
+		path := writeBumpTestConfig(t, srv.URL)
+		before, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		got, err := BumpVersion(ctx, path, BumpOptions{
+			Version:        "1.1.0",
+			ExpectedCommit: "4c3b7e5f2a9d8c1b0e6f7a8b9c0d1e2f3a4b5c6d",
+			EncodeOptions:  formatted.EncodeOptions{GapExpressions: []string{".", ".pipeline"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/hello-1.1.0.tar.gz"}, requested)
+
+		want := strings.NewReplacer(
+			`version: "1.0.0"`, `version: "1.1.0"`,
+			"epoch: 3", "epoch: 0",
+			"expected-sha256: old", "expected-sha256: "+sum256,
+			"expected-sha512: old", "expected-sha512: "+sum512,
+			"9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b", "4c3b7e5f2a9d8c1b0e6f7a8b9c0d1e2f3a4b5c6d",
+		).Replace(string(before))
+		assert.Equal(t, want, string(got))
+
+		// The config itself is left alone.
+		after, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("local source", func(t *testing.T) {
+		path := writeBumpTestConfig(t, "https://example.invalid/releases")
+		src := filepath.Join(t.TempDir(), "hello-1.1.0.tar.gz")
+		require.NoError(t, os.WriteFile(src, tarball, 0o644))
+
+		got, err := BumpVersion(ctx, path, BumpOptions{
+			Version:        "1.1.0",
+			ExpectedCommit: "4c3b7e5f2a9d8c1b0e6f7a8b9c0d1e2f3a4b5c6d",
+			Sources:        []string{src},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, string(got), "expected-sha256: "+sum256)
+		assert.Contains(t, string(got), "expected-sha512: "+sum512)
+	})
+
+	t.Run("same version", func(t *testing.T) {
+		path := writeBumpTestConfig(t, srv.URL)
+		got, err := BumpVersion(ctx, path, BumpOptions{
+			Version:        "1.0.0",
+			ExpectedCommit: "4c3b7e5f2a9d8c1b0e6f7a8b9c0d1e2f3a4b5c6d",
+			Sources:        []string{writeSource(t, "hello-1.0.0.tar.gz")},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, string(got), "epoch: 4")
+	})
+
+	t.Run("no expected commit", func(t *testing.T) {
+		path := writeBumpTestConfig(t, srv.URL)
+		got, err := BumpVersion(ctx, path, BumpOptions{Version: "1.1.0"})
+		require.NoError(t, err)
+		assert.Contains(t, string(got), "expected-commit: 9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		path := writeBumpTestConfig(t, srv.URL)
+
+		_, err := BumpVersion(ctx, path, BumpOptions{Version: "1.2.0", ExpectedCommit: "4c3b7e5f2a9d8c1b0e6f7a8b9c0d1e2f3a4b5c6d"})
+		assert.ErrorContains(t, err, "404")
+
+		_, err = BumpVersion(ctx, path, BumpOptions{
+			Version:        "1.1.0",
+			ExpectedCommit: "4c3b7e5f2a9d8c1b0e6f7a8b9c0d1e2f3a4b5c6d",
+			Sources:        []string{writeSource(t, "unrelated.tar.gz")},
+		})
+		assert.ErrorContains(t, err, "no fetch step downloads unrelated.tar.gz")
+	})
+}
+
+func writeSource(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+	return path
+}
@@ -10,9 +10,6 @@ import (
 
 	"gopkg.in/yaml.v3"
 
-	"chainguard.dev/melange/pkg/renovate"
-	"chainguard.dev/melange/pkg/renovate/bump"
-
 	"chainguard.dev/melange/pkg/config"
 )
 
@@ -22,7 +19,7 @@ type Packages struct {
 	Config   config.Configuration
 	Filename string
 	Dir      string
-	NoLint   []string
+	NoLint   []NoLint
 	Hash     string
 }
 
@@ -76,20 +73,6 @@ func ReadPackageConfigs(ctx context.Context, packageNames []string, dir string)
 	return ReadAllPackagesFromRepo(ctx, dir)
 }
 
-func findNoLint(filename string) ([]string, error) {
-	b, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-	lines := strings.Split(string(b), "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "#nolint:") {
-			return strings.Split(strings.TrimPrefix(line, "#nolint:"), ","), nil
-		}
-	}
-	return nil, nil
-}
-
 func ReadAllPackagesFromRepo(ctx context.Context, dir string) (map[string]*Packages, error) {
 	p := make(map[string]*Packages)
 
@@ -165,17 +148,3 @@ func ReadAllPackagesFromRepo(ctx context.Context, dir string) (map[string]*Packa
 	}
 	return p, nil
 }
-
-func Bump(ctx context.Context, configFile, version, expectedCommit string) error {
-	rctx, err := renovate.New(renovate.WithConfig(configFile))
-	if err != nil {
-		return err
-	}
-
-	bumpRenovator := bump.New(ctx,
-		bump.WithTargetVersion(version),
-		bump.WithExpectedCommit(expectedCommit),
-	)
-
-	return rctx.Renovate(ctx, bumpRenovator)
-}
@@ -0,0 +1,199 @@
+package melange
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// NoLintDateLayout is the layout of the date given to a #nolint directive's
+// "until" option.
+const NoLintDateLayout = "2006-01-02"
+
+// reNoLint matches a #nolint directive in a YAML comment, which starts either
+// the line or after whitespace. A directive that starts at the beginning of a
+// line applies to the whole file, any other directive applies to a single
+// line.
+var reNoLint = regexp.MustCompile(`(^|\s)#\s*nolint:(.*)$`)
+
+// reBlockScalar matches a line whose value is a literal or folded block
+// scalar, capturing its indentation.
+var reBlockScalar = regexp.MustCompile(`^(\s*)(- +)?([^#]*:\s+|- +)?[|>][-+0-9]*\s*(#.*)?$`)
+
+// NoLint is a single #nolint directive found in a melange configuration.
+//
+// Directives have the form:
+//
+//	#nolint:rule-a,rule-b [until=YYYY-MM-DD] // reason
+//
+// A directive at the very start of a line suppresses the listed rules for the
+// whole file. An indented directive on a line of its own applies to the next
+// line, and a directive trailing other content applies to the line it is on.
+type NoLint struct {
+	// Rules are the names of the rules being suppressed.
+	Rules []string
+
+	// Reason explains why the rules are suppressed.
+	Reason string
+
+	// Until is the last day the directive is honoured. The zero value means
+	// the directive never expires.
+	Until time.Time
+
+	// Line is the line number the directive was found on.
+	Line int
+
+	// Target is the line number the directive applies to, or zero when the
+	// directive applies to the whole file.
+	Target int
+
+	// Err is the problem with a directive that can't be parsed. Invalid
+	// directives don't suppress anything, and are reported by the linter.
+	Err error
+}
+
+// FileScoped returns true if the directive applies to the whole file.
+func (n NoLint) FileScoped() bool {
+	return n.Target == 0
+}
+
+// Expired returns true if the directive is no longer honoured at the given
+// time.
+func (n NoLint) Expired(now time.Time) bool {
+	if n.Until.IsZero() {
+		return false
+	}
+	return now.After(n.Until.AddDate(0, 0, 1))
+}
+
+// Suppresses returns true if the directive is valid and lists the given rule.
+func (n NoLint) Suppresses(rule string) bool {
+	if n.Err != nil {
+		return false
+	}
+	for _, r := range n.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func findNoLint(filename string) ([]NoLint, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseNoLint(string(b)), nil
+}
+
+// parseNoLint returns the #nolint directives in the comments of content.
+// Directives that can't be parsed are returned with their Err set.
+func parseNoLint(content string) []NoLint {
+	var directives []NoLint
+	lines := strings.Split(content, "\n")
+	block := inBlockScalar(lines)
+	for i, line := range lines {
+		if block[i] {
+			continue
+		}
+		m := reNoLint.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		n, err := parseNoLintDirective(line[m[4]:m[5]])
+		n.Line = i + 1
+		if err != nil {
+			n.Err = err
+			directives = append(directives, n)
+			continue
+		}
+
+		switch {
+		case m[0] == 0:
+			// File-wide directive, nothing to target.
+		case strings.TrimSpace(line[:m[0]]) != "":
+			n.Target = n.Line
+		default:
+			n.Target = nextContentLine(lines, i+1)
+			if n.Target == 0 {
+				n.Err = fmt.Errorf("nothing follows the directive")
+			}
+		}
+
+		directives = append(directives, n)
+	}
+	return directives
+}
+
+// parseNoLintDirective parses what follows "#nolint:". Rules are separated by
+// commas, optionally followed by spaces, and options have the form key=value.
+func parseNoLintDirective(s string) (NoLint, error) {
+	n := NoLint{}
+
+	spec, reason, _ := strings.Cut(s, "//")
+	n.Reason = strings.TrimSpace(reason)
+
+	for _, f := range strings.Fields(spec) {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			for _, r := range strings.Split(f, ",") {
+				if r != "" {
+					n.Rules = append(n.Rules, r)
+				}
+			}
+			continue
+		}
+		if k != "until" {
+			return n, fmt.Errorf("unknown option %q", f)
+		}
+		until, err := time.Parse(NoLintDateLayout, v)
+		if err != nil {
+			return n, fmt.Errorf("parsing until date: %w", err)
+		}
+		n.Until = until
+	}
+	if len(n.Rules) == 0 {
+		return n, fmt.Errorf("no rules given")
+	}
+
+	return n, nil
+}
+
+// inBlockScalar returns, for each line, whether it is part of the content of
+// a literal or folded block scalar, where a # doesn't start a comment.
+func inBlockScalar(lines []string) []bool {
+	block := make([]bool, len(lines))
+	indent := -1
+	for i, line := range lines {
+		if indent >= 0 {
+			trimmed := strings.TrimLeft(line, " ")
+			if trimmed == "" || len(line)-len(trimmed) > indent {
+				block[i] = true
+				continue
+			}
+			indent = -1
+		}
+		if m := reBlockScalar.FindStringSubmatch(line); m != nil {
+			// The content is indented more than the key, or more than the
+			// dash of a sequence item.
+			indent = len(m[1])
+		}
+	}
+	return block
+}
+
+// nextContentLine returns the 1-based number of the first line at or after the
+// 0-based index start that is neither blank nor a comment.
+func nextContentLine(lines []string, start int) int {
+	for i := start; i < len(lines); i++ {
+		l := strings.TrimSpace(lines[i])
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		return i + 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package melange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMelange_parseNoLint(t *testing.T) {
+	content := `#nolint:foo,bar // file-wide reason
+package:
+  name: foo
+environment:
+  contents:
+    packages:
+      - baz # nolint:baz until=2025-06-30 // trailing reason
+pipeline:
+  # nolint:qux // applies to the step below
+
+  - runs: echo hello
+`
+	got := parseNoLint(content)
+
+	want := []NoLint{
+		{Rules: []string{"foo", "bar"}, Reason: "file-wide reason", Line: 1},
+		{Rules: []string{"baz"}, Reason: "trailing reason", Until: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC), Line: 7, Target: 7},
+		{Rules: []string{"qux"}, Reason: "applies to the step below", Line: 9, Target: 11},
+	}
+	assert.Equal(t, want, got)
+
+	assert.True(t, got[0].FileScoped())
+	assert.False(t, got[1].FileScoped())
+	assert.False(t, got[1].Expired(time.Date(2025, 6, 30, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, got[1].Expired(time.Date(2025, 7, 1, 1, 0, 0, 0, time.UTC)))
+}
+
+func TestMelange_parseNoLintInvalid(t *testing.T) {
+	for _, content := range []string{
+		"#nolint:foo until=tomorrow // reason\n",
+		"#nolint:foo since=2025-01-01 // reason\n",
+		"#nolint: // reason\n",
+		"package:\n  name: foo\n  # nolint:foo // reason\n",
+	} {
+		got := parseNoLint(content)
+		require.Len(t, got, 1, content)
+		assert.Error(t, got[0].Err, content)
+		assert.False(t, got[0].Suppresses("foo"), content)
+	}
+}
+
+func TestMelange_parseNoLintCommaSpace(t *testing.T) {
+	got := parseNoLint("#nolint:foo, bar until=2025-06-30 // reason\n")
+	require.Len(t, got, 1)
+	require.NoError(t, got[0].Err)
+	assert.Equal(t, []string{"foo", "bar"}, got[0].Rules)
+}
+
+func TestMelange_parseNoLintBlockScalar(t *testing.T) {
+	content := `package:
+  name: foo
+pipeline:
+  - runs: |
+      # nolint: this is shell, not a directive
+      echo "#nolint:foo"
+
+      # nolint: still shell
+  - name: folded
+    runs: >-
+      # nolint:bar
+  # nolint:baz // back in YAML
+  - runs: echo hello
+`
+	got := parseNoLint(content)
+	require.Len(t, got, 1)
+	assert.Equal(t, NoLint{Rules: []string{"baz"}, Reason: "back in YAML", Line: 12, Target: 13}, got[0])
+}